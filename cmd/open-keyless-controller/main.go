@@ -16,7 +16,11 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/betterengineering/open-keyless/pkg/controller"
 )
@@ -32,5 +36,19 @@ func main() {
 		log.Fatalf("could not initialize controller - %s", err)
 	}
 
-	cntrl.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("received %s, shutting down", sig)
+		cancel()
+	}()
+
+	err = cntrl.Run(ctx)
+	if err != nil {
+		log.Fatalf("could not shut down controller cleanly - %s", err)
+	}
 }
@@ -0,0 +1,78 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/audit"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+)
+
+func runAudit(env *environment, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	from := flags.String("from", "", "only show entries at or after this date or RFC 3339 time")
+	to := flags.String("to", "", "only show entries before this RFC 3339 time, or up to the end of this date")
+	badge := flags.String("badge", "", "only show entries for this badge id")
+	limit := flags.Int("limit", api.DefaultAuditLimit, "most recent entries to show")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New("audit does not take any arguments")
+	}
+
+	if env.client == nil {
+		return errors.New("audit requires -server since the audit log is kept by a running controller")
+	}
+
+	if *limit <= 0 {
+		return errors.New("the limit must be a positive number")
+	}
+
+	query := audit.Query{
+		BadgeID: *badge,
+		Limit:   *limit,
+	}
+
+	fromTime, err := datastore.ParseValidity(*from, false)
+	if err != nil {
+		return fmt.Errorf("invalid -from - %s", err)
+	}
+	if fromTime != nil {
+		query.From = *fromTime
+	}
+
+	toTime, err := datastore.ParseValidity(*to, true)
+	if err != nil {
+		return fmt.Errorf("invalid -to - %s", err)
+	}
+	if toTime != nil {
+		query.To = *toTime
+	}
+
+	entries, err := env.client.QueryAudit(query)
+	if err != nil {
+		return err
+	}
+
+	return env.printer.auditEntries(entries)
+}
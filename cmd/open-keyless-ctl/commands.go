@@ -0,0 +1,280 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+func runList(env *environment, args []string) error {
+	if len(args) != 0 {
+		return errors.New("list does not take any arguments")
+	}
+
+	badges, err := env.datastore.ListBadges()
+	if err != nil {
+		return err
+	}
+
+	return env.printer.badges(badges)
+}
+
+func runGet(env *environment, args []string) error {
+	id, err := singleID("get", args)
+	if err != nil {
+		return err
+	}
+
+	return printBadge(env, id)
+}
+
+func runAdd(env *environment, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	badgeType := flags.String("type", "card", "type of badge, ex card, sticker, keychain")
+	disabled := flags.Bool("disabled", false, "add the badge disabled")
+	validFrom := flags.String("valid-from", "", "date or RFC 3339 timestamp the badge is granted access from")
+	validUntil := flags.String("valid-until", "", "date or RFC 3339 timestamp the badge is granted access until")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	id, err := singleID("add", flags.Args())
+	if err != nil {
+		return err
+	}
+
+	from, err := datastore.ParseValidity(*validFrom, false)
+	if err != nil {
+		return err
+	}
+
+	until, err := datastore.ParseValidity(*validUntil, true)
+	if err != nil {
+		return err
+	}
+
+	err = env.datastore.CreateBadgeWithValidity(id, *badgeType, !*disabled, from, until)
+	if err != nil {
+		return err
+	}
+
+	return printBadge(env, id)
+}
+
+func runEnable(env *environment, args []string) error {
+	id, err := singleID("enable", args)
+	if err != nil {
+		return err
+	}
+
+	err = env.datastore.EnableBadge(id)
+	if err != nil {
+		return err
+	}
+
+	return printBadge(env, id)
+}
+
+func runDisable(env *environment, args []string) error {
+	id, err := singleID("disable", args)
+	if err != nil {
+		return err
+	}
+
+	err = env.datastore.DisableBadge(id)
+	if err != nil {
+		return err
+	}
+
+	return printBadge(env, id)
+}
+
+func runDelete(env *environment, args []string) error {
+	id, err := singleID("delete", args)
+	if err != nil {
+		return err
+	}
+
+	return env.datastore.DeleteBadge(id)
+}
+
+func runImport(env *environment, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "",
+		"format of the file, either json or csv. Defaults to json for .json files and csv otherwise")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("import requires a file, use - to read from stdin")
+	}
+	path := flags.Arg(0)
+
+	var content []byte
+	if path == "-" {
+		content, err = ioutil.ReadAll(env.stdin)
+	} else {
+		content, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	badges, err := decodeBadges(content, fileFormat(*format, path))
+	if err != nil {
+		return err
+	}
+
+	// Schedules can not be set through the datastore, so refuse the whole file rather than granting access outside of
+	// the intended schedule.
+	for _, badge := range badges {
+		if badge.Schedule != "" {
+			return fmt.Errorf("badge '%s' has schedule '%s', schedules can not be imported, remove them from the file "+
+				"and set them in the datastore", badge.ID, badge.Schedule)
+		}
+	}
+
+	imported := 0
+	skipped := 0
+	for _, badge := range badges {
+		err = env.datastore.CreateBadgeWithValidity(badge.ID, badge.Type, badge.Enabled, badge.ValidFrom,
+			badge.ValidUntil)
+		if err != nil && err.Error() == datastore.ErrBadgeAlreadyExists {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not import badge '%s' - %s", badge.ID, err)
+		}
+
+		imported++
+	}
+
+	fmt.Fprintf(env.stderr, "imported %d badges, skipped %d that already exist\n", imported, skipped)
+	return nil
+}
+
+func runExport(env *environment, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "",
+		"format of the file, either json or csv. Defaults to json for .json files and csv otherwise, and to json "+
+			"when writing to stdout")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() > 1 {
+		return errors.New("export takes at most one file")
+	}
+
+	badges, err := env.datastore.ListBadges()
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return encodeBadges(env.printer.out, badges, fileFormat(*format, ".json"))
+	}
+
+	path := flags.Arg(0)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = encodeBadges(file, badges, fileFormat(*format, path))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func printBadge(env *environment, id string) error {
+	badge, err := env.datastore.GetBadge(id)
+	if err != nil {
+		return err
+	}
+
+	return env.printer.badge(*badge)
+}
+
+func singleID(name string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("%s requires a single badge id", name)
+	}
+
+	return args[0], nil
+}
+
+// fileFormat returns the explicit format if one was provided, otherwise the format implied by the extension of path.
+func fileFormat(format string, path string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJSON
+	default:
+		return formatCSV
+	}
+}
+
+func decodeBadges(content []byte, format string) ([]datastore.Badge, error) {
+	switch format {
+	case formatJSON:
+		badges := []datastore.Badge{}
+		err := json.Unmarshal(content, &badges)
+		return badges, err
+	case formatCSV:
+		return datastore.ParseTextFile(content)
+	default:
+		return nil, fmt.Errorf("unsupported format '%s', expected json or csv", format)
+	}
+}
+
+func encodeBadges(w io.Writer, badges []datastore.Badge, format string) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(badges)
+	case formatCSV:
+		return datastore.EncodeTextFile(w, badges)
+	default:
+		return fmt.Errorf("unsupported format '%s', expected json or csv", format)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+)
+
+// enrollPollInterval is how often the controller is asked if a badge was enrolled.
+const enrollPollInterval = 500 * time.Millisecond
+
+func runEnroll(env *environment, args []string) error {
+	flags := flag.NewFlagSet("enroll", flag.ContinueOnError)
+	badgeType := flags.String("type", enrollment.DefaultBadgeType, "type of badge, ex card, sticker, keychain")
+	disabled := flags.Bool("disabled", false, "add the badge disabled")
+	reader := flags.String("reader", "", "only enroll badges scanned at the named reader, defaults to any reader")
+	timeout := flags.Duration("timeout", enrollment.DefaultTimeout, "how long to wait for a badge")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New("enroll does not take any arguments")
+	}
+
+	if env.client == nil {
+		return errors.New("enroll requires -server since badges are scanned at a running controller")
+	}
+
+	enabled := !*disabled
+	status, err := env.client.StartEnrollment(api.EnrollmentRequest{
+		Type:    *badgeType,
+		Enabled: &enabled,
+		Reader:  *reader,
+		Timeout: timeout.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	previous := status.LastEnrolledAt
+	if status.ExpiresAt != nil {
+		fmt.Fprintf(env.stderr, "scan the badge to enroll before %s\n", status.ExpiresAt.Local().Format(time.Kitchen))
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(enrollPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			_, err := env.client.CancelEnrollment()
+			if err != nil {
+				return err
+			}
+
+			return errors.New("enrollment cancelled")
+		case <-ticker.C:
+			status, err := env.client.EnrollmentStatus()
+			if err != nil {
+				return err
+			}
+
+			if enrolledSince(status, previous) {
+				return printBadge(env, status.LastEnrolledID)
+			}
+
+			if !status.Active {
+				return errors.New("enrollment ended without enrolling a badge")
+			}
+		}
+	}
+}
+
+// enrolledSince returns true if the status reports a badge enrolled after previous.
+func enrolledSince(status enrollment.Status, previous *time.Time) bool {
+	if status.LastEnrolledAt == nil {
+		return false
+	}
+
+	return previous == nil || status.LastEnrolledAt.After(*previous)
+}
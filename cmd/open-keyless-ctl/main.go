@@ -0,0 +1,223 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Command open-keyless-ctl manages the badges of an Open Keyless controller. It works either directly against the
+// datastore configured in the controller config or remotely through the admin API of a running controller.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/controller"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	log "github.com/sirupsen/logrus"
+)
+
+// command is a subcommand of open-keyless-ctl.
+type command struct {
+	usage       string
+	description string
+	run         func(env *environment, args []string) error
+}
+
+// environment is passed to every command.
+type environment struct {
+	configPath string
+	datastore  datastore.Datastore
+	client     *api.Client
+	printer    *printer
+	stdin      io.Reader
+	stderr     io.Writer
+}
+
+var commands = map[string]command{
+	"list": {
+		usage:       "list",
+		description: "List every badge.",
+		run:         runList,
+	},
+	"get": {
+		usage:       "get <id>",
+		description: "Get a badge.",
+		run:         runGet,
+	},
+	"add": {
+		usage:       "add [-type <type>] [-disabled] [-valid-from <date>] [-valid-until <date>] <id>",
+		description: "Add a badge. Badges are enabled unless -disabled is provided.",
+		run:         runAdd,
+	},
+	"enable": {
+		usage:       "enable <id>",
+		description: "Enable a badge.",
+		run:         runEnable,
+	},
+	"disable": {
+		usage:       "disable <id>",
+		description: "Disable a badge.",
+		run:         runDisable,
+	},
+	"delete": {
+		usage:       "delete <id>",
+		description: "Delete a badge.",
+		run:         runDelete,
+	},
+	"import": {
+		usage:       "import [-format json|csv] <file|->",
+		description: "Add every badge in the file that does not exist yet.",
+		run:         runImport,
+	},
+	"enroll": {
+		usage:       "enroll [-type <type>] [-disabled]",
+		description: "Wait for the next unknown badge scanned at the controller and add it. Requires -server.",
+		run:         runEnroll,
+	},
+	"provision": {
+		usage:       "provision [-type <type>] [-format] <number>",
+		description: "Write a secure credential to the card on a local reader and add it.",
+		run:         runProvision,
+	},
+	"audit": {
+		usage:       "audit [-from <time>] [-to <time>] [-badge <id>] [-limit <n>]",
+		description: "Show the most recent access decisions from the audit log. Requires -server.",
+		run:         runAudit,
+	},
+	"export": {
+		usage:       "export [-format json|csv] [file]",
+		description: "Write every badge to the file or stdout.",
+		run:         runExport,
+	},
+}
+
+func main() {
+	flags := flag.NewFlagSet("open-keyless-ctl", flag.ExitOnError)
+	configPath := flags.String("config", "",
+		"path to the controller config, defaults to the paths searched by the controller")
+	server := flags.String("server", os.Getenv("OPEN_KEYLESS_SERVER"),
+		"admin interface url of a controller to manage remotely, ex https://controller.local:8081")
+	token := flags.String("token", os.Getenv("OPEN_KEYLESS_TOKEN"), "admin token used with -server")
+	caCert := flags.String("ca-cert", "",
+		"certificate used to verify the controller with -server, such as its self-signed certificate")
+	clientCert := flags.String("client-cert", "", "client certificate presented to the controller with -server")
+	clientKey := flags.String("client-key", "", "client key presented to the controller with -server")
+	output := flags.String("o", outputTable, "output format, either table or json")
+	flags.Usage = func() { usage(flags) }
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		usage(flags)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", flags.Arg(0))
+		usage(flags)
+		os.Exit(2)
+	}
+
+	p, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		fatal(err)
+	}
+
+	log.SetLevel(log.WarnLevel)
+
+	var ds datastore.Datastore
+	var client *api.Client
+	if *server != "" {
+		client, err = api.NewClient(api.ClientConfig{
+			Server:         *server,
+			Token:          *token,
+			CACertFile:     *caCert,
+			ClientCertFile: *clientCert,
+			ClientKeyFile:  *clientKey,
+		})
+		ds = client
+	} else {
+		ds, err = openDatastore(*configPath)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	err = cmd.run(&environment{
+		configPath: *configPath,
+		datastore:  ds,
+		client:     client,
+		printer:    p,
+		stdin:      os.Stdin,
+		stderr:     os.Stderr,
+	}, flags.Args()[1:])
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// openDatastore opens the datastore configured in the controller config. The cache is always bypassed so that changes
+// are made directly against the backing datastore, and nothing is started in the background for a single command.
+func openDatastore(configPath string) (datastore.Datastore, error) {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return controller.OpenDatastore(config)
+}
+
+// loadConfig loads the controller config from the provided path, or from the default locations if the path is empty.
+func loadConfig(configPath string) (controller.ControllerConfig, error) {
+	var config controller.ControllerConfig
+	var err error
+	if configPath != "" {
+		config, err = controller.NewControllerConfigFromFile(configPath)
+	} else {
+		config, err = controller.NewControllerConfig()
+	}
+	if err != nil {
+		return config, fmt.Errorf("could not load the controller config - %s", err)
+	}
+
+	return config, nil
+}
+
+func usage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintf(out, "Usage: open-keyless-ctl [flags] <command> [args]\n\nCommands:\n")
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(out, "  %-36s %s\n", cmd.usage, cmd.description)
+	}
+
+	fmt.Fprintf(out, "\nFlags:\n")
+	flags.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "error - %s\n", strings.TrimSpace(err.Error()))
+	os.Exit(1)
+}
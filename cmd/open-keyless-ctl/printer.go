@@ -0,0 +1,129 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/audit"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer writes badges and audit entries in the selected output format.
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+func newPrinter(out io.Writer, format string) (*printer, error) {
+	if format != outputTable && format != outputJSON {
+		return nil, fmt.Errorf("unsupported output format '%s', expected table or json", format)
+	}
+
+	return &printer{
+		out:    out,
+		format: format,
+	}, nil
+}
+
+func (p *printer) badge(badge datastore.Badge) error {
+	if p.format == outputJSON {
+		return p.json(badge)
+	}
+
+	return p.table([]datastore.Badge{badge})
+}
+
+func (p *printer) badges(badges []datastore.Badge) error {
+	if p.format == outputJSON {
+		return p.json(badges)
+	}
+
+	return p.table(badges)
+}
+
+func (p *printer) auditEntries(entries []audit.Entry) error {
+	if p.format == outputJSON {
+		return p.json(entries)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSOURCE\tBADGE\tREADER\tDECISION\tREASON\tSTRIKE ACTUATED")
+
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Timestamp.Local().Format(time.RFC3339),
+			entry.Source,
+			orDash(entry.BadgeID),
+			orDash(entry.Reader),
+			entry.Decision,
+			orDash(entry.Reason),
+			strconv.FormatBool(entry.StrikeActuated),
+		)
+	}
+
+	return w.Flush()
+}
+
+func (p *printer) json(v interface{}) error {
+	encoder := json.NewEncoder(p.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (p *printer) table(badges []datastore.Badge) error {
+	w := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tENABLED\tSCHEDULE\tVALID FROM\tVALID UNTIL")
+
+	for _, badge := range badges {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			badge.ID,
+			orDash(badge.Type),
+			strconv.FormatBool(badge.Enabled),
+			orDash(badge.Schedule),
+			formatTime(badge.ValidFrom),
+			formatTime(badge.ValidUntil),
+		)
+	}
+
+	return w.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
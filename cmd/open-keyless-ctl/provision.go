@@ -0,0 +1,141 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/controller"
+	"github.com/betterengineering/open-keyless/pkg/provisioning"
+	"github.com/fuzxxl/nfc/2.0/nfc"
+)
+
+// provisionPollInterval is how often the reader is checked for a card while waiting for one.
+const provisionPollInterval = 500 * time.Millisecond
+
+func runProvision(env *environment, args []string) error {
+	flags := flag.NewFlagSet("provision", flag.ContinueOnError)
+	badgeType := flags.String("type", "card", "type of badge, ex card, sticker, keychain")
+	disabled := flags.Bool("disabled", false, "add the badge disabled")
+	readerName := flags.String("reader", "",
+		"reader in the controller config whose secure credential settings are used, defaults to the first one with a "+
+			"secure credential")
+	connection := flags.String("connection", "",
+		"libnfc connection string of the reader the card is placed on, defaults to the connection of -reader")
+	applicationKey := flags.String("application-key", os.Getenv("OPEN_KEYLESS_APPLICATION_KEY"),
+		"hex encoded AES master key for the application master key of each card")
+	piccKey := flags.String("picc-key", "",
+		"hex encoded 2K3DES PICC master key used with -format, defaults to the all zero key of blank cards")
+	format := flags.Bool("format", false, "delete every application on the card first")
+	timeout := flags.Duration("timeout", 30*time.Second, "how long to wait for a card")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("provision requires exactly one credential number")
+	}
+
+	number, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid credential number '%s' - %s", flags.Arg(0), err)
+	}
+
+	config, err := loadConfig(env.configPath)
+	if err != nil {
+		return err
+	}
+
+	reader, err := secureCredentialReader(config, *readerName)
+	if err != nil {
+		return err
+	}
+
+	cfg := provisioning.Config{
+		Credential: reader.LibNFCConfig.Credential,
+		Format:     *format,
+	}
+
+	cfg.ApplicationKey, err = hex.DecodeString(*applicationKey)
+	if err != nil {
+		return fmt.Errorf("invalid application key - %s", err)
+	}
+
+	if *piccKey != "" {
+		cfg.PICCKey, err = hex.DecodeString(*piccKey)
+		if err != nil {
+			return fmt.Errorf("invalid picc key - %s", err)
+		}
+	}
+
+	if *connection == "" {
+		*connection = reader.LibNFCConfig.Connection
+	}
+
+	device, err := nfc.Open(*connection)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	err = device.InitiatorInit()
+	if err != nil {
+		return err
+	}
+
+	p, err := provisioning.NewProvisioner(device, env.datastore, cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.stderr, "place a card on the reader\n")
+	deadline := time.Now().Add(*timeout)
+	for {
+		result, err := p.Provision(number, *badgeType, !*disabled)
+		if err != nil && err.Error() == provisioning.ErrNoCard && time.Now().Before(deadline) {
+			time.Sleep(provisionPollInterval)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(env.stderr, "provisioned card %s\n", hex.EncodeToString(result.UID))
+		return printBadge(env, result.ID)
+	}
+}
+
+// secureCredentialReader returns the named reader, or the first reader with a secure credential if no name is given.
+func secureCredentialReader(config controller.ControllerConfig, name string) (controller.ReaderConfig, error) {
+	for _, reader := range config.Readers {
+		if (name == "" || reader.Name == name) && reader.LibNFCConfig.SecureCredential {
+			return reader, nil
+		}
+	}
+
+	if name != "" {
+		return controller.ReaderConfig{}, fmt.Errorf("reader '%s' is not configured with a secure credential", name)
+	}
+
+	return controller.ReaderConfig{}, errors.New("no reader is configured with a secure credential")
+}
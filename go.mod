@@ -3,9 +3,10 @@ module github.com/betterengineering/open-keyless
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
 	github.com/fabioberger/airtable-go v3.1.0+incompatible
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/fuzxxl/nfc v0.0.0-20160114122741-3b2ea457777d
 	github.com/golang/mock v1.2.0
-	github.com/karalabe/hid v1.0.1-0.20190806082151-9c14560f9ee8 // indirect
+	github.com/karalabe/hid v1.0.1-0.20190806082151-9c14560f9ee8
 	github.com/prometheus/client_golang v0.9.2
 	github.com/sirupsen/logrus v1.3.0
 	github.com/spf13/viper v1.3.1
@@ -6,6 +6,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	datastore "github.com/betterengineering/open-keyless/pkg/datastore"
 	gomock "github.com/golang/mock/gomock"
@@ -78,6 +79,20 @@ func (mr *MockDatastoreMockRecorder) CreateBadge(id, badgeType, enabled interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBadge", reflect.TypeOf((*MockDatastore)(nil).CreateBadge), id, badgeType, enabled)
 }
 
+// CreateBadgeWithValidity mocks base method
+func (m *MockDatastore) CreateBadgeWithValidity(id, badgeType string, enabled bool, from, until *time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBadgeWithValidity", id, badgeType, enabled, from, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBadgeWithValidity indicates an expected call of CreateBadgeWithValidity
+func (mr *MockDatastoreMockRecorder) CreateBadgeWithValidity(id, badgeType, enabled, from, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBadgeWithValidity", reflect.TypeOf((*MockDatastore)(nil).CreateBadgeWithValidity), id, badgeType, enabled, from, until)
+}
+
 // EnableBadge mocks base method
 func (m *MockDatastore) EnableBadge(id string) error {
 	m.ctrl.T.Helper()
@@ -76,3 +76,47 @@ func (mr *MockLibNFCDeviceMockRecorder) InitiatorListPassiveTargets(mod interfac
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitiatorListPassiveTargets", reflect.TypeOf((*MockLibNFCDevice)(nil).InitiatorListPassiveTargets), mod)
 }
+
+// InitiatorSelectPassiveTarget mocks base method
+func (m *MockLibNFCDevice) InitiatorSelectPassiveTarget(mod nfc.Modulation, initData []byte) (nfc.Target, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InitiatorSelectPassiveTarget", mod, initData)
+	ret0, _ := ret[0].(nfc.Target)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InitiatorSelectPassiveTarget indicates an expected call of InitiatorSelectPassiveTarget
+func (mr *MockLibNFCDeviceMockRecorder) InitiatorSelectPassiveTarget(mod, initData interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitiatorSelectPassiveTarget", reflect.TypeOf((*MockLibNFCDevice)(nil).InitiatorSelectPassiveTarget), mod, initData)
+}
+
+// InitiatorDeselectTarget mocks base method
+func (m *MockLibNFCDevice) InitiatorDeselectTarget() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InitiatorDeselectTarget")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InitiatorDeselectTarget indicates an expected call of InitiatorDeselectTarget
+func (mr *MockLibNFCDeviceMockRecorder) InitiatorDeselectTarget() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitiatorDeselectTarget", reflect.TypeOf((*MockLibNFCDevice)(nil).InitiatorDeselectTarget))
+}
+
+// InitiatorTransceiveBytes mocks base method
+func (m *MockLibNFCDevice) InitiatorTransceiveBytes(tx, rx []byte, timeout int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InitiatorTransceiveBytes", tx, rx, timeout)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InitiatorTransceiveBytes indicates an expected call of InitiatorTransceiveBytes
+func (mr *MockLibNFCDeviceMockRecorder) InitiatorTransceiveBytes(tx, rx, timeout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitiatorTransceiveBytes", reflect.TypeOf((*MockLibNFCDevice)(nil).InitiatorTransceiveBytes), tx, rx, timeout)
+}
@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,15 +35,15 @@ func (m *MockScanner) EXPECT() *MockScannerMockRecorder {
 }
 
 // Scan mocks base method
-func (m *MockScanner) Scan() {
+func (m *MockScanner) Scan(ctx context.Context) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Scan")
+	m.ctrl.Call(m, "Scan", ctx)
 }
 
 // Scan indicates an expected call of Scan
-func (mr *MockScannerMockRecorder) Scan() *gomock.Call {
+func (mr *MockScannerMockRecorder) Scan(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockScanner)(nil).Scan))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockScanner)(nil).Scan), ctx)
 }
 
 // Done mocks base method
@@ -0,0 +1,91 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package api provides the JSON REST API served on the admin interface of the controller.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrMethodNotAllowed is returned when a route is requested with an unsupported method.
+	ErrMethodNotAllowed = "the requested method is not allowed for this route"
+
+	// ErrNotFound is returned when a route does not exist.
+	ErrNotFound = "the requested route does not exist"
+
+	// ErrInvalidRequestBody is returned when the request body can not be decoded.
+	ErrInvalidRequestBody = "could not decode the request body"
+
+	// Prefix is the path prefix all API routes are served under.
+	Prefix = "/api/v1"
+)
+
+// ErrorResponse is the body returned for every unsuccessful request.
+type ErrorResponse struct {
+	// Error describes what went wrong.
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("could not write api response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+}
+
+// decodeJSON decodes the request body into v and writes a bad request response if it can not be decoded.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequestBody+" - "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+// splitPath returns the segments of the path after the prefix. Ex "/api/v1/badges/8604de7d/enable" with the prefix
+// "/api/v1/badges" returns ["8604de7d", "enable"].
+func splitPath(path string, prefix string) []string {
+	rest := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if rest == "" {
+		return []string{}
+	}
+
+	return strings.Split(rest, "/")
+}
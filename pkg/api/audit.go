@@ -0,0 +1,117 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/betterengineering/open-keyless/pkg/audit"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrInvalidAuditTime is returned when the audit log is queried with a from or to that is not a date or RFC 3339
+	// timestamp.
+	ErrInvalidAuditTime = "the audit query time range could not be parsed"
+
+	// ErrInvalidAuditLimit is returned when the audit log is queried with a limit that is not a positive number.
+	ErrInvalidAuditLimit = "the audit query limit must be a positive number"
+
+	// DefaultAuditLimit is the most entries returned when a limit is not provided.
+	DefaultAuditLimit = 100
+
+	// AuditPath is the path the audit log route is served under.
+	AuditPath = Prefix + "/audit"
+)
+
+// AuditQuerier is an interface for querying the audit log.
+type AuditQuerier interface {
+	Query(query audit.Query) ([]audit.Entry, error)
+}
+
+// AuditAPI serves the audit log route.
+//
+//	GET /api/v1/audit?from=&to=&badge=&limit= query the audit log
+type AuditAPI struct {
+	querier AuditQuerier
+}
+
+// NewAuditAPI provides an initialized AuditAPI for the provided audit log.
+func NewAuditAPI(querier AuditQuerier) *AuditAPI {
+	return &AuditAPI{
+		querier: querier,
+	}
+}
+
+// Register registers the audit route with the provided register function, such as application.HandleAdmin.
+func (a *AuditAPI) Register(handle func(pattern string, handler http.Handler)) {
+	handle(AuditPath, a)
+}
+
+// ServeHTTP returns the entries of the audit log that match the query parameters, oldest first. from and to are RFC
+// 3339 timestamps or "2006-01-02" dates, where a to date includes the whole day. Only the most recent limit entries
+// are returned, which defaults to DefaultAuditLimit.
+func (a *AuditAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	values := r.URL.Query()
+	query := audit.Query{
+		BadgeID: values.Get("badge"),
+		Limit:   DefaultAuditLimit,
+	}
+
+	from, err := datastore.ParseValidity(values.Get("from"), false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrInvalidAuditTime+" - "+err.Error())
+		return
+	}
+	if from != nil {
+		query.From = *from
+	}
+
+	to, err := datastore.ParseValidity(values.Get("to"), true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrInvalidAuditTime+" - "+err.Error())
+		return
+	}
+	if to != nil {
+		query.To = *to
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit <= 0 {
+			writeError(w, http.StatusBadRequest, ErrInvalidAuditLimit)
+			return
+		}
+	}
+
+	entries, err := a.querier.Query(query)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("could not query the audit log")
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/application"
+	"github.com/betterengineering/open-keyless/pkg/audit"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeAuditLog struct {
+	query   audit.Query
+	entries []audit.Entry
+	err     error
+}
+
+func (f *fakeAuditLog) Query(query audit.Query) ([]audit.Entry, error) {
+	f.query = query
+	return f.entries, f.err
+}
+
+func TestAuditAPI(t *testing.T) {
+	from := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 6, 2, 0, 0, 0, 0, time.Local)
+
+	cases := map[string]struct {
+		path   string
+		status int
+		query  audit.Query
+	}{
+		"default": {"/api/v1/audit", http.StatusOK, audit.Query{Limit: api.DefaultAuditLimit}},
+		"badge":   {"/api/v1/audit?badge=8604de7d&limit=5", http.StatusOK, audit.Query{BadgeID: "8604de7d", Limit: 5}},
+		"range": {
+			path:   "/api/v1/audit?from=2021-06-01T08:00:00Z&to=2021-06-01",
+			status: http.StatusOK,
+			query:  audit.Query{From: from, To: to, Limit: api.DefaultAuditLimit},
+		},
+		"from":      {"/api/v1/audit?from=yesterday", http.StatusBadRequest, audit.Query{}},
+		"limit":     {"/api/v1/audit?limit=0", http.StatusBadRequest, audit.Query{}},
+		"not limit": {"/api/v1/audit?limit=all", http.StatusBadRequest, audit.Query{}},
+	}
+
+	for name, c := range cases {
+		auditLog := &fakeAuditLog{}
+		response := serve(api.NewAuditAPI(auditLog), http.MethodGet, c.path, "")
+
+		if response.Code != c.status {
+			t.Errorf("expected status %d for the %s case but got %d", c.status, name, response.Code)
+		}
+
+		actual := auditLog.query
+		expected := c.query
+		if !actual.From.Equal(expected.From) || !actual.To.Equal(expected.To) || actual.BadgeID != expected.BadgeID ||
+			actual.Limit != expected.Limit {
+			t.Errorf("expected the %s case to query '%+v' but got '%+v'", name, expected, actual)
+		}
+	}
+}
+
+func TestAuditAPIMethodNotAllowed(t *testing.T) {
+	response := serve(api.NewAuditAPI(&fakeAuditLog{}), http.MethodPost, "/api/v1/audit", "")
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d but got %d", http.StatusMethodNotAllowed, response.Code)
+	}
+}
+
+func TestAuditAPIQueryError(t *testing.T) {
+	auditLog := &fakeAuditLog{err: errors.New("permission denied")}
+
+	response := serve(api.NewAuditAPI(auditLog), http.MethodGet, "/api/v1/audit", "")
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, response.Code)
+	}
+}
+
+func TestAuditAPIRequiresAuthentication(t *testing.T) {
+	app := application.NewApplication(application.Config{
+		LogLevel:    logrus.WarnLevel,
+		AdminTokens: []application.Token{{Name: "dashboard", Token: "s3cret", Scope: application.ScopeReadOnly}},
+	}, application.OpenKeylessController)
+	api.NewAuditAPI(&fakeAuditLog{}).Register(app.HandleAdmin)
+
+	response := httptest.NewRecorder()
+	app.AdminHandler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil))
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a token but got %d", http.StatusUnauthorized, response.Code)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	request.Header.Set("Authorization", "Bearer s3cret")
+	response = httptest.NewRecorder()
+	app.AdminHandler().ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("expected status %d with a read-only token but got %d", http.StatusOK, response.Code)
+	}
+}
+
+func TestClientQueryAudit(t *testing.T) {
+	at := time.Date(2021, 6, 1, 8, 30, 0, 0, time.UTC)
+	auditLog := &fakeAuditLog{entries: []audit.Entry{{
+		Timestamp: at,
+		Source:    audit.SourceBadge,
+		BadgeID:   "8604de7d",
+		Reader:    "entry",
+		Decision:  audit.DecisionGranted,
+	}}}
+
+	server := httptest.NewServer(api.NewAuditAPI(auditLog))
+	defer server.Close()
+
+	client, err := api.NewClient(api.ClientConfig{Server: server.URL})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	from := at.Add(-time.Hour)
+	entries, err := client.QueryAudit(audit.Query{From: from, BadgeID: "8604de7d", Limit: 10})
+	if err != nil {
+		t.Fatalf("error querying the audit log - %s", err)
+	}
+
+	if len(entries) != 1 || entries[0].BadgeID != "8604de7d" || !entries[0].Timestamp.Equal(at) {
+		t.Errorf("unexpected entries '%+v'", entries)
+	}
+
+	if !auditLog.query.From.Equal(from) || auditLog.query.BadgeID != "8604de7d" || auditLog.query.Limit != 10 {
+		t.Errorf("unexpected query '%+v'", auditLog.query)
+	}
+}
+
+func TestClientQueryAuditDisabled(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client, err := api.NewClient(api.ClientConfig{Server: server.URL})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	_, err = client.QueryAudit(audit.Query{})
+	if err == nil || err.Error() != api.ErrAuditLogDisabled {
+		t.Errorf("expected error '%s' but got '%v'", api.ErrAuditLogDisabled, err)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrBadgeIDRequired is returned when a badge is created without an id.
+	ErrBadgeIDRequired = "a badge id is required"
+
+	// ErrInvalidValidity is returned when the validity period of a badge is not a date or RFC 3339 timestamp.
+	ErrInvalidValidity = "the badge validity could not be parsed"
+
+	// BadgesPath is the path badge routes are served under.
+	BadgesPath = Prefix + "/badges"
+)
+
+// BadgeAPI serves the badge management routes backed by a datastore.
+//
+//	GET    /api/v1/badges              list every badge
+//	POST   /api/v1/badges              create a badge
+//	GET    /api/v1/badges/{id}         get a badge
+//	DELETE /api/v1/badges/{id}         delete a badge
+//	POST   /api/v1/badges/{id}/enable  enable a badge
+//	POST   /api/v1/badges/{id}/disable disable a badge
+type BadgeAPI struct {
+	datastore datastore.Datastore
+}
+
+// CreateBadgeRequest is the body of a request to create a badge.
+type CreateBadgeRequest struct {
+	// ID is the id burned into the RFID badge.
+	ID string `json:"id"`
+
+	// Type is the type of badge. E.x. card, sticker, keychain.
+	Type string `json:"type"`
+
+	// Enabled determines if the badge should be considered active.
+	Enabled bool `json:"enabled"`
+
+	// ValidFrom is an optional RFC 3339 timestamp or "2006-01-02" date from which the badge is granted access.
+	ValidFrom string `json:"validFrom,omitempty"`
+
+	// ValidUntil is an optional RFC 3339 timestamp or "2006-01-02" date until which the badge is granted access. A
+	// date includes the whole day.
+	ValidUntil string `json:"validUntil,omitempty"`
+}
+
+// NewBadgeAPI provides an initialized BadgeAPI for the provided datastore.
+func NewBadgeAPI(ds datastore.Datastore) *BadgeAPI {
+	return &BadgeAPI{
+		datastore: ds,
+	}
+}
+
+// Register registers the badge routes with the provided register function, such as application.HandleAdmin.
+func (b *BadgeAPI) Register(handle func(pattern string, handler http.Handler)) {
+	handle(BadgesPath, b)
+	handle(BadgesPath+"/", b)
+}
+
+// ServeHTTP routes the request to the matching badge operation.
+func (b *BadgeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path, BadgesPath)
+
+	switch len(segments) {
+	case 0:
+		switch r.Method {
+		case http.MethodGet:
+			b.listBadges(w)
+		case http.MethodPost:
+			b.createBadge(w, r)
+		default:
+			writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+	case 1:
+		switch r.Method {
+		case http.MethodGet:
+			b.getBadge(w, segments[0])
+		case http.MethodDelete:
+			b.deleteBadge(w, segments[0])
+		default:
+			writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case 2:
+		if segments[1] != "enable" && segments[1] != "disable" {
+			writeError(w, http.StatusNotFound, ErrNotFound)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, http.MethodPost)
+			return
+		}
+
+		b.setEnabled(w, segments[0], segments[1] == "enable")
+	default:
+		writeError(w, http.StatusNotFound, ErrNotFound)
+	}
+}
+
+func (b *BadgeAPI) listBadges(w http.ResponseWriter) {
+	badges, err := b.datastore.ListBadges()
+	if err != nil {
+		writeDatastoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, badges)
+}
+
+func (b *BadgeAPI) createBadge(w http.ResponseWriter, r *http.Request) {
+	var request CreateBadgeRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	if request.ID == "" {
+		writeError(w, http.StatusBadRequest, ErrBadgeIDRequired)
+		return
+	}
+
+	validFrom, err := datastore.ParseValidity(request.ValidFrom, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrInvalidValidity+" - "+err.Error())
+		return
+	}
+
+	validUntil, err := datastore.ParseValidity(request.ValidUntil, true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrInvalidValidity+" - "+err.Error())
+		return
+	}
+
+	err = b.datastore.CreateBadgeWithValidity(request.ID, request.Type, request.Enabled, validFrom, validUntil)
+	if err != nil {
+		writeDatastoreError(w, err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"id":          request.ID,
+		"type":        request.Type,
+		"enabled":     request.Enabled,
+		"valid_from":  request.ValidFrom,
+		"valid_until": request.ValidUntil,
+	}).Info("created badge through the api")
+
+	b.writeBadge(w, http.StatusCreated, request.ID)
+}
+
+func (b *BadgeAPI) getBadge(w http.ResponseWriter, id string) {
+	b.writeBadge(w, http.StatusOK, id)
+}
+
+func (b *BadgeAPI) deleteBadge(w http.ResponseWriter, id string) {
+	err := b.datastore.DeleteBadge(id)
+	if err != nil {
+		writeDatastoreError(w, err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"id": id,
+	}).Info("deleted badge through the api")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *BadgeAPI) setEnabled(w http.ResponseWriter, id string, enabled bool) {
+	var err error
+	if enabled {
+		err = b.datastore.EnableBadge(id)
+	} else {
+		err = b.datastore.DisableBadge(id)
+	}
+	if err != nil {
+		writeDatastoreError(w, err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"id":      id,
+		"enabled": enabled,
+	}).Info("updated badge through the api")
+
+	b.writeBadge(w, http.StatusOK, id)
+}
+
+func (b *BadgeAPI) writeBadge(w http.ResponseWriter, status int, id string) {
+	badge, err := b.datastore.GetBadge(id)
+	if err != nil {
+		writeDatastoreError(w, err)
+		return
+	}
+
+	writeJSON(w, status, badge)
+}
+
+// writeDatastoreError maps errors from the datastore to a status code.
+func writeDatastoreError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case datastore.ErrBadgeDoesNotExist:
+		writeError(w, http.StatusNotFound, err.Error())
+	case datastore.ErrBadgeAlreadyExists:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("error communicating with the datastore")
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
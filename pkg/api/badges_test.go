@@ -0,0 +1,168 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/golang/mock/gomock"
+)
+
+func TestBadgeAPIListBadges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().ListBadges().Return([]datastore.Badge{{ID: "8604de7d", Type: "card", Enabled: true}}, nil)
+
+	response := serve(api.NewBadgeAPI(ds), http.MethodGet, "/api/v1/badges", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, response.Code)
+	}
+
+	badges := []datastore.Badge{}
+	err := json.NewDecoder(response.Body).Decode(&badges)
+	if err != nil {
+		t.Fatalf("error decoding response - %s", err)
+	}
+
+	if len(badges) != 1 || badges[0].ID != "8604de7d" {
+		t.Errorf("expected the badge from the datastore but got '%+v'", badges)
+	}
+}
+
+func TestBadgeAPICreateBadge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().CreateBadgeWithValidity("8604de7d", "card", true, nil, nil).Return(nil)
+	ds.EXPECT().GetBadge("8604de7d").Return(&datastore.Badge{ID: "8604de7d", Type: "card", Enabled: true}, nil)
+	ds.EXPECT().CreateBadgeWithValidity("04a1b2c3", "card", false, nil, nil).
+		Return(errors.New(datastore.ErrBadgeAlreadyExists))
+
+	cases := map[string]int{
+		`{"id":"8604de7d","type":"card","enabled":true}`: http.StatusCreated,
+		`{"id":"04a1b2c3","type":"card"}`:                http.StatusConflict,
+		`{"type":"card"}`:                                http.StatusBadRequest,
+		`{"id":"04a1b2c3","color":"blue"}`:               http.StatusBadRequest,
+	}
+
+	for body, expected := range cases {
+		response := serve(api.NewBadgeAPI(ds), http.MethodPost, "/api/v1/badges", body)
+		if response.Code != expected {
+			t.Errorf("expected status %d for '%s' but got %d", expected, body, response.Code)
+		}
+	}
+}
+
+func TestBadgeAPICreateBadgeWithValidity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// A validUntil date includes the whole day.
+	validFrom := time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	validUntil := time.Date(2021, 7, 1, 0, 0, 0, 0, time.Local)
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().CreateBadgeWithValidity("8604de7d", "", false, &validFrom, &validUntil).Return(nil)
+	ds.EXPECT().GetBadge("8604de7d").Return(&datastore.Badge{ID: "8604de7d"}, nil)
+
+	cases := map[string]int{
+		`{"id":"8604de7d","validFrom":"2021-06-01","validUntil":"2021-06-30"}`: http.StatusCreated,
+		`{"id":"8604de7d","validUntil":"next week"}`:                           http.StatusBadRequest,
+	}
+
+	for body, expected := range cases {
+		response := serve(api.NewBadgeAPI(ds), http.MethodPost, "/api/v1/badges", body)
+		if response.Code != expected {
+			t.Errorf("expected status %d for '%s' but got %d", expected, body, response.Code)
+		}
+	}
+}
+
+func TestBadgeAPIBadgeDoesNotExist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	notFound := errors.New(datastore.ErrBadgeDoesNotExist)
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge("ffffffff").Return(nil, notFound)
+	ds.EXPECT().DeleteBadge("ffffffff").Return(notFound)
+	ds.EXPECT().EnableBadge("ffffffff").Return(notFound)
+	ds.EXPECT().DisableBadge("ffffffff").Return(notFound)
+
+	requests := map[string]string{
+		"/api/v1/badges/ffffffff":         http.MethodGet,
+		"/api/v1/badges/ffffffff/":        http.MethodDelete,
+		"/api/v1/badges/ffffffff/enable":  http.MethodPost,
+		"/api/v1/badges/ffffffff/disable": http.MethodPost,
+	}
+
+	for path, method := range requests {
+		response := serve(api.NewBadgeAPI(ds), method, path, "")
+		if response.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for %s %s but got %d", http.StatusNotFound, method, path, response.Code)
+		}
+	}
+}
+
+func TestBadgeAPIEnableBadge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().EnableBadge("8604de7d").Return(nil)
+	ds.EXPECT().GetBadge("8604de7d").Return(&datastore.Badge{ID: "8604de7d", Enabled: true}, nil)
+
+	response := serve(api.NewBadgeAPI(ds), http.MethodPost, "/api/v1/badges/8604de7d/enable", "")
+	if response.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, response.Code)
+	}
+}
+
+func TestBadgeAPIMethodNotAllowed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+
+	response := serve(api.NewBadgeAPI(ds), http.MethodPut, "/api/v1/badges/8604de7d", "")
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d but got %d", http.StatusMethodNotAllowed, response.Code)
+	}
+
+	if response.Header().Get("Allow") == "" {
+		t.Errorf("expected the allowed methods to be returned")
+	}
+}
+
+func serve(handler http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
@@ -0,0 +1,311 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/audit"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+)
+
+const (
+	// ErrInvalidCACert is returned when the CA certificate for the client does not contain any certificates.
+	ErrInvalidCACert = "could not find any certificates in the ca cert file"
+
+	// ErrAuditLogDisabled is returned when the audit log is queried on a controller that does not have it enabled.
+	ErrAuditLogDisabled = "the audit log is not enabled on the controller"
+
+	// DefaultClientTimeout is the timeout for requests made by the client when one is not configured.
+	DefaultClientTimeout = 10 * time.Second
+)
+
+// Client implements the datastore interface against the admin API of a controller.
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// ClientConfig is a configuration struct for a Client.
+type ClientConfig struct {
+	// Server is the base URL of the admin interface of the controller. Ex "https://controller.local:8081".
+	Server string
+
+	// Token is the bearer token sent with every request.
+	Token string
+
+	// CACertFile is the path to a PEM encoded certificate used to verify the controller, such as its self-signed
+	// certificate. If empty, the system roots are used.
+	CACertFile string
+
+	// ClientCertFile and ClientKeyFile are the paths to the PEM encoded certificate and key presented to a controller
+	// that requires client certificates.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// Timeout is the timeout for each request.
+	Timeout time.Duration
+}
+
+// NewClient provides an initialized Client using the provided configuration.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CACertFile != "" {
+		bundle, err := ioutil.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New(ErrInvalidCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultClientTimeout
+	}
+
+	return &Client{
+		server: strings.TrimSuffix(cfg.Server, "/"),
+		token:  cfg.Token,
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// HasAccess returns true if the badge with the given ID exists and is enabled.
+func (c *Client) HasAccess(id string) (bool, error) {
+	badge, err := c.GetBadge(id)
+	if err != nil {
+		if err.Error() == datastore.ErrBadgeDoesNotExist {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return badge.Enabled && badge.ValidAt(time.Now()), nil
+}
+
+// ListBadges returns a list of badges from the controller.
+func (c *Client) ListBadges() ([]datastore.Badge, error) {
+	badges := []datastore.Badge{}
+	err := c.do(http.MethodGet, BadgesPath, nil, &badges)
+	return badges, err
+}
+
+// CreateBadge creates a badge through the controller with the provided values.
+func (c *Client) CreateBadge(id string, badgeType string, enabled bool) error {
+	request := CreateBadgeRequest{
+		ID:      id,
+		Type:    badgeType,
+		Enabled: enabled,
+	}
+
+	return c.do(http.MethodPost, BadgesPath, request, nil)
+}
+
+// CreateBadgeWithValidity creates a badge through the controller that is only granted access between from and until.
+// A nil from or until leaves that side of the validity period open.
+func (c *Client) CreateBadgeWithValidity(id, badgeType string, enabled bool, from, until *time.Time) error {
+	request := CreateBadgeRequest{
+		ID:         id,
+		Type:       badgeType,
+		Enabled:    enabled,
+		ValidFrom:  formatValidity(from),
+		ValidUntil: formatValidity(until),
+	}
+
+	return c.do(http.MethodPost, BadgesPath, request, nil)
+}
+
+// EnableBadge enables a badge through the controller.
+func (c *Client) EnableBadge(id string) error {
+	return c.do(http.MethodPost, badgePath(id)+"/enable", nil, nil)
+}
+
+// DisableBadge disables a badge through the controller.
+func (c *Client) DisableBadge(id string) error {
+	return c.do(http.MethodPost, badgePath(id)+"/disable", nil, nil)
+}
+
+// DeleteBadge deletes a badge through the controller.
+func (c *Client) DeleteBadge(id string) error {
+	return c.do(http.MethodDelete, badgePath(id), nil, nil)
+}
+
+// GetBadge returns a badge with the given ID. If the badge does not exist, ErrBadgeDoesNotExist will be returned.
+func (c *Client) GetBadge(id string) (*datastore.Badge, error) {
+	badge := &datastore.Badge{}
+	err := c.do(http.MethodGet, badgePath(id), nil, badge)
+	if err != nil {
+		return nil, err
+	}
+
+	return badge, nil
+}
+
+// StartEnrollment starts waiting for the next unknown badge scanned at the controller.
+func (c *Client) StartEnrollment(request EnrollmentRequest) (enrollment.Status, error) {
+	status := enrollment.Status{}
+	err := c.do(http.MethodPost, EnrollmentPath, request, &status)
+	return status, err
+}
+
+// EnrollmentStatus returns the state of enrollment on the controller.
+func (c *Client) EnrollmentStatus() (enrollment.Status, error) {
+	status := enrollment.Status{}
+	err := c.do(http.MethodGet, EnrollmentPath, nil, &status)
+	return status, err
+}
+
+// CancelEnrollment cancels enrollment on the controller.
+func (c *Client) CancelEnrollment() (enrollment.Status, error) {
+	status := enrollment.Status{}
+	err := c.do(http.MethodDelete, EnrollmentPath, nil, &status)
+	return status, err
+}
+
+// QueryAudit returns the entries of the audit log on the controller that match the query, oldest first. A zero limit
+// returns up to DefaultAuditLimit entries.
+func (c *Client) QueryAudit(query audit.Query) ([]audit.Entry, error) {
+	values := url.Values{}
+	if !query.From.IsZero() {
+		values.Set("from", query.From.Format(time.RFC3339Nano))
+	}
+	if !query.To.IsZero() {
+		values.Set("to", query.To.Format(time.RFC3339Nano))
+	}
+	if query.BadgeID != "" {
+		values.Set("badge", query.BadgeID)
+	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	path := AuditPath
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+
+	entries := []audit.Entry{}
+	err := c.do(http.MethodGet, path, nil, &entries)
+	if err != nil && err.Error() == datastore.ErrBadgeDoesNotExist {
+		// The route is only served when the audit log is enabled.
+		return nil, errors.New(ErrAuditLogDisabled)
+	}
+
+	return entries, err
+}
+
+// do makes a request to the controller. The request body is encoded from in and the response body is decoded into out
+// when they are not nil. Unsuccessful responses are returned as errors, with a 404 mapped to ErrBadgeDoesNotExist and a
+// 409 mapped to ErrBadgeAlreadyExists so that callers can treat the client like any other datastore.
+func (c *Client) do(method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return err
+	}
+
+	if in != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return responseError(response)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+func responseError(response *http.Response) error {
+	switch response.StatusCode {
+	case http.StatusNotFound:
+		return errors.New(datastore.ErrBadgeDoesNotExist)
+	case http.StatusConflict:
+		return errors.New(datastore.ErrBadgeAlreadyExists)
+	}
+
+	var body ErrorResponse
+	err := json.NewDecoder(response.Body).Decode(&body)
+	if err != nil || body.Error == "" {
+		return fmt.Errorf("unexpected response from the controller - %s", response.Status)
+	}
+
+	return fmt.Errorf("%s - %s", response.Status, body.Error)
+}
+
+func badgePath(id string) string {
+	return BadgesPath + "/" + url.PathEscape(id)
+}
+
+func formatValidity(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/golang/mock/gomock"
+)
+
+func TestClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	badge := datastore.Badge{ID: "8604de7d", Type: "card", Enabled: true}
+	validUntil := time.Date(2021, 6, 30, 18, 0, 0, 0, time.UTC)
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().ListBadges().Return([]datastore.Badge{badge}, nil)
+	ds.EXPECT().CreateBadgeWithValidity("8604de7d", "card", true, nil, nil).Return(nil)
+	ds.EXPECT().CreateBadgeWithValidity("0c0ffee0", "card", true, nil, &validUntil).Return(nil)
+	ds.EXPECT().GetBadge("0c0ffee0").Return(&datastore.Badge{ID: "0c0ffee0", ValidUntil: &validUntil}, nil)
+	ds.EXPECT().GetBadge("8604de7d").Return(&badge, nil).Times(3)
+	ds.EXPECT().DisableBadge("8604de7d").Return(nil)
+	ds.EXPECT().DeleteBadge("8604de7d").Return(nil)
+	ds.EXPECT().GetBadge("ffffffff").Return(nil, errors.New(datastore.ErrBadgeDoesNotExist))
+
+	server := httptest.NewServer(api.NewBadgeAPI(ds))
+	defer server.Close()
+
+	client, err := api.NewClient(api.ClientConfig{Server: server.URL})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	badges, err := client.ListBadges()
+	if err != nil {
+		t.Fatalf("error listing badges - %s", err)
+	}
+
+	if !reflect.DeepEqual([]datastore.Badge{badge}, badges) {
+		t.Errorf("expected '%+v' does not equal actual '%+v'", []datastore.Badge{badge}, badges)
+	}
+
+	err = client.CreateBadge("8604de7d", "card", true)
+	if err != nil {
+		t.Fatalf("error creating badge - %s", err)
+	}
+
+	err = client.CreateBadgeWithValidity("0c0ffee0", "card", true, nil, &validUntil)
+	if err != nil {
+		t.Fatalf("error creating badge with a validity period - %s", err)
+	}
+
+	actual, err := client.GetBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error getting badge - %s", err)
+	}
+
+	if !reflect.DeepEqual(badge, *actual) {
+		t.Errorf("expected '%+v' does not equal actual '%+v'", badge, *actual)
+	}
+
+	err = client.DisableBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error disabling badge - %s", err)
+	}
+
+	err = client.DeleteBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error deleting badge - %s", err)
+	}
+
+	hasAccess, err := client.HasAccess("ffffffff")
+	if err != nil || hasAccess {
+		t.Errorf("expected a badge that does not exist to be denied access but got '%t' and '%v'", hasAccess, err)
+	}
+}
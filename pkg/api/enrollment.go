@@ -0,0 +1,120 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+)
+
+const (
+	// ErrInvalidEnrollmentTimeout is returned when enrollment is started with a timeout that can not be parsed or is
+	// not positive.
+	ErrInvalidEnrollmentTimeout = "the enrollment timeout must be a positive duration. Ex \"30s\""
+
+	// EnrollmentPath is the path the enrollment route is served under.
+	EnrollmentPath = Prefix + "/enrollment"
+)
+
+// Enroller is an interface for controlling enrollment mode.
+type Enroller interface {
+	Start(request enrollment.Request) enrollment.Status
+	Cancel() enrollment.Status
+	Status() enrollment.Status
+}
+
+// EnrollmentAPI serves the enrollment route.
+//
+//	GET    /api/v1/enrollment get the state of enrollment
+//	POST   /api/v1/enrollment start waiting for the next unknown badge
+//	DELETE /api/v1/enrollment cancel enrollment
+type EnrollmentAPI struct {
+	enroller Enroller
+}
+
+// EnrollmentRequest is the body of a request to start enrollment.
+type EnrollmentRequest struct {
+	// Type is the type given to the enrolled badge. Defaults to card.
+	Type string `json:"type"`
+
+	// Enabled determines if the enrolled badge is created enabled. Defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Reader restricts enrollment to badges scanned at the named reader. Defaults to any reader.
+	Reader string `json:"reader"`
+
+	// Timeout is how long to wait for a badge. Ex "30s".
+	Timeout string `json:"timeout"`
+}
+
+// NewEnrollmentAPI provides an initialized EnrollmentAPI.
+func NewEnrollmentAPI(enroller Enroller) *EnrollmentAPI {
+	return &EnrollmentAPI{
+		enroller: enroller,
+	}
+}
+
+// Register registers the enrollment route with the provided register function, such as application.HandleAdmin.
+func (e *EnrollmentAPI) Register(handle func(pattern string, handler http.Handler)) {
+	handle(EnrollmentPath, e)
+}
+
+// ServeHTTP routes the request to the matching enrollment operation.
+func (e *EnrollmentAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, e.enroller.Status())
+	case http.MethodPost:
+		e.start(w, r)
+	case http.MethodDelete:
+		writeJSON(w, http.StatusOK, e.enroller.Cancel())
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
+
+func (e *EnrollmentAPI) start(w http.ResponseWriter, r *http.Request) {
+	var request EnrollmentRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	enabled := true
+	if request.Enabled != nil {
+		enabled = *request.Enabled
+	}
+
+	var timeout time.Duration
+	if request.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(request.Timeout)
+		if err != nil || timeout <= 0 {
+			writeError(w, http.StatusBadRequest, ErrInvalidEnrollmentTimeout)
+			return
+		}
+	}
+
+	status := e.enroller.Start(enrollment.Request{
+		BadgeType: request.Type,
+		Enabled:   enabled,
+		Reader:    request.Reader,
+		Timeout:   timeout,
+	})
+
+	writeJSON(w, http.StatusAccepted, status)
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/api"
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+)
+
+type fakeEnroller struct {
+	request enrollment.Request
+	active  bool
+}
+
+func (f *fakeEnroller) Start(request enrollment.Request) enrollment.Status {
+	f.request = request
+	f.active = true
+	return f.Status()
+}
+
+func (f *fakeEnroller) Cancel() enrollment.Status {
+	f.active = false
+	return f.Status()
+}
+
+func (f *fakeEnroller) Status() enrollment.Status {
+	return enrollment.Status{Active: f.active, BadgeType: f.request.BadgeType, Reader: f.request.Reader}
+}
+
+func TestEnrollmentAPI(t *testing.T) {
+	enroller := &fakeEnroller{}
+	server := httptest.NewServer(api.NewEnrollmentAPI(enroller))
+	defer server.Close()
+
+	client, err := api.NewClient(api.ClientConfig{Server: server.URL})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	status, err := client.StartEnrollment(api.EnrollmentRequest{Type: "sticker", Reader: "entry", Timeout: "1m"})
+	if err != nil {
+		t.Fatalf("error starting enrollment - %s", err)
+	}
+
+	if !status.Active || status.Reader != "entry" {
+		t.Errorf("expected enrollment to be active on the entry reader but got '%+v'", status)
+	}
+
+	expected := enrollment.Request{BadgeType: "sticker", Enabled: true, Reader: "entry", Timeout: time.Minute}
+	if enroller.request != expected {
+		t.Errorf("expected '%+v' does not equal actual '%+v'", expected, enroller.request)
+	}
+
+	status, err = client.CancelEnrollment()
+	if err != nil {
+		t.Fatalf("error cancelling enrollment - %s", err)
+	}
+
+	if status.Active {
+		t.Errorf("expected enrollment to be cancelled")
+	}
+
+	response := serve(api.NewEnrollmentAPI(enroller), http.MethodPost, "/api/v1/enrollment", `{"timeout":"soon"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid timeout but got %d", http.StatusBadRequest, response.Code)
+	}
+}
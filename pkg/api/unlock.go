@@ -0,0 +1,135 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/application"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrUnlockReasonRequired is returned when a remote unlock is requested without a reason.
+	ErrUnlockReasonRequired = "a reason is required to unlock the door remotely"
+
+	// ErrInvalidUnlockDuration is returned when a remote unlock is requested with a duration that can not be parsed
+	// or is not positive.
+	ErrInvalidUnlockDuration = "the unlock duration must be a positive duration. Ex \"5s\""
+
+	// DefaultUnlockDuration is how long the door is unlocked for when a duration is not provided.
+	DefaultUnlockDuration = 3 * time.Second
+
+	// UnlockPath is the path the remote unlock route is served under.
+	UnlockPath = Prefix + "/unlock"
+)
+
+// Unlocker is an interface for unlocking the door remotely.
+type Unlocker interface {
+	RemoteUnlock(duration time.Duration, reason string, requester string) error
+}
+
+// UnlockAPI serves the remote unlock route.
+//
+//	POST /api/v1/unlock unlock the door
+type UnlockAPI struct {
+	unlocker    Unlocker
+	maxDuration time.Duration
+}
+
+// UnlockRequest is the body of a request to unlock the door remotely.
+type UnlockRequest struct {
+	// Duration is how long to unlock the door for. Ex "5s". It is capped at the configured maximum.
+	Duration string `json:"duration"`
+
+	// Reason is why the door is being unlocked. Ex "delivery".
+	Reason string `json:"reason"`
+}
+
+// UnlockResponse is the body returned when the door was unlocked remotely.
+type UnlockResponse struct {
+	// Duration is how long the door was unlocked for after the maximum was applied.
+	Duration string `json:"duration"`
+
+	// Reason is the reason given for the unlock.
+	Reason string `json:"reason"`
+}
+
+// NewUnlockAPI provides an initialized UnlockAPI. Requested durations are capped at maxDuration.
+func NewUnlockAPI(unlocker Unlocker, maxDuration time.Duration) *UnlockAPI {
+	return &UnlockAPI{
+		unlocker:    unlocker,
+		maxDuration: maxDuration,
+	}
+}
+
+// Register registers the unlock route with the provided register function, such as application.HandleAdmin.
+func (u *UnlockAPI) Register(handle func(pattern string, handler http.Handler)) {
+	handle(UnlockPath, u)
+}
+
+// ServeHTTP unlocks the door for the requested duration.
+func (u *UnlockAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var request UnlockRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	if request.Reason == "" {
+		writeError(w, http.StatusBadRequest, ErrUnlockReasonRequired)
+		return
+	}
+
+	duration := DefaultUnlockDuration
+	if request.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 {
+			writeError(w, http.StatusBadRequest, ErrInvalidUnlockDuration)
+			return
+		}
+	}
+
+	if duration > u.maxDuration {
+		duration = u.maxDuration
+	}
+
+	requester := application.RequestTokenName(r)
+	if requester == "" {
+		requester = r.RemoteAddr
+	}
+
+	err := u.unlocker.RemoteUnlock(duration, request.Reason, requester)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"requester": requester,
+			"error":     err,
+		}).Error("could not unlock the door remotely")
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UnlockResponse{
+		Duration: duration.String(),
+		Reason:   request.Reason,
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/api"
+)
+
+type fakeUnlocker struct {
+	duration time.Duration
+	reason   string
+	err      error
+}
+
+func (f *fakeUnlocker) RemoteUnlock(duration time.Duration, reason string, requester string) error {
+	f.duration = duration
+	f.reason = reason
+	return f.err
+}
+
+func TestUnlockAPI(t *testing.T) {
+	cases := map[string]struct {
+		body     string
+		status   int
+		duration time.Duration
+	}{
+		"default":  {`{"reason":"delivery"}`, http.StatusOK, api.DefaultUnlockDuration},
+		"duration": {`{"reason":"delivery","duration":"10s"}`, http.StatusOK, 10 * time.Second},
+		"capped":   {`{"reason":"delivery","duration":"1h"}`, http.StatusOK, 30 * time.Second},
+		"reason":   {`{"duration":"10s"}`, http.StatusBadRequest, 0},
+		"invalid":  {`{"reason":"delivery","duration":"-5s"}`, http.StatusBadRequest, 0},
+	}
+
+	for name, c := range cases {
+		unlocker := &fakeUnlocker{}
+		response := serve(api.NewUnlockAPI(unlocker, 30*time.Second), http.MethodPost, "/api/v1/unlock", c.body)
+
+		if response.Code != c.status {
+			t.Errorf("expected status %d for the %s case but got %d", c.status, name, response.Code)
+		}
+
+		if unlocker.duration != c.duration {
+			t.Errorf("expected the %s case to unlock for '%s' but got '%s'", name, c.duration, unlocker.duration)
+		}
+	}
+}
+
+func TestUnlockAPIStrikeError(t *testing.T) {
+	unlocker := &fakeUnlocker{err: errors.New("strike cannot be unlocked after Done() has been called")}
+
+	unlockAPI := api.NewUnlockAPI(unlocker, 30*time.Second)
+	response := serve(unlockAPI, http.MethodPost, "/api/v1/unlock", `{"reason":"delivery"}`)
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d but got %d", http.StatusInternalServerError, response.Code)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrUnauthorized is returned when a request to the admin endpoints does not include a valid token.
+	ErrUnauthorized = "a valid bearer token or api key is required"
+
+	// ErrForbidden is returned when a request to the admin endpoints is made with a token that does not have the
+	// scope required for the request.
+	ErrForbidden = "the token does not have the scope required for this request"
+
+	// ErrInvalidClientCA is returned when the client certificate authority bundle does not contain any certificates.
+	ErrInvalidClientCA = "could not find any certificates in the client ca file"
+
+	// apiKeyHeader is the header an API key can be provided in instead of the Authorization header.
+	apiKeyHeader = "X-API-Key"
+
+	// adminReadHeaderTimeout is how long the admin server waits for the headers of a request so that slow clients can
+	// not hold connections open indefinitely.
+	adminReadHeaderTimeout = 10 * time.Second
+)
+
+// tokenNameKey is the context key the name of the token used to authenticate a request is stored under.
+type tokenNameKey struct{}
+
+var (
+	adminAuthFailureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "open_keyless_admin_auth_failures_total",
+			Help: "The total count of requests to the admin endpoints that were rejected.",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(adminAuthFailureCounter)
+}
+
+// HandleAdmin registers the handler for the given pattern on the admin server. Every request to the handler must be
+// authenticated with a token or, when no tokens are configured, a verified client certificate. Without either, every
+// request is rejected. Handlers must be registered before ServeAdmin is called.
+func (app *Application) HandleAdmin(pattern string, handler http.Handler) {
+	app.admin.Handle(pattern, app.authenticate(handler))
+	app.adminHandlers = true
+}
+
+// AdminHandler returns the handler for the admin server.
+func (app *Application) AdminHandler() http.Handler {
+	return app.admin
+}
+
+// ServeAdmin starts the admin server on the admin interface in the background if metrics or any admin handlers are
+// enabled. An error is returned if the TLS configuration can not be loaded.
+func (app *Application) ServeAdmin() error {
+	if !app.Config.MetricsEnabled && !app.adminHandlers {
+		return nil
+	}
+
+	if app.adminHandlers && !app.Config.AdminAuthenticationEnabled() {
+		log.WithFields(log.Fields{
+			"application": app.AppType,
+			"interface":   app.Config.AdminInterface,
+		}).Error("admin endpoints reject every request, configure admin tokens or a client ca to authenticate them")
+	}
+
+	server := &http.Server{
+		Addr:              app.Config.AdminInterface,
+		Handler:           app.AdminHandler(),
+		ReadHeaderTimeout: adminReadHeaderTimeout,
+	}
+	app.adminServer = server
+
+	cfg := app.Config.AdminTLS
+	if !cfg.Enabled {
+		go app.serveAdmin(server.ListenAndServe)
+		return nil
+	}
+
+	if cfg.SelfSigned {
+		err := ensureSelfSignedCert(cfg.CertFile, cfg.KeyFile, app.Config.AdminInterface)
+		if err != nil {
+			return err
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = tlsConfig
+
+	go app.serveAdmin(func() error {
+		return server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+	})
+	return nil
+}
+
+// ShutdownAdmin gracefully stops the admin server if it was started, waiting for in flight requests to finish until the
+// provided context is done.
+func (app *Application) ShutdownAdmin(ctx context.Context) error {
+	if app.adminServer == nil {
+		return nil
+	}
+
+	return app.adminServer.Shutdown(ctx)
+}
+
+func (app *Application) serveAdmin(serve func() error) {
+	err := serve()
+	if err != nil && err != http.ErrServerClosed {
+		log.WithFields(log.Fields{
+			"application": app.AppType,
+			"interface":   app.Config.AdminInterface,
+			"error":       err,
+		}).Error("admin server stopped")
+	}
+}
+
+// authenticate requires every request to include a configured token with a scope that allows the request. When no
+// tokens are configured, a client certificate verified against the client ca is accepted with the admin scope instead.
+func (app *Application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := app.findToken(requestToken(r))
+		if !ok && len(app.Config.AdminTokens) == 0 {
+			token, ok = clientCertificateToken(r)
+		}
+
+		if !ok {
+			adminAuthFailureCounter.WithLabelValues("unauthorized").Inc()
+			log.WithFields(log.Fields{
+				"application": app.AppType,
+				"remote":      r.RemoteAddr,
+				"path":        r.URL.Path,
+			}).Warn("rejected admin request without a valid token")
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAdminError(w, http.StatusUnauthorized, ErrUnauthorized)
+			return
+		}
+
+		if !allowedByScope(token.Scope, r.Method) {
+			adminAuthFailureCounter.WithLabelValues("forbidden").Inc()
+			log.WithFields(log.Fields{
+				"application": app.AppType,
+				"remote":      r.RemoteAddr,
+				"path":        r.URL.Path,
+				"token":       token.Name,
+			}).Warn("rejected admin request outside of the token scope")
+
+			writeAdminError(w, http.StatusForbidden, ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenNameKey{}, token.Name)))
+	})
+}
+
+// RequestTokenName returns the name of the token the request was authenticated with, the common name of the client
+// certificate when it was authenticated with one, or an empty string if the request was not authenticated.
+func RequestTokenName(r *http.Request) string {
+	name, _ := r.Context().Value(tokenNameKey{}).(string)
+	return name
+}
+
+// findToken returns the configured token matching the provided value. Every token is compared in constant time so that
+// the comparison does not leak how much of a token was guessed correctly.
+func (app *Application) findToken(value string) (Token, bool) {
+	if value == "" {
+		return Token{}, false
+	}
+
+	match := Token{}
+	found := false
+	for _, token := range app.Config.AdminTokens {
+		if subtle.ConstantTimeCompare([]byte(token.Token), []byte(value)) == 1 {
+			match = token
+			found = true
+		}
+	}
+
+	return match, found
+}
+
+// clientCertificateToken returns a token with the admin scope named after the client certificate of the request if the
+// certificate was verified during the TLS handshake. Certificates are only verified when a client ca is configured.
+func clientCertificateToken(r *http.Request) (Token, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Token{}, false
+	}
+
+	return Token{
+		Name:  r.TLS.VerifiedChains[0][0].Subject.CommonName,
+		Scope: ScopeAdmin,
+	}, true
+}
+
+func requestToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+
+	return r.Header.Get(apiKeyHeader)
+}
+
+func allowedByScope(scope string, method string) bool {
+	switch scope {
+	case ScopeAdmin:
+		return true
+	case ScopeReadOnly:
+		return method == http.MethodGet || method == http.MethodHead
+	default:
+		return false
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func newTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	bundle, err := ioutil.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New(ErrInvalidClientCA)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package application_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/application"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAdminHandlerAuthentication(t *testing.T) {
+	app := application.NewApplication(application.Config{
+		LogLevel: logrus.WarnLevel,
+		AdminTokens: []application.Token{
+			{Name: "onboarding", Token: "s3cr3t", Scope: application.ScopeAdmin},
+			{Name: "dashboard", Token: "r34d", Scope: application.ScopeReadOnly},
+		},
+	}, application.OpenKeylessController)
+
+	app.HandleAdmin("/api/v1/badges", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		method   string
+		header   string
+		value    string
+		expected int
+	}{
+		{http.MethodGet, "", "", http.StatusUnauthorized},
+		{http.MethodGet, "Authorization", "Bearer wrong", http.StatusUnauthorized},
+		{http.MethodGet, "Authorization", "Bearer r34d", http.StatusOK},
+		{http.MethodPost, "Authorization", "Bearer r34d", http.StatusForbidden},
+		{http.MethodPost, "Authorization", "Bearer s3cr3t", http.StatusOK},
+		{http.MethodDelete, "X-API-Key", "s3cr3t", http.StatusOK},
+		{http.MethodDelete, "X-API-Key", "r34d", http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, "/api/v1/badges", nil)
+		if c.header != "" {
+			request.Header.Set(c.header, c.value)
+		}
+
+		response := httptest.NewRecorder()
+		app.AdminHandler().ServeHTTP(response, request)
+
+		if response.Code != c.expected {
+			t.Errorf("expected status %d for %s with '%s: %s' but got %d", c.expected, c.method, c.header, c.value,
+				response.Code)
+		}
+	}
+}
+
+func TestAdminHandlerWithoutTokens(t *testing.T) {
+	app := application.NewApplication(application.Config{
+		LogLevel:       logrus.WarnLevel,
+		MetricsEnabled: true,
+	}, application.OpenKeylessController)
+
+	app.HandleAdmin("/api/v1/unlock", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	response := httptest.NewRecorder()
+	app.AdminHandler().ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/api/v1/unlock", nil))
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without tokens but got %d", http.StatusUnauthorized, response.Code)
+	}
+
+	response = httptest.NewRecorder()
+	app.AdminHandler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if response.Code != http.StatusOK {
+		t.Errorf("expected metrics to be served without tokens but got %d", response.Code)
+	}
+}
+
+func TestAdminHandlerClientCertificate(t *testing.T) {
+	app := application.NewApplication(application.Config{
+		LogLevel: logrus.WarnLevel,
+	}, application.OpenKeylessController)
+
+	var name string
+	app.HandleAdmin("/api/v1/unlock", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name = application.RequestTokenName(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// The TLS handshake only fills in the verified chains when the certificate was verified against the client ca.
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/unlock", nil)
+	request.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "front-desk"}}}},
+	}
+
+	response := httptest.NewRecorder()
+	app.AdminHandler().ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("expected status %d with a verified client certificate but got %d", http.StatusOK, response.Code)
+	}
+
+	if name != "front-desk" {
+		t.Errorf("expected the request to be named after the certificate but got '%s'", name)
+	}
+}
+
+func TestServeAdminSelfSigned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	certFile := filepath.Join(dir, "tls", "admin.crt")
+	app := application.NewApplication(application.Config{
+		LogLevel:       logrus.WarnLevel,
+		AdminInterface: address,
+		AdminTLS: application.TLSConfig{
+			Enabled:    true,
+			CertFile:   certFile,
+			KeyFile:    filepath.Join(dir, "tls", "admin.key"),
+			SelfSigned: true,
+		},
+		AdminTokens: []application.Token{{Name: "test", Token: "s3cr3t", Scope: application.ScopeReadOnly}},
+	}, application.OpenKeylessController)
+
+	app.HandleAdmin("/ping", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	err = app.ServeAdmin()
+	if err != nil {
+		t.Fatalf("error serving admin endpoints - %s", err)
+	}
+	defer app.ShutdownAdmin(context.Background())
+
+	cert, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("expected a self-signed certificate to be generated - %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(cert) {
+		t.Fatalf("could not parse the generated certificate")
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		Timeout:   time.Second,
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "https://"+address+"/ping", nil)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	request.Header.Set("Authorization", "Bearer s3cr3t")
+
+	for x := 0; x < 20; x++ {
+		var response *http.Response
+		response, err = client.Do(request)
+		if err == nil {
+			response.Body.Close()
+			if response.StatusCode != http.StatusNoContent {
+				t.Errorf("expected status %d but got %d", http.StatusNoContent, response.StatusCode)
+			}
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Errorf("could not make a request to the admin server with the generated certificate - %s", err)
+}
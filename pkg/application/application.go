@@ -29,6 +29,10 @@ import (
 type Application struct {
 	AppType string
 	Config  Config
+
+	admin         *http.ServeMux
+	adminHandlers bool
+	adminServer   *http.Server
 }
 
 // NewApplication provides an instantiated Application object with the provided configuration and type.
@@ -36,6 +40,7 @@ func NewApplication(config Config, appType string) *Application {
 	app := &Application{
 		AppType: appType,
 		Config:  config,
+		admin:   http.NewServeMux(),
 	}
 
 	if config.MetricsEnabled {
@@ -72,9 +77,15 @@ Version %s
 	}
 }
 
+// enablePrometheusMetrics serves the metrics endpoint on the admin server. Metrics only require authentication when
+// tokens are configured so that a scraper without a token keeps working on an otherwise unauthenticated admin server.
 func (app *Application) enablePrometheusMetrics() {
-	http.Handle("/metrics", promhttp.Handler())
-	go http.ListenAndServe(app.Config.AdminInterface, nil)
+	if len(app.Config.AdminTokens) == 0 {
+		app.admin.Handle("/metrics", promhttp.Handler())
+		return
+	}
+
+	app.admin.Handle("/metrics", app.authenticate(promhttp.Handler()))
 }
 
 func (app *Application) configureLogging() {
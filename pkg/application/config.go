@@ -28,4 +28,51 @@ type Config struct {
 	// AdminInterface is the interface string for the admin endpoints. Ex "192.168.10.100:8081". The IP address can be
 	// excluded for all interfaces. Ex ":8081".
 	AdminInterface string
+
+	// AdminTLS is used to serve the admin endpoints over TLS.
+	AdminTLS TLSConfig
+
+	// AdminTokens are the bearer tokens or API keys accepted by the admin endpoints. Handlers registered with
+	// HandleAdmin reject every request when neither tokens nor client certificates are configured.
+	AdminTokens []Token
+}
+
+// AdminAuthenticationEnabled returns true if requests to the admin handlers can be authenticated, either with a token
+// or with a client certificate.
+func (c Config) AdminAuthenticationEnabled() bool {
+	return len(c.AdminTokens) > 0 || (c.AdminTLS.Enabled && c.AdminTLS.ClientCAFile != "")
+}
+
+// TLSConfig is a configuration object for serving the admin endpoints over TLS.
+type TLSConfig struct {
+	// Enabled determines if the admin endpoints are served over TLS.
+	Enabled bool
+
+	// CertFile is the path to the PEM encoded certificate.
+	CertFile string
+
+	// KeyFile is the path to the PEM encoded private key.
+	KeyFile string
+
+	// SelfSigned determines if a self-signed certificate and key are generated at CertFile and KeyFile when they do
+	// not exist yet.
+	SelfSigned bool
+
+	// ClientCAFile is the path to a PEM encoded bundle of certificate authorities. If set, clients must present a
+	// certificate signed by one of them. When no admin tokens are configured, the certificate alone authenticates
+	// requests to the admin handlers.
+	ClientCAFile string
+}
+
+// Token is a credential accepted by the admin endpoints either as a bearer token in the Authorization header or in the
+// X-API-Key header.
+type Token struct {
+	// Name identifies the token in logs. Ex "onboarding-script".
+	Name string
+
+	// Token is the secret value of the token.
+	Token string
+
+	// Scope is either ScopeReadOnly or ScopeAdmin.
+	Scope string
 }
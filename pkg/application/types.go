@@ -18,4 +18,10 @@ package application
 const (
 	// OpenKeylessController application type.
 	OpenKeylessController = "open-keyless-controller"
+
+	// ScopeReadOnly allows a token to make GET and HEAD requests to the admin endpoints.
+	ScopeReadOnly = "read-only"
+
+	// ScopeAdmin allows a token to make any request to the admin endpoints.
+	ScopeAdmin = "admin"
 )
@@ -0,0 +1,110 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package audit provides a persistent, append-only record of every access decision made by the controller.
+package audit
+
+import (
+	"time"
+)
+
+const (
+	// DecisionGranted is recorded when a badge was granted access.
+	DecisionGranted = "granted"
+
+	// DecisionDenied is recorded when a badge was denied access.
+	DecisionDenied = "denied"
+
+	// SourceBadge is recorded for decisions made for a badge scan.
+	SourceBadge = "badge"
+
+	// SourceRemoteUnlock is recorded when the door was unlocked remotely through the admin API.
+	SourceRemoteUnlock = "remote unlock"
+
+	// SourceRequestToExit is recorded when the door was unlocked from the inside with the request to exit button or
+	// motion sensor.
+	SourceRequestToExit = "request to exit"
+)
+
+// Log is an interface for recording and querying access decisions.
+type Log interface {
+	Record(entry Entry) error
+	Query(query Query) ([]Entry, error)
+	Done() error
+}
+
+// Entry is a single access decision in the audit log.
+type Entry struct {
+	// Timestamp is when the badge was read or the remote unlock was requested.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Source is what requested access, either SourceBadge, SourceRemoteUnlock or SourceRequestToExit.
+	Source string `json:"source"`
+
+	// Requester identifies who requested a remote unlock. Ex the name of the admin token used.
+	Requester string `json:"requester,omitempty"`
+
+	// BadgeID is the id of the badge that was read.
+	BadgeID string `json:"badgeId"`
+
+	// Reader is the name of the reader the badge was read on.
+	Reader string `json:"reader"`
+
+	// Decision is either DecisionGranted or DecisionDenied.
+	Decision string `json:"decision"`
+
+	// Reason is why access was denied, or the reason given for a remote unlock. It is empty when a badge was granted
+	// access.
+	Reason string `json:"reason,omitempty"`
+
+	// DatastoreLatency is how long the datastore took to answer the lookup for the badge. It is zero when the
+	// datastore was not consulted.
+	DatastoreLatency time.Duration `json:"datastoreLatency"`
+
+	// StrikeActuated is true if the door strike was successfully unlocked.
+	StrikeActuated bool `json:"strikeActuated"`
+}
+
+// Query is used to filter the entries returned from the audit log. Zero values match every entry.
+type Query struct {
+	// From only matches entries at or after this time.
+	From time.Time
+
+	// To only matches entries before this time.
+	To time.Time
+
+	// BadgeID only matches entries for this badge.
+	BadgeID string
+
+	// Limit is the maximum number of entries returned. When more entries match, the most recent are returned.
+	Limit int
+}
+
+// Matches returns true if the provided entry matches the query.
+func (q Query) Matches(entry Entry) bool {
+	if !q.From.IsZero() && entry.Timestamp.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && !entry.Timestamp.Before(q.To) {
+		return false
+	}
+
+	if q.BadgeID != "" && entry.BadgeID != q.BadgeID {
+		return false
+	}
+
+	return true
+}
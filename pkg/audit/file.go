@@ -0,0 +1,476 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxSize is the size in bytes the current audit file is allowed to reach before it is rotated when one is
+	// not configured.
+	DefaultMaxSize = 10 * 1024 * 1024
+
+	// DefaultMaxFiles is the number of rotated audit files that are kept when one is not configured.
+	DefaultMaxFiles = 10
+
+	// DefaultMaxAge is how long rotated audit files are kept when one is not configured.
+	DefaultMaxAge = 90 * 24 * time.Hour
+
+	currentFileName   = "audit.log"
+	rotatedFilePrefix = "audit-"
+	rotatedFileSuffix = ".log"
+	rotatedTimeLayout = "20060102T150405.000000000Z"
+
+	// maxEntrySize is the largest line that will be read from an audit file.
+	maxEntrySize = 1024 * 1024
+)
+
+var (
+	entriesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "open_keyless_audit_entries_total",
+			Help: "The total count of access decisions written to the audit log.",
+		},
+		[]string{"decision"},
+	)
+	writeErrorCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_audit_write_errors_total",
+			Help: "The total count of access decisions that could not be written to the audit log.",
+		},
+	)
+	rotationCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_audit_rotations_total",
+			Help: "The total count of audit file rotations.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(entriesCounter)
+	prometheus.MustRegister(writeErrorCounter)
+	prometheus.MustRegister(rotationCounter)
+}
+
+// FileLog implements the Log interface with a directory of files. Entries are appended to the current file as one JSON
+// object per line. Once the current file reaches the max size, it is rotated to a file named after the time of the
+// rotation. Rotated files beyond the max number of files or older than the max age are removed.
+type FileLog struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	maxAge   time.Duration
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+}
+
+// FileConfig is a configuration struct for a FileLog.
+type FileConfig struct {
+	// Dir is the directory the audit files are stored in. It is created if it does not exist.
+	Dir string
+
+	// MaxSize is the size in bytes the current audit file is allowed to reach before it is rotated.
+	MaxSize int64
+
+	// MaxFiles is the number of rotated audit files that are kept.
+	MaxFiles int
+
+	// MaxAge is how long rotated audit files are kept.
+	MaxAge time.Duration
+}
+
+// NewFileLog provides an initialized FileLog using the provided configuration. Be sure to call Done when you are done
+// with the log to clean up.
+func NewFileLog(cfg FileConfig) (*FileLog, error) {
+	l := &FileLog{
+		dir:      cfg.Dir,
+		maxSize:  cfg.MaxSize,
+		maxFiles: cfg.MaxFiles,
+		maxAge:   cfg.MaxAge,
+	}
+
+	if l.maxSize <= 0 {
+		l.maxSize = DefaultMaxSize
+	}
+
+	if l.maxFiles <= 0 {
+		l.maxFiles = DefaultMaxFiles
+	}
+
+	if l.maxAge <= 0 {
+		l.maxAge = DefaultMaxAge
+	}
+
+	err := os.MkdirAll(l.dir, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.prune()
+	if err != nil {
+		l.file.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Record appends the provided entry to the audit log, rotating the current file first if the entry would take it past
+// the max size.
+func (l *FileLog) Record(entry Entry) error {
+	err := l.record(entry)
+	if err != nil {
+		writeErrorCounter.Inc()
+		return err
+	}
+
+	entriesCounter.WithLabelValues(entry.Decision).Inc()
+	return nil
+}
+
+// Query returns the entries in the audit log that match the provided query, oldest first. The files are read without
+// holding the lock so that a large query does not block Record.
+func (l *FileLog) Query(query Query) ([]Entry, error) {
+	paths, current, size, err := l.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, path := range paths {
+		// Every entry in a rotated file was written before the file was rotated, so files rotated before the start of
+		// the query can be skipped without being read.
+		rotatedAt, _ := parseRotatedFileName(filepath.Base(path))
+		if !query.From.IsZero() && rotatedAt.Before(query.From) {
+			continue
+		}
+
+		entries, err = readFileEntries(path, query, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if current != nil {
+		defer current.Close()
+
+		entries, err = readEntries(l.currentPath(), io.LimitReader(current, size), query, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[len(entries)-query.Limit:]
+	}
+
+	return entries, nil
+}
+
+// Done flushes the current audit file to disk and closes it.
+func (l *FileLog) Done() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Sync()
+	closeErr := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// snapshot flushes the current file and returns the rotated files along with a handle to the current file and its size.
+// The handle keeps reading the same file if it is rotated before the query reads it, and the size excludes entries
+// recorded after the snapshot. The handle is nil if the current file does not exist.
+func (l *FileLog) snapshot() ([]string, *os.File, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		err := l.file.Sync()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	paths, err := l.rotatedFiles()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// The current file may have been removed since it was opened, in which case it is recreated on the next rotation.
+	current, err := os.Open(l.currentPath())
+	if os.IsNotExist(err) {
+		return paths, nil, 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return paths, current, l.size, nil
+}
+
+func (l *FileLog) record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		// A failed rotation is retried on the next entry. The entry is still written to the current file so that
+		// access decisions are not lost while the directory can not be written to.
+		err = l.rotate()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"dir":   l.dir,
+				"error": err,
+			}).Error("could not rotate the audit file")
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	return l.file.Sync()
+}
+
+// open opens the current file, creating it if it does not exist. The previously open file is only closed once the
+// current file has been opened, so that the log stays writable when opening fails. The caller must hold the lock.
+func (l *FileLog) open() error {
+	file, err := os.OpenFile(l.currentPath(), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	size, err := terminate(file, info.Size())
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if l.file != nil {
+		err = l.file.Close()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"dir":   l.dir,
+				"error": err,
+			}).Warn("could not close the previous audit file")
+		}
+	}
+
+	l.file = file
+	l.size = size
+	return nil
+}
+
+// terminate ends a partially written last line, such as one left behind when the controller lost power, so that it does
+// not corrupt the next entry. It returns the size of the file after the line is ended.
+func terminate(file *os.File, size int64) (int64, error) {
+	if size == 0 {
+		return size, nil
+	}
+
+	last := make([]byte, 1)
+	_, err := file.ReadAt(last, size-1)
+	if err != nil {
+		return size, err
+	}
+
+	if last[0] == '\n' {
+		return size, nil
+	}
+
+	n, err := file.Write([]byte{'\n'})
+	return size + int64(n), err
+}
+
+// rotate moves the current file aside and opens a new one. If the current file can not be moved, it is reopened instead
+// so that entries keep being written to the current path, recreating it if it was removed. The caller must hold the
+// lock.
+func (l *FileLog) rotate() error {
+	name := rotatedFilePrefix + time.Now().UTC().Format(rotatedTimeLayout) + rotatedFileSuffix
+	err := os.Rename(l.currentPath(), filepath.Join(l.dir, name))
+	if err != nil {
+		openErr := l.open()
+		if openErr != nil {
+			log.WithFields(log.Fields{
+				"dir":   l.dir,
+				"error": openErr,
+			}).Error("could not reopen the audit file")
+		}
+
+		return err
+	}
+
+	err = l.open()
+	if err != nil {
+		return err
+	}
+
+	rotationCounter.Inc()
+
+	err = l.prune()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"dir":   l.dir,
+			"error": err,
+		}).Error("could not remove expired audit files")
+	}
+
+	return nil
+}
+
+// prune removes rotated files beyond the max number of files or older than the max age.
+func (l *FileLog) prune() error {
+	paths, err := l.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-l.maxAge)
+	for i, path := range paths {
+		rotatedAt, _ := parseRotatedFileName(filepath.Base(path))
+		if i >= len(paths)-l.maxFiles && !rotatedAt.Before(cutoff) {
+			continue
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotatedFiles returns the paths of the rotated files, oldest first.
+func (l *FileLog) rotatedFiles() ([]string, error) {
+	infos, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, info := range infos {
+		if _, ok := parseRotatedFileName(info.Name()); !ok {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(l.dir, info.Name()))
+	}
+
+	// The rotation time is formatted so that lexical order is chronological order.
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (l *FileLog) currentPath() string {
+	return filepath.Join(l.dir, currentFileName)
+}
+
+func parseRotatedFileName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, rotatedFilePrefix) || !strings.HasSuffix(name, rotatedFileSuffix) {
+		return time.Time{}, false
+	}
+
+	value := strings.TrimSuffix(strings.TrimPrefix(name, rotatedFilePrefix), rotatedFileSuffix)
+	rotatedAt, err := time.Parse(rotatedTimeLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return rotatedAt, true
+}
+
+// readFileEntries appends the entries in the file at path that match the query to entries. A file that no longer
+// exists, such as a rotated file that was removed after the query started, has no entries.
+func readFileEntries(path string, query Query, entries []Entry) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	return readEntries(path, file, query, entries)
+}
+
+// readEntries appends the entries read from r that match the query to entries. Lines that can not be decoded, such as
+// a line that was partially written when the controller lost power, are skipped.
+func readEntries(path string, r io.Reader, query Query, entries []Entry) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxEntrySize)
+
+	for scanner.Scan() {
+		var entry Entry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"path":  path,
+				"error": err,
+			}).Warn("skipping malformed audit entry")
+			continue
+		}
+
+		if query.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
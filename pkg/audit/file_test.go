@@ -0,0 +1,210 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package audit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/audit"
+)
+
+func TestFileLogQuery(t *testing.T) {
+	l, dir := givenFileLog(t, audit.FileConfig{})
+	defer os.RemoveAll(dir)
+	defer l.Done()
+
+	start := time.Date(2021, time.December, 20, 9, 0, 0, 0, time.UTC)
+	for x := 0; x < 6; x++ {
+		badgeID := "8604de7d"
+		if x%2 == 1 {
+			badgeID = "04a1b2c3"
+		}
+
+		err := l.Record(audit.Entry{
+			Timestamp: start.Add(time.Duration(x) * time.Hour),
+			BadgeID:   badgeID,
+			Reader:    "entry",
+			Decision:  audit.DecisionGranted,
+		})
+		if err != nil {
+			t.Fatalf("error setting up test - %s", err)
+		}
+	}
+
+	cases := map[string]struct {
+		query    audit.Query
+		expected int
+	}{
+		"everything": {audit.Query{}, 6},
+		"badge":      {audit.Query{BadgeID: "04a1b2c3"}, 3},
+		"from":       {audit.Query{From: start.Add(4 * time.Hour)}, 2},
+		"range":      {audit.Query{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)}, 2},
+		"limit":      {audit.Query{Limit: 4}, 4},
+	}
+
+	for name, c := range cases {
+		entries, err := l.Query(c.query)
+		if err != nil {
+			t.Fatalf("error querying audit log - %s", err)
+		}
+
+		if len(entries) != c.expected {
+			t.Errorf("expected %d entries for the %s query but got %d", c.expected, name, len(entries))
+		}
+	}
+
+	entries, err := l.Query(audit.Query{Limit: 1})
+	if err != nil {
+		t.Fatalf("error querying audit log - %s", err)
+	}
+
+	if !entries[0].Timestamp.Equal(start.Add(5 * time.Hour)) {
+		t.Errorf("expected a limited query to return the most recent entries but got '%+v'", entries[0])
+	}
+}
+
+func TestFileLogRotation(t *testing.T) {
+	l, dir := givenFileLog(t, audit.FileConfig{MaxSize: 200, MaxFiles: 2})
+	defer os.RemoveAll(dir)
+	defer l.Done()
+
+	for x := 0; x < 20; x++ {
+		err := l.Record(audit.Entry{
+			Timestamp: time.Now(),
+			BadgeID:   "8604de7d",
+			Reader:    "entry",
+			Decision:  audit.DecisionDenied,
+			Reason:    "badge disabled",
+		})
+		if err != nil {
+			t.Fatalf("error recording entry - %s", err)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("error reading audit directory - %s", err)
+	}
+
+	if len(infos) != 3 {
+		t.Errorf("expected the current file and 2 rotated files but found %d files", len(infos))
+	}
+
+	for _, info := range infos {
+		if info.Size() > 200 {
+			t.Errorf("expected '%s' to be rotated before exceeding the max size but it is %d bytes", info.Name(),
+				info.Size())
+		}
+	}
+}
+
+func TestFileLogRotationFailure(t *testing.T) {
+	l, dir := givenFileLog(t, audit.FileConfig{MaxSize: 200})
+	defer os.RemoveAll(dir)
+	defer l.Done()
+
+	entry := audit.Entry{
+		Timestamp: time.Now(),
+		BadgeID:   "8604de7d",
+		Reader:    "entry",
+		Decision:  audit.DecisionDenied,
+		Reason:    "badge disabled",
+	}
+
+	err := l.Record(entry)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	// Removing the current file makes the rename in the next rotation fail.
+	err = os.Remove(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	for x := 0; x < 5; x++ {
+		err = l.Record(entry)
+		if err != nil {
+			t.Fatalf("error recording entry after a failed rotation - %s", err)
+		}
+	}
+
+	entries, err := l.Query(audit.Query{})
+	if err != nil {
+		t.Fatalf("error querying audit log - %s", err)
+	}
+
+	if len(entries) != 5 {
+		t.Errorf("expected the entries recorded after the failed rotation but got %d entries", len(entries))
+	}
+}
+
+func TestFileLogMalformedEntry(t *testing.T) {
+	l, dir := givenFileLog(t, audit.FileConfig{})
+	defer os.RemoveAll(dir)
+
+	err := l.Record(audit.Entry{Timestamp: time.Now(), BadgeID: "8604de7d", Decision: audit.DecisionGranted})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	l.Done()
+
+	file, err := os.OpenFile(filepath.Join(dir, "audit.log"), os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	file.WriteString(`{"timestamp":"2021-`)
+	file.Close()
+
+	l, err = audit.NewFileLog(audit.FileConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("error reopening audit log - %s", err)
+	}
+	defer l.Done()
+
+	err = l.Record(audit.Entry{Timestamp: time.Now(), BadgeID: "04a1b2c3", Decision: audit.DecisionDenied})
+	if err != nil {
+		t.Fatalf("error recording entry - %s", err)
+	}
+
+	entries, err := l.Query(audit.Query{})
+	if err != nil {
+		t.Fatalf("error querying audit log - %s", err)
+	}
+
+	if len(entries) != 2 {
+		t.Errorf("expected only the partially written entry to be skipped but got %d entries", len(entries))
+	}
+}
+
+func givenFileLog(t *testing.T, cfg audit.FileConfig) (*audit.FileLog, string) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	cfg.Dir = dir
+	l, err := audit.NewFileLog(cfg)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	return l, dir
+}
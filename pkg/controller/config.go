@@ -16,8 +16,23 @@
 package controller
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/betterengineering/open-keyless/pkg/application"
+	"github.com/betterengineering/open-keyless/pkg/audit"
 	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+	"github.com/betterengineering/open-keyless/pkg/door"
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+	"github.com/betterengineering/open-keyless/pkg/feedback"
+	"github.com/betterengineering/open-keyless/pkg/scanner"
+	"github.com/betterengineering/open-keyless/pkg/schedule"
+	"github.com/betterengineering/open-keyless/pkg/strike"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -28,20 +43,198 @@ const (
 
 	// ErrAirtableBaseIDNotFound is returned when the Airtable Base ID is not found in the config.
 	ErrAirtableBaseIDNotFound = "could not find the required airtable base ID in the config"
+
+	// ErrTextFilePathNotFound is returned when the text file path is not found in the config.
+	ErrTextFilePathNotFound = "could not find the required text file path in the config"
+
+	// ErrDoorSensorPinNotFound is returned when the door sensor is enabled without a GPIO pin.
+	ErrDoorSensorPinNotFound = "could not find the required door sensor pin in the config"
+
+	// ErrUnknownFeedbackSignal is returned when a feedback pattern is configured for a signal that does not exist.
+	ErrUnknownFeedbackSignal = "a feedback pattern is configured for an unknown signal"
+
+	// ErrRexPinNotFound is returned when the request to exit input is enabled without a GPIO pin.
+	ErrRexPinNotFound = "could not find the required request to exit pin in the config"
+
+	// ErrAdminTLSCertNotFound is returned when TLS is enabled for the admin interface without a cert and key file.
+	ErrAdminTLSCertNotFound = "could not find the required admin tls cert and key files in the config"
+
+	// ErrAdminTokenEmpty is returned when an admin token is configured without a value.
+	ErrAdminTokenEmpty = "an admin token is configured without a value"
+
+	// ErrInvalidAdminTokenScope is returned when an admin token is configured with an unsupported scope.
+	ErrInvalidAdminTokenScope = "an admin token is configured with an unsupported scope, expected read-only or admin"
+
+	// ErrUnsupportedDatastoreBackend is returned when the configured datastore backend is not supported.
+	ErrUnsupportedDatastoreBackend = "the configured datastore backend is not supported"
+
+	// DatastoreBackendTextFile selects the TextFile datastore.
+	DatastoreBackendTextFile = "textfile"
+
+	// DatastoreBackendAirtable selects the Airtable datastore.
+	DatastoreBackendAirtable = "airtable"
+
+	// ErrUnsupportedScannerType is returned when a reader is configured with a scanner type that is not supported.
+	ErrUnsupportedScannerType = "the configured scanner type is not supported"
+
+	// ErrDuplicateReaderName is returned when more than one reader is configured with the same name.
+	ErrDuplicateReaderName = "more than one reader is configured with the same name"
+
+	// ErrInvalidStrikeMode is returned when the strike is configured with an unsupported mode.
+	ErrInvalidStrikeMode = "the strike is configured with an unsupported mode, expected fail-secure or fail-safe"
+
+	// ErrWiegandPinsNotFound is returned when a Wiegand reader is configured without both data pins.
+	ErrWiegandPinsNotFound = "could not find the required Wiegand data pins in the config"
+
+	// ErrInvalidSecureCredential is returned when a reader's secure credential settings can not be parsed.
+	ErrInvalidSecureCredential = "could not parse the secure credential settings of a reader in the config"
+
+	// ErrInvalidSchedule is returned when a configured access schedule can not be parsed.
+	ErrInvalidSchedule = "could not parse an access schedule in the config"
+
+	// ScannerTypeHid selects the HID scanner for a reader.
+	ScannerTypeHid = "hid"
+
+	// ScannerTypeLibNFC selects the libnfc scanner for a reader.
+	ScannerTypeLibNFC = "libnfc"
+
+	// ScannerTypeWiegand selects the Wiegand scanner for a reader.
+	ScannerTypeWiegand = "wiegand"
+
+	// DefaultDebounceWindow is the debounce window used when one is not configured.
+	DefaultDebounceWindow = time.Second
+
+	// DefaultExpirationWarningDays is how many days before a badge expires that a warning is emitted when one is not
+	// configured.
+	DefaultExpirationWarningDays = 7
+
+	// DefaultAuditPath is the directory the audit log is stored in when one is not configured.
+	DefaultAuditPath = "/var/lib/open-keyless-controller/audit"
+
+	// DefaultRemoteUnlockMaxDuration is the longest the door can be unlocked remotely for when a maximum is not
+	// configured.
+	DefaultRemoteUnlockMaxDuration = 30 * time.Second
+
+	// DefaultEnrollmentMaxTimeout is the longest enrollment can wait for a badge when a maximum is not configured.
+	DefaultEnrollmentMaxTimeout = enrollment.DefaultMaxTimeout
+
+	// DefaultRexUnlockDuration is how long the strike is unlocked for a request to exit when a duration is not
+	// configured.
+	DefaultRexUnlockDuration = door.DefaultRexUnlockDuration
+
+	// DefaultShutdownTimeout is how long the controller waits for its readers, admin server and audit log to shut down
+	// when a timeout is not configured.
+	DefaultShutdownTimeout = 10 * time.Second
+
+	// DefaultReaderName is the name given to the reader when no readers are configured.
+	DefaultReaderName = scanner.DefaultReaderName
 )
 
 // ControllerConfig provides configuration for the Controller application.
 type ControllerConfig struct {
+	// AdminAPIEnabled determines if the badge management API is served on the admin interface.
+	AdminAPIEnabled bool
+
 	// AirtableConfig is a configuration object for the Airtable Datastore.
 	AirtableConfig datastore.AirtableDatastoreConfig
 
 	// ApplicationConfig is used to configure metrics and logging for the controller.
 	ApplicationConfig application.Config
 
+	// AuditConfig is used to configure the audit log of access decisions.
+	AuditConfig audit.FileConfig
+
+	// AuditEnabled determines if every access decision is recorded to the audit log.
+	AuditEnabled bool
+
+	// CacheConfig is used to configure the cache in front of the datastore.
+	CacheConfig datastore.CacheConfig
+
+	// CacheEnabled determines if access decisions are answered from a cached snapshot of the datastore.
+	CacheEnabled bool
+
+	// DatastoreBackend is the datastore implementation used to determine access. Ex "textfile" or "airtable".
+	DatastoreBackend string
+
+	// DebounceWindow is how long repeated reads of the same badge on the same reader are suppressed for. Once the badge
+	// has not been read for this long it is considered removed. Zero disables debouncing.
+	DebounceWindow time.Duration
+
+	// DoorSensorConfig is used to configure the door position sensor.
+	DoorSensorConfig door.Config
+
+	// DoorSensorEnabled determines if the door position sensor is monitored for the door opening, being held open and
+	// being forced open.
+	DoorSensorEnabled bool
+
+	// FeedbackPatterns are the patterns played on the reader buzzers and LEDs for each signal.
+	FeedbackPatterns map[feedback.Signal]feedback.Pattern
+
+	// EnrollmentAdminBadges are the ids of badges that toggle enrollment mode at the reader they are scanned on instead
+	// of unlocking the door.
+	EnrollmentAdminBadges []string
+
+	// EnrollmentMaxTimeout is the longest enrollment can wait for a badge.
+	EnrollmentMaxTimeout time.Duration
+
+	// ExpirationWarningDays is how many days before a badge expires that the controller starts warning about it. Zero
+	// disables the warning.
+	ExpirationWarningDays int
+
+	// Readers are the badge readers attached to the controller.
+	Readers []ReaderConfig
+
+	// RemoteUnlockMaxDuration is the longest the door can be unlocked for through the remote unlock API.
+	RemoteUnlockMaxDuration time.Duration
+
+	// Schedules are the named access schedules that badges may reference, keyed by their lowercased name.
+	Schedules map[string]*schedule.Schedule
+
+	// RexConfig is used to configure the request to exit button or motion sensor.
+	RexConfig door.RexConfig
+
+	// RexEnabled determines if the request to exit input is watched to unlock the door from the inside.
+	RexEnabled bool
+
+	// RexUnlockDuration is how long the strike is unlocked for a request to exit.
+	RexUnlockDuration time.Duration
+
+	// ShutdownTimeout is how long the controller waits for its readers, admin server and audit log to shut down before
+	// giving up.
+	ShutdownTimeout time.Duration
+
+	// StrikeConfig is used to configure the GPIO pin, polarity and mode of the door strike.
+	StrikeConfig strike.Config
+
 	// TextFileConfig is used to configure the TextFile config.
 	TextFileConfig datastore.TextFileConfig
 }
 
+// ReaderConfig is a configuration object for a badge reader attached to the controller.
+type ReaderConfig struct {
+	// Name identifies the reader in logs and metrics. Ex "entry" or "exit".
+	Name string
+
+	// Type is the scanner implementation used to talk to the reader. Ex "hid", "libnfc" or "wiegand".
+	Type string
+
+	// HidConfig is used to configure the reader when Type is "hid".
+	HidConfig scanner.HidScannerConfig
+
+	// LibNFCConfig is used to configure the reader when Type is "libnfc".
+	LibNFCConfig scanner.LibNFCScannerConfig
+
+	// WiegandConfig is used to configure the reader when Type is "wiegand".
+	WiegandConfig scanner.WiegandScannerConfig
+
+	// UIDLengths are the badge UID lengths in bytes accepted by the reader. Ex [7] to reject cloned 4 byte UIDs on a
+	// reader that should only see 7 byte UIDs. An empty list accepts any length.
+	UIDLengths []int
+
+	// Feedback is used to configure the buzzer and LED at the reader.
+	Feedback feedback.GPIOConfig
+}
+
 // NewControllerConfig provides a populated controller config from a configuration file.
 func NewControllerConfig() (ControllerConfig, error) {
 	err := configureViper()
@@ -49,11 +242,25 @@ func NewControllerConfig() (ControllerConfig, error) {
 		return ControllerConfig{}, err
 	}
 
-	airtableConifg := populateAirtableConfig()
+	return populateControllerConfig()
+}
+
+// NewControllerConfigFromFile provides a populated controller config from the configuration file at the provided path
+// rather than searching the default locations.
+func NewControllerConfigFromFile(path string) (ControllerConfig, error) {
+	viper.SetConfigFile(path)
+
+	err := viper.ReadInConfig()
 	if err != nil {
 		return ControllerConfig{}, err
 	}
 
+	return populateControllerConfig()
+}
+
+func populateControllerConfig() (ControllerConfig, error) {
+	airtableConifg := populateAirtableConfig()
+
 	applicationConfig, err := populateApplicationConfig()
 	if err != nil {
 		return ControllerConfig{}, err
@@ -61,11 +268,64 @@ func NewControllerConfig() (ControllerConfig, error) {
 
 	textFileConfig := populateTextFileConfig()
 
-	return ControllerConfig{
-		AirtableConfig:    airtableConifg,
-		ApplicationConfig: applicationConfig,
-		TextFileConfig:    textFileConfig,
-	}, nil
+	config := ControllerConfig{
+		AdminAPIEnabled:         viper.GetBool("application.admin.api.enabled"),
+		AirtableConfig:          airtableConifg,
+		ApplicationConfig:       applicationConfig,
+		AuditConfig:             populateAuditConfig(),
+		AuditEnabled:            viper.GetBool("audit.enabled"),
+		CacheConfig:             populateCacheConfig(),
+		CacheEnabled:            viper.GetBool("datastore.cache.enabled"),
+		DatastoreBackend:        populateDatastoreBackend(),
+		DebounceWindow:          populateDebounceWindow(),
+		DoorSensorConfig:        populateDoorSensorConfig(),
+		DoorSensorEnabled:       viper.GetBool("door.sensor.enabled"),
+		EnrollmentAdminBadges:   populateEnrollmentAdminBadges(),
+		EnrollmentMaxTimeout:    populateEnrollmentMaxTimeout(),
+		ExpirationWarningDays:   populateExpirationWarningDays(),
+		RemoteUnlockMaxDuration: populateRemoteUnlockMaxDuration(),
+		RexConfig:               populateRexConfig(),
+		RexEnabled:              viper.GetBool("door.rex.enabled"),
+		RexUnlockDuration:       populateRexUnlockDuration(),
+		ShutdownTimeout:         populateShutdownTimeout(),
+		StrikeConfig:            populateStrikeConfig(),
+		TextFileConfig:          textFileConfig,
+	}
+
+	err = validateDatastoreConfig(config)
+	if err != nil {
+		return ControllerConfig{}, err
+	}
+
+	err = validateStrikeConfig(config.StrikeConfig)
+	if err != nil {
+		return ControllerConfig{}, err
+	}
+
+	if config.DoorSensorEnabled && config.DoorSensorConfig.Pin == "" {
+		return ControllerConfig{}, errors.New(ErrDoorSensorPinNotFound)
+	}
+
+	if config.RexEnabled && config.RexConfig.Pin == "" {
+		return ControllerConfig{}, errors.New(ErrRexPinNotFound)
+	}
+
+	config.FeedbackPatterns, err = populateFeedbackPatterns()
+	if err != nil {
+		return ControllerConfig{}, err
+	}
+
+	config.Readers, err = populateReaderConfigs()
+	if err != nil {
+		return ControllerConfig{}, err
+	}
+
+	config.Schedules, err = populateSchedules()
+	if err != nil {
+		return ControllerConfig{}, err
+	}
+
+	return config, nil
 }
 
 func populateApplicationConfig() (application.Config, error) {
@@ -90,13 +350,64 @@ func populateApplicationConfig() (application.Config, error) {
 		adminInterface = ":8081"
 	}
 
+	adminTLS, err := populateAdminTLSConfig()
+	if err != nil {
+		return application.Config{}, err
+	}
+
+	adminTokens, err := populateAdminTokens()
+	if err != nil {
+		return application.Config{}, err
+	}
+
 	return application.Config{
 		LogLevel:       logLevel,
 		MetricsEnabled: metricsEnabled,
 		AdminInterface: adminInterface,
+		AdminTLS:       adminTLS,
+		AdminTokens:    adminTokens,
 	}, nil
 }
 
+func populateAdminTLSConfig() (application.TLSConfig, error) {
+	cfg := application.TLSConfig{
+		Enabled:      viper.GetBool("application.admin.tls.enabled"),
+		CertFile:     viper.GetString("application.admin.tls.certFile"),
+		KeyFile:      viper.GetString("application.admin.tls.keyFile"),
+		SelfSigned:   viper.GetBool("application.admin.tls.selfSigned"),
+		ClientCAFile: viper.GetString("application.admin.tls.clientCAFile"),
+	}
+
+	if cfg.Enabled && (cfg.CertFile == "" || cfg.KeyFile == "") {
+		return application.TLSConfig{}, errors.New(ErrAdminTLSCertNotFound)
+	}
+
+	return cfg, nil
+}
+
+func populateAdminTokens() ([]application.Token, error) {
+	tokens := []application.Token{}
+
+	err := viper.UnmarshalKey("application.admin.tokens", &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, token := range tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("%s - %s", ErrAdminTokenEmpty, token.Name)
+		}
+
+		scope := strings.ToLower(token.Scope)
+		if scope != application.ScopeReadOnly && scope != application.ScopeAdmin {
+			return nil, fmt.Errorf("%s - %s", ErrInvalidAdminTokenScope, token.Scope)
+		}
+		tokens[i].Scope = scope
+	}
+
+	return tokens, nil
+}
+
 func configureViper() error {
 	viper.SetConfigName("config")
 	viper.AddConfigPath("/etc/open-keyless-controller/")
@@ -113,8 +424,381 @@ func populateAirtableConfig() datastore.AirtableDatastoreConfig {
 	}
 }
 
+func populateAuditConfig() audit.FileConfig {
+	path := viper.GetString("audit.path")
+	if path == "" {
+		path = DefaultAuditPath
+	}
+
+	maxSize := viper.GetInt64("audit.maxSizeMB") * 1024 * 1024
+	if maxSize <= 0 {
+		maxSize = audit.DefaultMaxSize
+	}
+
+	maxFiles := viper.GetInt("audit.maxFiles")
+	if maxFiles <= 0 {
+		maxFiles = audit.DefaultMaxFiles
+	}
+
+	maxAge := viper.GetDuration("audit.maxAge")
+	if maxAge <= 0 {
+		maxAge = audit.DefaultMaxAge
+	}
+
+	return audit.FileConfig{
+		Dir:      path,
+		MaxSize:  maxSize,
+		MaxFiles: maxFiles,
+		MaxAge:   maxAge,
+	}
+}
+
+func populateCacheConfig() datastore.CacheConfig {
+	refreshInterval := viper.GetDuration("datastore.cache.refreshInterval")
+	if refreshInterval <= 0 {
+		refreshInterval = datastore.DefaultCacheRefreshInterval
+	}
+
+	return datastore.CacheConfig{
+		Path:            viper.GetString("datastore.cache.path"),
+		RefreshInterval: refreshInterval,
+	}
+}
+
 func populateTextFileConfig() datastore.TextFileConfig {
 	return datastore.TextFileConfig{
 		Path: viper.GetString("datastore.textFile.path"),
 	}
 }
+
+func populateDatastoreBackend() string {
+	backend := strings.ToLower(viper.GetString("datastore.backend"))
+	if backend == "" {
+		backend = DatastoreBackendTextFile
+	}
+
+	return backend
+}
+
+func validateDatastoreConfig(config ControllerConfig) error {
+	switch config.DatastoreBackend {
+	case DatastoreBackendTextFile:
+		if config.TextFileConfig.Path == "" {
+			return errors.New(ErrTextFilePathNotFound)
+		}
+	case DatastoreBackendAirtable:
+		if config.AirtableConfig.Key == "" {
+			return errors.New(ErrAirtableAPIKeyNotFound)
+		}
+
+		if config.AirtableConfig.BaseID == "" {
+			return errors.New(ErrAirtableBaseIDNotFound)
+		}
+	default:
+		return fmt.Errorf("%s - %s", ErrUnsupportedDatastoreBackend, config.DatastoreBackend)
+	}
+
+	return nil
+}
+
+func populateDebounceWindow() time.Duration {
+	if !viper.IsSet("debounce.window") {
+		return DefaultDebounceWindow
+	}
+
+	return viper.GetDuration("debounce.window")
+}
+
+func populateDoorSensorConfig() door.Config {
+	return door.Config{
+		Pin:               viper.GetString("door.sensor.pin"),
+		ActiveLow:         viper.GetBool("door.sensor.activeLow"),
+		Debounce:          viper.GetDuration("door.sensor.debounce"),
+		HeldOpenThreshold: viper.GetDuration("door.sensor.heldOpenThreshold"),
+	}
+}
+
+func populateRexConfig() door.RexConfig {
+	return door.RexConfig{
+		Pin:       viper.GetString("door.rex.pin"),
+		ActiveLow: viper.GetBool("door.rex.activeLow"),
+		Debounce:  viper.GetDuration("door.rex.debounce"),
+	}
+}
+
+func populateRexUnlockDuration() time.Duration {
+	duration := viper.GetDuration("door.rex.unlockDuration")
+	if duration <= 0 {
+		duration = DefaultRexUnlockDuration
+	}
+
+	return duration
+}
+
+func populateFeedbackPatterns() (map[feedback.Signal]feedback.Pattern, error) {
+	patterns := feedback.DefaultPatterns()
+
+	raw := map[string]feedback.Pattern{}
+	err := viper.UnmarshalKey("feedback.patterns", &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, pattern := range raw {
+		signal := feedback.Signal(strings.ToLower(name))
+		if _, ok := patterns[signal]; !ok {
+			return nil, fmt.Errorf("%s - %s", ErrUnknownFeedbackSignal, name)
+		}
+
+		patterns[signal] = pattern
+	}
+
+	return patterns, nil
+}
+
+func populateEnrollmentAdminBadges() []string {
+	badges := []string{}
+	for _, id := range viper.GetStringSlice("enrollment.adminBadges") {
+		badges = append(badges, strings.ToLower(id))
+	}
+
+	return badges
+}
+
+func populateEnrollmentMaxTimeout() time.Duration {
+	maxTimeout := viper.GetDuration("enrollment.maxTimeout")
+	if maxTimeout <= 0 {
+		maxTimeout = DefaultEnrollmentMaxTimeout
+	}
+
+	return maxTimeout
+}
+
+func populateExpirationWarningDays() int {
+	if !viper.IsSet("expiration.warningDays") {
+		return DefaultExpirationWarningDays
+	}
+
+	return viper.GetInt("expiration.warningDays")
+}
+
+func populateStrikeConfig() strike.Config {
+	return strike.Config{
+		Pin:       viper.GetString("strike.pin"),
+		ActiveLow: viper.GetBool("strike.activeLow"),
+		Mode:      strings.ToLower(viper.GetString("strike.mode")),
+	}
+}
+
+func validateStrikeConfig(config strike.Config) error {
+	switch config.Mode {
+	case "", strike.ModeFailSecure, strike.ModeFailSafe:
+		return nil
+	default:
+		return fmt.Errorf("%s - %s", ErrInvalidStrikeMode, config.Mode)
+	}
+}
+
+func populateShutdownTimeout() time.Duration {
+	timeout := viper.GetDuration("shutdown.timeout")
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	return timeout
+}
+
+func populateRemoteUnlockMaxDuration() time.Duration {
+	maxDuration := viper.GetDuration("application.admin.api.remoteUnlock.maxDuration")
+	if maxDuration <= 0 {
+		maxDuration = DefaultRemoteUnlockMaxDuration
+	}
+
+	return maxDuration
+}
+
+func populateReaderConfigs() ([]ReaderConfig, error) {
+	raw := []struct {
+		Name        string
+		Type        string
+		VendorID    uint16 `mapstructure:"vendorID"`
+		ProductID   uint16 `mapstructure:"productID"`
+		Path        string
+		Connection  string
+		Modulations []string
+		UIDLengths  []int  `mapstructure:"uidLengths"`
+		BuzzerPin   string `mapstructure:"buzzerPin"`
+		LEDPin      string `mapstructure:"ledPin"`
+
+		D0Pin        string        `mapstructure:"d0Pin"`
+		D1Pin        string        `mapstructure:"d1Pin"`
+		FrameTimeout time.Duration `mapstructure:"frameTimeout"`
+
+		SecureCredential secureCredentialConfig `mapstructure:"secureCredential"`
+	}{}
+
+	err := viper.UnmarshalKey("readers", &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) == 0 {
+		return []ReaderConfig{
+			{
+				Name: DefaultReaderName,
+				Type: ScannerTypeHid,
+				HidConfig: scanner.HidScannerConfig{
+					Reader: DefaultReaderName,
+				},
+			},
+		}, nil
+	}
+
+	names := map[string]bool{}
+	readers := []ReaderConfig{}
+	for i, r := range raw {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("reader-%d", i)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("%s - %s", ErrDuplicateReaderName, name)
+		}
+		names[name] = true
+
+		scannerType := strings.ToLower(r.Type)
+		if scannerType == "" {
+			scannerType = ScannerTypeHid
+		}
+
+		reader := ReaderConfig{
+			Name:       name,
+			Type:       scannerType,
+			UIDLengths: r.UIDLengths,
+			Feedback: feedback.GPIOConfig{
+				BuzzerPin: r.BuzzerPin,
+				LEDPin:    r.LEDPin,
+			},
+		}
+
+		switch scannerType {
+		case ScannerTypeHid:
+			reader.HidConfig = scanner.HidScannerConfig{
+				Reader:    name,
+				VendorID:  r.VendorID,
+				ProductID: r.ProductID,
+				Path:      r.Path,
+			}
+		case ScannerTypeLibNFC:
+			reader.LibNFCConfig = scanner.LibNFCScannerConfig{
+				Reader:           name,
+				Connection:       r.Connection,
+				SecureCredential: r.SecureCredential.Enabled,
+			}
+
+			for _, name := range r.Modulations {
+				mod, err := scanner.ParseModulation(name)
+				if err != nil {
+					return nil, err
+				}
+
+				reader.LibNFCConfig.Modulations = append(reader.LibNFCConfig.Modulations, mod)
+			}
+
+			if r.SecureCredential.Enabled {
+				reader.LibNFCConfig.Credential, err = r.SecureCredential.credentialConfig()
+				if err != nil {
+					return nil, fmt.Errorf("%s - %s: %s", ErrInvalidSecureCredential, name, err)
+				}
+			}
+		case ScannerTypeWiegand:
+			if r.D0Pin == "" || r.D1Pin == "" {
+				return nil, fmt.Errorf("%s - %s", ErrWiegandPinsNotFound, name)
+			}
+
+			reader.WiegandConfig = scanner.WiegandScannerConfig{
+				Reader:       name,
+				D0Pin:        r.D0Pin,
+				D1Pin:        r.D1Pin,
+				FrameTimeout: r.FrameTimeout,
+			}
+		default:
+			return nil, fmt.Errorf("%s - %s", ErrUnsupportedScannerType, r.Type)
+		}
+
+		readers = append(readers, reader)
+	}
+
+	return readers, nil
+}
+
+// secureCredentialConfig holds the secure credential settings of a reader as they are written in the config. The
+// application id and keys are hex encoded.
+type secureCredentialConfig struct {
+	Enabled    bool
+	AID        string `mapstructure:"aid"`
+	KeyNumber  byte   `mapstructure:"keyNumber"`
+	Key        string
+	FileNumber byte   `mapstructure:"fileNumber"`
+	SigningKey string `mapstructure:"signingKey"`
+	Diversify  bool
+	SystemID   string `mapstructure:"systemID"`
+}
+
+func (r secureCredentialConfig) credentialConfig() (desfire.CredentialConfig, error) {
+	aid, err := strconv.ParseUint(r.AID, 16, 32)
+	if err != nil {
+		return desfire.CredentialConfig{}, err
+	}
+
+	key, err := hex.DecodeString(r.Key)
+	if err != nil {
+		return desfire.CredentialConfig{}, err
+	}
+
+	signingKey, err := hex.DecodeString(r.SigningKey)
+	if err != nil {
+		return desfire.CredentialConfig{}, err
+	}
+
+	systemID, err := hex.DecodeString(r.SystemID)
+	if err != nil {
+		return desfire.CredentialConfig{}, err
+	}
+
+	cfg := desfire.CredentialConfig{
+		AID:        uint32(aid),
+		KeyNumber:  r.KeyNumber,
+		Key:        key,
+		FileNumber: r.FileNumber,
+		SigningKey: signingKey,
+		Diversify:  r.Diversify,
+		SystemID:   systemID,
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func populateSchedules() (map[string]*schedule.Schedule, error) {
+	raw := map[string]schedule.Config{}
+
+	err := viper.UnmarshalKey("schedules", &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := map[string]*schedule.Schedule{}
+	for name, cfg := range raw {
+		name = strings.ToLower(name)
+
+		s, err := schedule.New(name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s - %s", ErrInvalidSchedule, err)
+		}
+
+		schedules[name] = s
+	}
+
+	return schedules, nil
+}
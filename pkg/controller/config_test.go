@@ -16,14 +16,26 @@
 package controller_test
 
 import (
+	"encoding/hex"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/betterengineering/open-keyless/pkg/application"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 
+	"github.com/betterengineering/open-keyless/pkg/audit"
 	"github.com/betterengineering/open-keyless/pkg/controller"
 	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+	"github.com/betterengineering/open-keyless/pkg/door"
+	"github.com/betterengineering/open-keyless/pkg/feedback"
+	"github.com/betterengineering/open-keyless/pkg/scanner"
+	"github.com/betterengineering/open-keyless/pkg/schedule"
+	"github.com/betterengineering/open-keyless/pkg/strike"
+	"github.com/fuzxxl/nfc/2.0/nfc"
 )
 
 func TestNewControllerConfig(t *testing.T) {
@@ -32,7 +44,23 @@ func TestNewControllerConfig(t *testing.T) {
 		t.Fatalf("could not create controller config - %s", err)
 	}
 
+	cleaning, err := schedule.New("cleaning", schedule.Config{
+		Timezone: "America/Los_Angeles",
+		Windows: []schedule.WindowConfig{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "18:00", End: "22:00"},
+		},
+		Holidays: []string{"2021-12-24", "2021-12-31"},
+	})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	patterns := feedback.DefaultPatterns()
+	patterns[feedback.SignalGranted] = feedback.Pattern{{Tone: 2000, LED: true, Duration: 500 * time.Millisecond}}
+	patterns[feedback.SignalEnrollmentStarted] = feedback.Pattern{{LED: true, Duration: time.Second}}
+
 	expected := controller.ControllerConfig{
+		AdminAPIEnabled: true,
 		AirtableConfig: datastore.AirtableDatastoreConfig{
 			Key:    "foo",
 			BaseID: "bar",
@@ -41,6 +69,105 @@ func TestNewControllerConfig(t *testing.T) {
 			LogLevel:       logrus.WarnLevel,
 			MetricsEnabled: true,
 			AdminInterface: ":9091",
+			AdminTLS: application.TLSConfig{
+				Enabled:      true,
+				CertFile:     "/foo/admin.crt",
+				KeyFile:      "/foo/admin.key",
+				SelfSigned:   true,
+				ClientCAFile: "/foo/clients.pem",
+			},
+			AdminTokens: []application.Token{
+				{Name: "onboarding", Token: "s3cr3t", Scope: application.ScopeAdmin},
+				{Name: "dashboard", Token: "r34d", Scope: application.ScopeReadOnly},
+			},
+		},
+		AuditConfig: audit.FileConfig{
+			Dir:      "/foo/audit",
+			MaxSize:  5 * 1024 * 1024,
+			MaxFiles: 20,
+			MaxAge:   30 * 24 * time.Hour,
+		},
+		AuditEnabled: true,
+		CacheConfig: datastore.CacheConfig{
+			Path:            "/foo/cache.json",
+			RefreshInterval: time.Minute,
+		},
+		CacheEnabled:     true,
+		DatastoreBackend: controller.DatastoreBackendAirtable,
+		DebounceWindow:   2 * time.Second,
+		DoorSensorConfig: door.Config{
+			Pin:               "GPIO20",
+			Debounce:          100 * time.Millisecond,
+			HeldOpenThreshold: time.Minute,
+		},
+		DoorSensorEnabled:     true,
+		EnrollmentAdminBadges: []string{"04a1b2c3d4e5f6"},
+		EnrollmentMaxTimeout:  2 * time.Minute,
+		ExpirationWarningDays: 14,
+		FeedbackPatterns:      patterns,
+		Readers: []controller.ReaderConfig{
+			{
+				Name: "entry",
+				Type: controller.ScannerTypeLibNFC,
+				LibNFCConfig: scanner.LibNFCScannerConfig{
+					Reader:     "entry",
+					Connection: "pn532_uart:/dev/ttyS0",
+					Modulations: []nfc.Modulation{
+						{Type: nfc.ISO14443a, BaudRate: nfc.Nbr106},
+						{Type: nfc.Felica, BaudRate: nfc.Nbr212},
+					},
+					SecureCredential: true,
+					Credential: desfire.CredentialConfig{
+						AID:        0xF51CD0,
+						KeyNumber:  1,
+						Key:        mustDecodeHex("00112233445566778899aabbccddeeff"),
+						FileNumber: 2,
+						SigningKey: mustDecodeHex("ffeeddccbbaa99887766554433221100"),
+						Diversify:  true,
+						SystemID:   mustDecodeHex("4e585020416275"),
+					},
+				},
+				Feedback: feedback.GPIOConfig{
+					BuzzerPin: "12",
+					LEDPin:    "25",
+				},
+				UIDLengths: []int{7},
+			},
+			{
+				Name: "exit",
+				Type: controller.ScannerTypeHid,
+				HidConfig: scanner.HidScannerConfig{
+					Reader:    "exit",
+					VendorID:  0x072f,
+					ProductID: 0x2200,
+				},
+			},
+			{
+				Name: "gate",
+				Type: controller.ScannerTypeWiegand,
+				WiegandConfig: scanner.WiegandScannerConfig{
+					Reader:       "gate",
+					D0Pin:        "GPIO5",
+					D1Pin:        "GPIO6",
+					FrameTimeout: 30 * time.Millisecond,
+				},
+			},
+		},
+		RemoteUnlockMaxDuration: time.Minute,
+		Schedules: map[string]*schedule.Schedule{
+			"cleaning": cleaning,
+		},
+		RexConfig: door.RexConfig{
+			Pin:       "GPIO26",
+			ActiveLow: true,
+		},
+		RexEnabled:        true,
+		RexUnlockDuration: 8 * time.Second,
+		ShutdownTimeout:   20 * time.Second,
+		StrikeConfig: strike.Config{
+			Pin:       "GPIO21",
+			ActiveLow: true,
+			Mode:      strike.ModeFailSafe,
 		},
 		TextFileConfig: datastore.TextFileConfig{
 			Path: "/foo/ids.txt",
@@ -51,3 +178,165 @@ func TestNewControllerConfig(t *testing.T) {
 		t.Errorf("expected '%+v' does not equal actual '%+v'", expected, actual)
 	}
 }
+
+func TestNewControllerConfigMissingDatastoreSettings(t *testing.T) {
+	cases := map[string]string{
+		"datastore.airtable.key":  controller.ErrAirtableAPIKeyNotFound,
+		"datastore.airtable.base": controller.ErrAirtableBaseIDNotFound,
+	}
+
+	for key, expected := range cases {
+		viper.Set(key, "")
+
+		_, err := controller.NewControllerConfig()
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error '%s' but got '%v'", expected, err)
+		}
+
+		viper.Reset()
+	}
+}
+
+func TestNewControllerConfigUnsupportedDatastoreBackend(t *testing.T) {
+	viper.Set("datastore.backend", "foo")
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil {
+		t.Errorf("expected an error for an unsupported datastore backend")
+	}
+}
+
+func TestNewControllerConfigDuplicateReaderName(t *testing.T) {
+	viper.Set("readers", []map[string]interface{}{
+		{"name": "entry", "type": "hid"},
+		{"name": "entry", "type": "libnfc"},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil {
+		t.Errorf("expected an error for duplicate reader names")
+	}
+}
+
+func TestNewControllerConfigInvalidSchedule(t *testing.T) {
+	viper.Set("schedules", map[string]interface{}{
+		"cleaning": map[string]interface{}{
+			"windows": []map[string]interface{}{
+				{"days": []string{"mon"}, "start": "6pm", "end": "22:00"},
+			},
+		},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil {
+		t.Errorf("expected an error for an invalid schedule")
+	}
+}
+
+func TestNewControllerConfigInvalidAdminToken(t *testing.T) {
+	viper.Set("application.admin.tokens", []map[string]interface{}{
+		{"name": "onboarding", "token": "s3cr3t", "scope": "superuser"},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil {
+		t.Errorf("expected an error for an unsupported token scope")
+	}
+}
+
+func TestNewControllerConfigInvalidStrikeMode(t *testing.T) {
+	viper.Set("datastore.textFile.path", "/foo/ids.txt")
+	viper.Set("strike.mode", "fail-deadly")
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil || !strings.HasPrefix(err.Error(), controller.ErrInvalidStrikeMode) {
+		t.Errorf("expected error '%s' but got '%v'", controller.ErrInvalidStrikeMode, err)
+	}
+}
+
+func TestNewControllerConfigUnknownFeedbackSignal(t *testing.T) {
+	viper.Set("datastore.textFile.path", "/foo/ids.txt")
+	viper.Set("feedback.patterns", map[string]interface{}{
+		"celebrate": []map[string]interface{}{{"tone": 2000, "duration": "1s"}},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil || !strings.HasPrefix(err.Error(), controller.ErrUnknownFeedbackSignal) {
+		t.Errorf("expected error '%s' but got '%v'", controller.ErrUnknownFeedbackSignal, err)
+	}
+}
+
+func TestNewControllerConfigInvalidSecureCredential(t *testing.T) {
+	viper.Set("datastore.textFile.path", "/foo/ids.txt")
+	viper.Set("readers", []map[string]interface{}{
+		{
+			"name": "entry",
+			"type": "libnfc",
+			"secureCredential": map[string]interface{}{
+				"enabled": true,
+				"aid":     "F51CD0",
+				"key":     "0011",
+			},
+		},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil || !strings.HasPrefix(err.Error(), controller.ErrInvalidSecureCredential) {
+		t.Errorf("expected error '%s' but got '%v'", controller.ErrInvalidSecureCredential, err)
+	}
+}
+
+func TestNewControllerConfigUnsupportedModulation(t *testing.T) {
+	viper.Set("datastore.textFile.path", "/foo/ids.txt")
+	viper.Set("readers", []map[string]interface{}{
+		{"name": "entry", "type": "libnfc", "modulations": []string{"iso15693"}},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil || err.Error() != scanner.ErrISO15693Unsupported {
+		t.Errorf("expected error '%s' but got '%v'", scanner.ErrISO15693Unsupported, err)
+	}
+}
+
+func TestNewControllerConfigMissingWiegandPins(t *testing.T) {
+	viper.Set("datastore.textFile.path", "/foo/ids.txt")
+	viper.Set("readers", []map[string]interface{}{
+		{"name": "gate", "type": "wiegand", "d0Pin": "GPIO5"},
+	})
+	defer viper.Reset()
+
+	_, err := controller.NewControllerConfig()
+	if err == nil || !strings.HasPrefix(err.Error(), controller.ErrWiegandPinsNotFound) {
+		t.Errorf("expected error '%s' but got '%v'", controller.ErrWiegandPinsNotFound, err)
+	}
+}
+
+func TestNewControllerConfigFromFile(t *testing.T) {
+	defer viper.Reset()
+
+	config, err := controller.NewControllerConfigFromFile("testdata/config.yml")
+	if err != nil {
+		t.Fatalf("could not create controller config - %s", err)
+	}
+
+	if config.DatastoreBackend != controller.DatastoreBackendAirtable {
+		t.Errorf("expected the datastore backend from the config file but got '%s'", config.DatastoreBackend)
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
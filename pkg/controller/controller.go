@@ -17,29 +17,95 @@
 package controller
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/betterengineering/open-keyless/pkg/api"
 	"github.com/betterengineering/open-keyless/pkg/application"
+	"github.com/betterengineering/open-keyless/pkg/audit"
 	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/door"
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+	"github.com/betterengineering/open-keyless/pkg/feedback"
 	"github.com/betterengineering/open-keyless/pkg/scanner"
+	"github.com/betterengineering/open-keyless/pkg/schedule"
 	"github.com/betterengineering/open-keyless/pkg/strike"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// ErrShutdownTimeout is returned when the controller does not shut down within the shutdown timeout.
+	ErrShutdownTimeout = "the controller did not shut down within the shutdown timeout"
+
+	// ErrAdminAPIUnauthenticated is returned when the admin API is enabled without admin tokens or client certificates
+	// to authenticate its requests.
+	ErrAdminAPIUnauthenticated = "the admin api requires admin tokens or a client ca to be configured"
+
+	// DenyReasonUnexpectedUIDLength is recorded when a badge is read with a UID length the reader does not accept.
+	DenyReasonUnexpectedUIDLength = "unexpected uid length"
+
+	// DenyReasonUnknownBadge is recorded when a badge does not exist in the datastore.
+	DenyReasonUnknownBadge = "unknown badge"
+
+	// DenyReasonBadgeDisabled is recorded when a badge exists in the datastore but is not enabled.
+	DenyReasonBadgeDisabled = "badge disabled"
+
+	// DenyReasonDatastoreError is recorded when the datastore could not be reached to look up a badge.
+	DenyReasonDatastoreError = "datastore error"
+
+	// DenyReasonNotYetValid is recorded when a badge is read before its validity period starts.
+	DenyReasonNotYetValid = "not yet valid"
+
+	// DenyReasonExpired is recorded when a badge is read after its validity period ends.
+	DenyReasonExpired = "badge expired"
+
+	// DenyReasonUnknownSchedule is recorded when a badge references a schedule that is not configured.
+	DenyReasonUnknownSchedule = "unknown schedule"
+
+	// DenyReasonOutsideSchedule is recorded when a badge is read outside of the windows allowed by its schedule.
+	DenyReasonOutsideSchedule = "outside schedule"
+
+	// DenyReasonAdminBadge is recorded when an admin badge is read, which starts or cancels enrollment instead of
+	// unlocking the door.
+	DenyReasonAdminBadge = "admin badge"
+
+	// DenyReasonEnrolled is recorded when a badge is read while enrollment is active and is enrolled instead of being
+	// granted access.
+	DenyReasonEnrolled = "enrolled"
+
+	// DenyReasonEnrollmentFailed is recorded when a badge is read while enrollment is active but could not be
+	// enrolled.
+	DenyReasonEnrollmentFailed = "enrollment failed"
+
+	// expirationCheckInterval is how often the datastore is checked for badges that are about to expire.
+	expirationCheckInterval = time.Hour
+)
+
 var (
 	accessDeniedCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "open_keyless_controller_access_denied_total",
 			Help: "The total count of badge scans that were denied access.",
 		},
-		[]string{"badge_id"},
+		[]string{"badge_id", "reader", "reason"},
 	)
 	accessGrantedCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "open_keyless_controller_access_granted_total",
-			Help: "The total count of badge scans that were granted access.",
+			Help: "The total count of badge scans and remote unlocks that were granted access.",
+		},
+		[]string{"badge_id", "reader", "source"},
+	)
+	badgeExpiresInGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "open_keyless_controller_badge_expires_in_seconds",
+			Help: "The number of seconds until an enabled badge that is within the expiration warning period expires.",
 		},
 		[]string{"badge_id"},
 	)
@@ -48,125 +114,769 @@ var (
 func init() {
 	prometheus.MustRegister(accessDeniedCounter)
 	prometheus.MustRegister(accessGrantedCounter)
+	prometheus.MustRegister(badgeExpiresInGauge)
 }
 
 // Controller is the primary struct for Open Keyless controller.
 type Controller struct {
-	datastore   datastore.Datastore
-	scanner     scanner.Scanner
-	application *application.Application
-	strike      strike.Strike
-	ids         chan string
-	errors      chan error
+	datastore       datastore.Datastore
+	scanners        []scanner.Scanner
+	debouncer       *scanner.Debouncer
+	readers         map[string]ReaderConfig
+	schedules       map[string]*schedule.Schedule
+	expiration      time.Duration
+	shutdownTimeout time.Duration
+	auditLog        audit.Log
+	enrollment      *enrollment.Manager
+	feedback        feedback.Feedback
+	feedbacks       []*feedback.GPIOFeedback
+	adminBadges     map[string]bool
+	application     *application.Application
+	strike          strike.Strike
+	door            *door.Monitor
+	rex             *door.Rex
+	rexDuration     time.Duration
+	doorEvents      chan door.Event
+	events          chan scanner.ScanEvent
+	errors          chan error
 }
 
 // NewController provides an initialized Controller with the provided configuration.
 func NewController(config ControllerConfig) (*Controller, error) {
 	app := application.NewApplication(config.ApplicationConfig, application.OpenKeylessController)
 
-	ds, err := datastore.NewTextFile(config.TextFileConfig)
-	if err != nil {
+	// The admin API can unlock the door, so it is never served without authentication.
+	if config.AdminAPIEnabled && !config.ApplicationConfig.AdminAuthenticationEnabled() {
 		log.WithFields(log.Fields{
 			"application": app.AppType,
-			"error":       err,
-		}).Error("could not read text file")
+		}).Error("refusing to serve the admin api without authentication")
+		return nil, errors.New(ErrAdminAPIUnauthenticated)
 	}
 
-	str, err := strike.NewDefaultDoorStrike()
+	ds, err := NewDatastore(config)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"application": app.AppType,
+			"backend":     config.DatastoreBackend,
 			"error":       err,
-		}).Error("could not connect to door strike")
+		}).Error("could not initialize datastore")
 		return nil, err
 	}
 
-	ids := make(chan string, 100)
-	errs := make(chan error, 100)
+	var auditLog audit.Log
+	if config.AuditEnabled {
+		auditLog, err = audit.NewFileLog(config.AuditConfig)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"application": app.AppType,
+				"path":        config.AuditConfig.Dir,
+				"error":       err,
+			}).Error("could not open the audit log")
 
-	scn, err := scanner.NewDefaultHidScanner(ids, errs)
+			datastore.Done(ds)
+			return nil, err
+		}
+	}
+
+	str, err := strike.OpenDoorStrike(config.StrikeConfig)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"application": app.AppType,
+			"pin":         config.StrikeConfig.Pin,
 			"error":       err,
-		}).Error("could not connect to the NFC scanner")
+		}).Error("could not connect to door strike")
+
+		if auditLog != nil {
+			auditLog.Done()
+		}
+		datastore.Done(ds)
+
 		return nil, err
 	}
 
-	return &Controller{
-		datastore:   ds,
-		application: app,
-		scanner:     scn,
-		strike:      str,
-		ids:         ids,
-		errors:      errs,
-	}, nil
+	var monitor *door.Monitor
+	var rex *door.Rex
+	var doorEvents chan door.Event
+	if config.DoorSensorEnabled || config.RexEnabled {
+		doorEvents = make(chan door.Event, 100)
+	}
+
+	if config.DoorSensorEnabled {
+		monitor, err = door.OpenMonitor(config.DoorSensorConfig, doorEvents)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"application": app.AppType,
+				"pin":         config.DoorSensorConfig.Pin,
+				"error":       err,
+			}).Error("could not connect to door sensor")
+
+			str.Done()
+			if auditLog != nil {
+				auditLog.Done()
+			}
+			datastore.Done(ds)
+
+			return nil, err
+		}
+	}
+
+	if config.RexEnabled {
+		rex, err = door.OpenRex(config.RexConfig, doorEvents)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"application": app.AppType,
+				"pin":         config.RexConfig.Pin,
+				"error":       err,
+			}).Error("could not connect to request to exit input")
+
+			str.Done()
+			if auditLog != nil {
+				auditLog.Done()
+			}
+			datastore.Done(ds)
+
+			return nil, err
+		}
+	}
+
+	if rex != nil && monitor != nil {
+		rex.AuthorizeDoor(monitor, config.RexUnlockDuration)
+	}
+
+	scans := make(chan scanner.ScanEvent, 100)
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+
+	scanners := []scanner.Scanner{}
+	readers := map[string]ReaderConfig{}
+	router := feedback.NewRouter()
+	feedbacks := []*feedback.GPIOFeedback{}
+	for _, readerConfig := range config.Readers {
+		scn, err := NewScanner(readerConfig, scans, errs)
+		if err == nil {
+			scanners = append(scanners, scn)
+		} else {
+			log.WithFields(log.Fields{
+				"application": app.AppType,
+				"reader":      readerConfig.Name,
+				"type":        readerConfig.Type,
+				"error":       err,
+			}).Error("could not connect to the NFC scanner")
+		}
+
+		if err == nil && readerConfig.Feedback.Enabled() {
+			var fb *feedback.GPIOFeedback
+			fb, err = feedback.OpenGPIOFeedback(readerConfig.Feedback, config.FeedbackPatterns)
+			if err == nil {
+				feedbacks = append(feedbacks, fb)
+				router.Add(readerConfig.Name, fb)
+			} else {
+				log.WithFields(log.Fields{
+					"application": app.AppType,
+					"reader":      readerConfig.Name,
+					"buzzerPin":   readerConfig.Feedback.BuzzerPin,
+					"ledPin":      readerConfig.Feedback.LEDPin,
+					"error":       err,
+				}).Error("could not connect to the reader feedback")
+			}
+		}
+
+		if err != nil {
+			for _, scn := range scanners {
+				scn.Done()
+			}
+			for _, fb := range feedbacks {
+				fb.Done()
+			}
+			str.Done()
+			if auditLog != nil {
+				auditLog.Done()
+			}
+			datastore.Done(ds)
+
+			return nil, err
+		}
+
+		readers[readerConfig.Name] = readerConfig
+	}
+
+	adminBadges := map[string]bool{}
+	for _, id := range config.EnrollmentAdminBadges {
+		adminBadges[id] = true
+	}
+
+	enroller := enrollment.NewManager(ds, router, enrollment.Config{
+		MaxTimeout: config.EnrollmentMaxTimeout,
+	})
+
+	c := &Controller{
+		datastore:       ds,
+		application:     app,
+		scanners:        scanners,
+		debouncer:       scanner.NewDebouncer(config.DebounceWindow, scans, events),
+		readers:         readers,
+		schedules:       config.Schedules,
+		expiration:      time.Duration(config.ExpirationWarningDays) * 24 * time.Hour,
+		shutdownTimeout: config.ShutdownTimeout,
+		auditLog:        auditLog,
+		enrollment:      enroller,
+		feedback:        router,
+		feedbacks:       feedbacks,
+		adminBadges:     adminBadges,
+		strike:          str,
+		door:            monitor,
+		rex:             rex,
+		rexDuration:     config.RexUnlockDuration,
+		doorEvents:      doorEvents,
+		events:          events,
+		errors:          errs,
+	}
+
+	if config.AdminAPIEnabled {
+		api.NewBadgeAPI(ds).Register(app.HandleAdmin)
+		api.NewUnlockAPI(c, config.RemoteUnlockMaxDuration).Register(app.HandleAdmin)
+		api.NewEnrollmentAPI(enroller).Register(app.HandleAdmin)
+		if auditLog != nil {
+			api.NewAuditAPI(auditLog).Register(app.HandleAdmin)
+		}
+	}
+
+	return c, nil
 }
 
-// Run will run the controller in a blocking fashion.
-func (c *Controller) Run() {
-	defer c.strike.Done()
-	defer c.scanner.Done()
+// Run will run the controller in a blocking fashion until the provided context is cancelled, at which point the strike
+// is locked and the controller is shut down. An error is returned if the controller could not shut down within the
+// shutdown timeout.
+func (c *Controller) Run(ctx context.Context) error {
+	c.debouncer.Start(ctx)
+
+	if c.door != nil {
+		c.door.Start(ctx)
+	}
+
+	if c.rex != nil {
+		c.rex.Start(ctx)
+	}
+
+	for _, scn := range c.scanners {
+		scn.Scan(ctx)
+	}
+
+	err := c.application.ServeAdmin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"interface":   c.application.Config.AdminInterface,
+			"error":       err,
+		}).Error("could not start the admin server")
+	}
 
-	c.scanner.Scan()
 	c.application.PrintBanner()
 
 	log.WithFields(log.Fields{
 		"application": c.application.AppType,
+		"readers":     len(c.scanners),
 	}).Info("scanning for badges")
 
+	expirationTicker := time.NewTicker(expirationCheckInterval)
+	defer expirationTicker.Stop()
+	c.checkExpirations()
+
 	for {
 		select {
-		case id := <-c.ids:
+		case <-ctx.Done():
+			return c.Shutdown()
+		case <-expirationTicker.C:
+			c.checkExpirations()
+		case event := <-c.doorEvents:
+			c.processDoorEvent(event)
+		case event := <-c.events:
+			if event.Removed {
+				c.logEvent(event).Debug("badge removed")
+				continue
+			}
+
+			c.logEvent(event).Debug("found badge id")
+			c.processID(event)
+		case err := <-c.errors:
+			fields := log.Fields{
+				"application": c.application.AppType,
+				"error":       err,
+			}
+			if readerErr, ok := err.(*scanner.ReaderError); ok {
+				fields["reader"] = readerErr.Reader
+			}
+
+			log.WithFields(fields).Error("encountered an error while scanning for badges")
+		}
+	}
+}
+
+// Shutdown locks the strike, then stops the door sensor, the request to exit input, enrollment, the readers, the admin
+// server, the datastore and the audit log. The strike is always locked first so that the door is not left unlocked if
+// the rest of the shutdown does not finish in time. An error is returned if the controller could not shut down within
+// the shutdown timeout.
+func (c *Controller) Shutdown() error {
+	log.WithFields(log.Fields{
+		"application": c.application.AppType,
+		"timeout":     c.shutdownTimeout.String(),
+	}).Info("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.shutdownTimeout)
+	defer cancel()
+
+	done := make(chan bool)
+	go func() {
+		c.strike.Done()
+		if c.door != nil {
+			c.door.Done()
+		}
+		if c.rex != nil {
+			c.rex.Done()
+		}
+		c.enrollment.Done()
+		c.debouncer.Done()
+
+		for _, scn := range c.scanners {
+			err := scn.Done()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"application": c.application.AppType,
+					"error":       err,
+				}).Error("could not close reader")
+			}
+		}
+
+		for _, fb := range c.feedbacks {
+			fb.Done()
+		}
+
+		err := c.application.ShutdownAdmin(ctx)
+		if err != nil {
 			log.WithFields(log.Fields{
 				"application": c.application.AppType,
-				"id":          id,
-			}).Debug("found badge id")
-			c.processID(id)
-		case err := <-c.errors:
+				"error":       err,
+			}).Error("could not shut down the admin server")
+		}
+
+		err = datastore.Done(c.datastore)
+		if err != nil {
 			log.WithFields(log.Fields{
 				"application": c.application.AppType,
 				"error":       err,
-			}).Error("encountered an error while scanning for badges")
+			}).Error("could not stop the datastore")
+		}
+
+		if c.auditLog != nil {
+			err = c.auditLog.Done()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"application": c.application.AppType,
+					"error":       err,
+				}).Error("could not close the audit log")
+			}
 		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+		}).Info("shut down")
+		return nil
+	case <-ctx.Done():
+		return errors.New(ErrShutdownTimeout)
 	}
 }
 
-func (c *Controller) processID(id string) {
-	hasAccess, err := c.datastore.HasAccess(id)
+func (c *Controller) processID(event scanner.ScanEvent) {
+	consumed, reason := c.enroll(event)
+	if consumed {
+		c.recordAudit(event, accessDecision{reason: reason}, false)
+		return
+	}
+
+	decision, err := c.checkAccess(event)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"application": c.application.AppType,
+			"reader":      event.Reader,
 			"error":       err,
-		}).Error("error communicating with airtable")
+		}).Error("error communicating with the datastore")
+
+		decision.reason = DenyReasonDatastoreError
+		c.recordAudit(event, decision, false)
+		c.feedback.Signal(event.Reader, feedback.SignalError)
 		return
 	}
 
-	if hasAccess {
-		c.grantAccess(id)
-		accessGrantedCounter.WithLabelValues(id).Inc()
+	if decision.granted {
+		actuated := c.grantAccess(event)
+		if actuated {
+			c.feedback.Signal(event.Reader, feedback.SignalGranted)
+		} else {
+			c.feedback.Signal(event.Reader, feedback.SignalError)
+		}
+		accessGrantedCounter.WithLabelValues(event.ID, event.Reader, audit.SourceBadge).Inc()
+		c.recordAudit(event, decision, actuated)
 		return
 	}
 
+	c.logEvent(event).WithField("reason", decision.reason).Info("access denied for badge id")
+
+	accessDeniedCounter.WithLabelValues(event.ID, event.Reader, decision.reason).Inc()
+	c.recordAudit(event, decision, false)
+	c.feedback.Signal(event.Reader, feedback.SignalDenied)
+}
+
+// enroll handles admin badge scans and scans captured by enrollment mode. It returns true if the scan was consumed and
+// should not be treated as an access request, along with the reason recorded to the audit log for the scan.
+func (c *Controller) enroll(event scanner.ScanEvent) (bool, string) {
+	if c.adminBadges[event.ID] {
+		if c.enrollment.Active() {
+			c.enrollment.Cancel()
+			return true, DenyReasonAdminBadge
+		}
+
+		c.logEvent(event).Info("admin badge scanned, starting enrollment")
+		c.enrollment.Start(enrollment.Request{
+			BadgeType: enrollment.DefaultBadgeType,
+			Enabled:   true,
+			Reader:    event.Reader,
+		})
+		return true, DenyReasonAdminBadge
+	}
+
+	if !c.acceptsUIDLength(event) {
+		return false, ""
+	}
+
+	consumed, err := c.enrollment.Capture(event.Reader, event.ID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"reader":      event.Reader,
+			"id":          event.ID,
+			"error":       err,
+		}).Error("could not enroll badge")
+	}
+
+	if !consumed {
+		return false, ""
+	}
+
+	if err != nil {
+		return true, DenyReasonEnrollmentFailed
+	}
+
+	return true, DenyReasonEnrolled
+}
+
+// accessDecision is the result of checking a badge scan for access.
+type accessDecision struct {
+	// granted is true if the badge should be granted access.
+	granted bool
+
+	// reason is why access was denied.
+	reason string
+
+	// datastoreLatency is how long the datastore took to look up the badge.
+	datastoreLatency time.Duration
+}
+
+// checkAccess determines if the badge in the event should be granted access.
+func (c *Controller) checkAccess(event scanner.ScanEvent) (accessDecision, error) {
+	if !c.acceptsUIDLength(event) {
+		return accessDecision{reason: DenyReasonUnexpectedUIDLength}, nil
+	}
+
+	lookupStart := time.Now()
+	badge, err := c.datastore.GetBadge(event.ID)
+	decision := accessDecision{datastoreLatency: time.Since(lookupStart)}
+	if err != nil {
+		if err.Error() == datastore.ErrBadgeDoesNotExist {
+			decision.reason = DenyReasonUnknownBadge
+			return decision, nil
+		}
+
+		return decision, err
+	}
+
+	decision.reason = c.denyReason(event, badge)
+	decision.granted = decision.reason == ""
+
+	return decision, nil
+}
+
+// denyReason returns why the badge should be denied access for the event, or an empty string if it should be granted
+// access.
+func (c *Controller) denyReason(event scanner.ScanEvent, badge *datastore.Badge) string {
+	if !badge.Enabled {
+		return DenyReasonBadgeDisabled
+	}
+
+	at := event.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	if badge.ValidFrom != nil && at.Before(*badge.ValidFrom) {
+		return DenyReasonNotYetValid
+	}
+
+	if !badge.ValidAt(at) {
+		return DenyReasonExpired
+	}
+
+	if badge.Schedule == "" {
+		return ""
+	}
+
+	s, ok := c.schedules[strings.ToLower(badge.Schedule)]
+	if !ok {
+		return DenyReasonUnknownSchedule
+	}
+
+	if !s.Allows(at) {
+		return DenyReasonOutsideSchedule
+	}
+
+	return ""
+}
+
+// grantAccess unlocks the strike and returns true if the strike actuated.
+func (c *Controller) grantAccess(event scanner.ScanEvent) bool {
+	c.logEvent(event).Info("allowing access for badge id")
+
+	err := c.unlock(time.Second * 3)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"reader":      event.Reader,
+			"error":       err,
+		}).Error("error unlocking strike for id")
+		return false
+	}
+
+	return true
+}
+
+// recordAudit writes the decision for the badge scan to the audit log.
+func (c *Controller) recordAudit(event scanner.ScanEvent, decision accessDecision, actuated bool) {
+	entry := audit.Entry{
+		Timestamp:        event.Timestamp,
+		Source:           audit.SourceBadge,
+		BadgeID:          event.ID,
+		Reader:           event.Reader,
+		Decision:         audit.DecisionDenied,
+		Reason:           decision.reason,
+		DatastoreLatency: decision.datastoreLatency,
+		StrikeActuated:   actuated,
+	}
+	if decision.granted {
+		entry.Decision = audit.DecisionGranted
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	c.writeAudit(entry)
+}
+
+// writeAudit writes the entry to the audit log if it is enabled. A failure to write to the audit log is logged rather
+// than returned so that it never holds up the door.
+func (c *Controller) writeAudit(entry audit.Entry) {
+	if c.auditLog == nil {
+		return
+	}
+
+	err := c.auditLog.Record(entry)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"source":      entry.Source,
+			"reader":      entry.Reader,
+			"id":          entry.BadgeID,
+			"error":       err,
+		}).Error("could not write access decision to the audit log")
+	}
+}
+
+// RemoteUnlock unlocks the strike for the provided duration on behalf of the requester. The unlock is logged, counted,
+// and recorded to the audit log the same way as a badge scan, with the source set to a remote unlock.
+func (c *Controller) RemoteUnlock(duration time.Duration, reason string, requester string) error {
 	log.WithFields(log.Fields{
 		"application": c.application.AppType,
-		"id":          id,
-	}).Info("access denied for badge id")
+		"requester":   requester,
+		"reason":      reason,
+		"duration":    duration.String(),
+	}).Info("unlocking door remotely")
+
+	err := c.unlock(duration)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"requester":   requester,
+			"error":       err,
+		}).Error("error unlocking strike remotely")
+	} else {
+		accessGrantedCounter.WithLabelValues("", "", audit.SourceRemoteUnlock).Inc()
+	}
+
+	c.writeAudit(audit.Entry{
+		Timestamp:      time.Now(),
+		Source:         audit.SourceRemoteUnlock,
+		Requester:      requester,
+		Decision:       audit.DecisionGranted,
+		Reason:         reason,
+		StrikeActuated: err == nil,
+	})
+
+	return err
+}
+
+// unlock unlocks the strike for the provided duration and allows the door to be opened in that time without raising
+// the forced open alarm.
+func (c *Controller) unlock(duration time.Duration) error {
+	err := c.strike.Unlock(duration)
+	if err != nil {
+		return err
+	}
+
+	if c.door != nil {
+		c.door.Authorize(duration)
+	}
+
+	return nil
+}
+
+// processDoorEvent logs the door event, unlocks the strike for a request to exit, signals alarms at every reader and
+// relocks the strike as soon as the door closes.
+func (c *Controller) processDoorEvent(event door.Event) {
+	fields := log.Fields{
+		"application": c.application.AppType,
+		"event":       event.Type,
+	}
+	if event.OpenFor > 0 {
+		fields["openFor"] = event.OpenFor.String()
+	}
 
-	accessDeniedCounter.WithLabelValues(id).Inc()
+	switch event.Type {
+	case door.EventRequestToExit:
+		c.requestToExit(event)
+	case door.AlarmForcedOpen:
+		log.WithFields(fields).Error("door forced open")
+		c.feedback.Signal("", feedback.SignalAlarm)
+	case door.AlarmHeldOpen:
+		log.WithFields(fields).Warn("door held open")
+		c.feedback.Signal("", feedback.SignalAlarm)
+	case door.EventOpened:
+		log.WithFields(fields).Info("door opened")
+	case door.EventClosed:
+		log.WithFields(fields).Info("door closed")
+
+		err := c.strike.Lock()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"application": c.application.AppType,
+				"error":       err,
+			}).Error("error relocking strike after the door closed")
+		}
+	}
 }
 
-func (c *Controller) grantAccess(id string) {
+// requestToExit unlocks the strike so that someone can leave. The Rex has already allowed the door to be opened, so the
+// exit is not reported as forced open even if the strike fails to unlock. The exit is logged, counted, and recorded to
+// the audit log.
+func (c *Controller) requestToExit(event door.Event) {
 	log.WithFields(log.Fields{
 		"application": c.application.AppType,
-		"id":          id,
-	}).Info("allowing access for badge id")
+		"duration":    c.rexDuration.String(),
+	}).Info("unlocking door for request to exit")
 
-	err := c.strike.Unlock(time.Second * 3)
+	err := c.unlock(c.rexDuration)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"application": c.application.AppType,
 			"error":       err,
-		}).Error("error unlocking strike for id")
+		}).Error("error unlocking strike for request to exit")
+	} else {
+		accessGrantedCounter.WithLabelValues("", "", audit.SourceRequestToExit).Inc()
 	}
+
+	c.writeAudit(audit.Entry{
+		Timestamp:      event.Timestamp,
+		Source:         audit.SourceRequestToExit,
+		Decision:       audit.DecisionGranted,
+		StrikeActuated: err == nil,
+	})
+}
+
+// checkExpirations warns about enabled badges that expire within the expiration warning period.
+func (c *Controller) checkExpirations() {
+	if c.expiration <= 0 {
+		return
+	}
+
+	badges, err := c.datastore.ListBadges()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"error":       err,
+		}).Error("error communicating with the datastore")
+		return
+	}
+
+	badgeExpiresInGauge.Reset()
+
+	now := time.Now()
+	for _, badge := range badges {
+		if !badge.Enabled || badge.ValidUntil == nil || !badge.ValidAt(now) {
+			continue
+		}
+
+		expiresIn := badge.ValidUntil.Sub(now)
+		if expiresIn > c.expiration {
+			continue
+		}
+
+		badgeExpiresInGauge.WithLabelValues(badge.ID).Set(expiresIn.Seconds())
+
+		log.WithFields(log.Fields{
+			"application": c.application.AppType,
+			"id":          badge.ID,
+			"valid_until": badge.ValidUntil.Format(time.RFC3339),
+			"days_left":   int(expiresIn.Hours() / 24),
+		}).Warn("badge is about to expire")
+	}
+}
+
+// acceptsUIDLength returns false if the reader that produced the event only accepts UIDs of other lengths.
+func (c *Controller) acceptsUIDLength(event scanner.ScanEvent) bool {
+	lengths := c.readers[event.Reader].UIDLengths
+	if len(lengths) == 0 {
+		return true
+	}
+
+	for _, length := range lengths {
+		if event.UIDLength() == length {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Controller) logEvent(event scanner.ScanEvent) *log.Entry {
+	return log.WithFields(log.Fields{
+		"application": c.application.AppType,
+		"reader":      event.Reader,
+		"id":          event.ID,
+		"technology":  event.Technology,
+		"uid_length":  event.UIDLength(),
+		"atqa":        hex.EncodeToString(event.ATQA[:]),
+		"sak":         fmt.Sprintf("%02x", event.SAK),
+	})
 }
@@ -0,0 +1,41 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/betterengineering/open-keyless/pkg/application"
+	"github.com/betterengineering/open-keyless/pkg/controller"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewControllerAdminAPIWithoutAuthentication(t *testing.T) {
+	configs := map[string]application.Config{
+		"no tls":           {LogLevel: logrus.WarnLevel},
+		"tls without a ca": {LogLevel: logrus.WarnLevel, AdminTLS: application.TLSConfig{Enabled: true}},
+	}
+
+	for name, cfg := range configs {
+		_, err := controller.NewController(controller.ControllerConfig{
+			ApplicationConfig: cfg,
+			AdminAPIEnabled:   true,
+		})
+		if err == nil || err.Error() != controller.ErrAdminAPIUnauthenticated {
+			t.Errorf("expected error '%s' with %s but got '%v'", controller.ErrAdminAPIUnauthenticated, name, err)
+		}
+	}
+}
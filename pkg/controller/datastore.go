@@ -0,0 +1,78 @@
+// Copyright 2019 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewDatastore provides the datastore implementation selected by the DatastoreBackend of the provided configuration. A
+// text file is watched for changes and, if the cache is enabled, the datastore is wrapped in a CachingDatastore that
+// refreshes in the background. Be sure to call datastore.Done when you are done with the datastore to clean up.
+func NewDatastore(config ControllerConfig) (datastore.Datastore, error) {
+	ds, err := OpenDatastore(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if txt, ok := ds.(*datastore.TextFile); ok {
+		watchTextFile(txt, config.TextFileConfig)
+	}
+
+	if !config.CacheEnabled {
+		return ds, nil
+	}
+
+	cache, err := datastore.NewCachingDatastore(ds, config.CacheConfig)
+	if err != nil {
+		datastore.Done(ds)
+		return nil, err
+	}
+
+	cache.Start()
+	return cache, nil
+}
+
+// OpenDatastore provides the datastore implementation selected by the DatastoreBackend of the provided configuration
+// without the cache or any other background work, for one-shot use such as a command line tool.
+func OpenDatastore(config ControllerConfig) (datastore.Datastore, error) {
+	err := validateDatastoreConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.DatastoreBackend {
+	case DatastoreBackendTextFile:
+		return datastore.NewTextFile(config.TextFileConfig)
+	case DatastoreBackendAirtable:
+		return datastore.NewAirTableDataStore(config.AirtableConfig)
+	default:
+		return nil, fmt.Errorf("%s - %s", ErrUnsupportedDatastoreBackend, config.DatastoreBackend)
+	}
+}
+
+func watchTextFile(ds *datastore.TextFile, config datastore.TextFileConfig) {
+	err := ds.Watch()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  config.Path,
+			"error": err,
+		}).Warn("could not watch text file for changes, the badge list will not be reloaded until restart")
+	}
+}
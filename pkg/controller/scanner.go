@@ -0,0 +1,52 @@
+// Copyright 2019 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/betterengineering/open-keyless/pkg/scanner"
+)
+
+// NewScanner provides the scanner implementation selected by the Type of the provided reader configuration. Scan events
+// and errors read by the scanner are sent on the provided channels.
+func NewScanner(config ReaderConfig, events chan scanner.ScanEvent, errs chan error) (scanner.Scanner, error) {
+	switch config.Type {
+	case ScannerTypeHid:
+		scn, err := scanner.NewHidScanner(config.HidConfig, events, errs)
+		if err != nil {
+			return nil, err
+		}
+
+		return scn, nil
+	case ScannerTypeLibNFC:
+		scn, err := scanner.OpenLibNFCScanner(config.LibNFCConfig, events, errs)
+		if err != nil {
+			return nil, err
+		}
+
+		return scn, nil
+	case ScannerTypeWiegand:
+		scn, err := scanner.OpenWiegandScanner(config.WiegandConfig, events, errs)
+		if err != nil {
+			return nil, err
+		}
+
+		return scn, nil
+	default:
+		return nil, fmt.Errorf("%s - %s", ErrUnsupportedScannerType, config.Type)
+	}
+}
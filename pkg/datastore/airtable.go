@@ -17,8 +17,12 @@ package datastore
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	airtable "github.com/fabioberger/airtable-go"
+	log "github.com/sirupsen/logrus"
 )
 
 // AirtableDatastore is an implementation of the datastore interface for Airtable.
@@ -33,19 +37,56 @@ type AirtableDatastoreConfig struct {
 
 	// BaseID is a valid Airtable base id.
 	BaseID string
+
+	// HTTPClient is used for requests to Airtable when set instead of the default client.
+	HTTPClient *http.Client
 }
 
 // AirtableBadge provides a wrapper around the badge model for Airtable requests.
 type AirtableBadge struct {
-	AirtableID string `json:"id"`
-	Fields     Badge  `json:"fields"`
+	AirtableID string         `json:"id"`
+	Fields     AirtableFields `json:"fields"`
 }
 
 // AirtableBadgeTmp is used to create a badge because the API does not like when AirtableID exists in the POST even
 // though it is empty.
 type AirtableBadgeTmp struct {
-	AirtableID string `json:"-"`
-	Fields     Badge  `json:"fields"`
+	AirtableID string         `json:"-"`
+	Fields     AirtableFields `json:"fields"`
+}
+
+// AirtableFields is the badge model as it is stored in Airtable. Airtable returns a date field that does not include a
+// time as a date like "2021-12-24" instead of a timestamp, so the validity fields are kept as strings and parsed with
+// ParseValidity.
+type AirtableFields struct {
+	ID         string `json:"id"`
+	Enabled    bool   `json:"enabled"`
+	Type       string `json:"type"`
+	Schedule   string `json:"schedule,omitempty"`
+	ValidFrom  string `json:"validFrom,omitempty"`
+	ValidUntil string `json:"validUntil,omitempty"`
+}
+
+// Badge converts the Airtable fields into a badge.
+func (f AirtableFields) Badge() (Badge, error) {
+	validFrom, err := ParseValidity(f.ValidFrom, false)
+	if err != nil {
+		return Badge{}, fmt.Errorf("could not parse validFrom of badge '%s' - %s", f.ID, err)
+	}
+
+	validUntil, err := ParseValidity(f.ValidUntil, true)
+	if err != nil {
+		return Badge{}, fmt.Errorf("could not parse validUntil of badge '%s' - %s", f.ID, err)
+	}
+
+	return Badge{
+		ID:         f.ID,
+		Enabled:    f.Enabled,
+		Type:       f.Type,
+		Schedule:   f.Schedule,
+		ValidFrom:  validFrom,
+		ValidUntil: validUntil,
+	}, nil
 }
 
 // NewAirTableDatastore provides an initialized datastore for Airtable using the provided configuration.
@@ -55,6 +96,10 @@ func NewAirTableDataStore(config AirtableDatastoreConfig) (*AirtableDatastore, e
 		return nil, err
 	}
 
+	if config.HTTPClient != nil {
+		client.HTTPClient = config.HTTPClient
+	}
+
 	return &AirtableDatastore{
 		client: client,
 	}, nil
@@ -62,13 +107,14 @@ func NewAirTableDataStore(config AirtableDatastoreConfig) (*AirtableDatastore, e
 
 // HasAccess returns true if the badge with the given ID should be given access.
 func (ds *AirtableDatastore) HasAccess(id string) (bool, error) {
-	airtableBadges, err := ds.listBadges()
+	badges, err := ds.ListBadges()
 	if err != nil {
 		return false, err
 	}
 
-	for _, badge := range airtableBadges {
-		if id == badge.Fields.ID && badge.Fields.Enabled == true {
+	now := time.Now()
+	for _, badge := range badges {
+		if id == badge.ID && badge.Enabled == true && badge.ValidAt(now) {
 			return true, nil
 		}
 	}
@@ -76,7 +122,8 @@ func (ds *AirtableDatastore) HasAccess(id string) (bool, error) {
 	return false, nil
 }
 
-// ListBadges returns a list of badges from the datastore.
+// ListBadges returns a list of badges from the datastore. A badge whose validity can not be parsed is logged and left
+// out of the list, so that a single bad record only denies that badge instead of every badge.
 func (ds *AirtableDatastore) ListBadges() ([]Badge, error) {
 	airtableBadges, err := ds.listBadges()
 	if err != nil {
@@ -84,8 +131,16 @@ func (ds *AirtableDatastore) ListBadges() ([]Badge, error) {
 	}
 
 	badges := []Badge{}
-	for _, badge := range airtableBadges {
-		badges = append(badges, badge.Fields)
+	for _, airtableBadge := range airtableBadges {
+		badge, err := airtableBadge.Fields.Badge()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"id":    airtableBadge.Fields.ID,
+				"error": err,
+			}).Error("skipping Airtable badge with an invalid record")
+			continue
+		}
+		badges = append(badges, badge)
 	}
 
 	return badges, nil
@@ -93,11 +148,19 @@ func (ds *AirtableDatastore) ListBadges() ([]Badge, error) {
 
 // CreateBadge creates a badge in the Airflow datastore with the provided values.
 func (ds *AirtableDatastore) CreateBadge(id string, badgeType string, enabled bool) error {
+	return ds.CreateBadgeWithValidity(id, badgeType, enabled, nil, nil)
+}
+
+// CreateBadgeWithValidity creates a badge in the Airtable datastore that is only granted access between from and until.
+// A nil from or until leaves that side of the validity period open.
+func (ds *AirtableDatastore) CreateBadgeWithValidity(id, badgeType string, enabled bool, from, until *time.Time) error {
 	airtableBadge := AirtableBadgeTmp{
-		Fields: Badge{
-			ID:      id,
-			Type:    badgeType,
-			Enabled: enabled,
+		Fields: AirtableFields{
+			ID:         id,
+			Type:       badgeType,
+			Enabled:    enabled,
+			ValidFrom:  formatAirtableTime(from),
+			ValidUntil: formatAirtableTime(until),
 		},
 	}
 
@@ -144,12 +207,17 @@ func (ds *AirtableDatastore) DeleteBadge(id string) error {
 
 // GetBadge returns a badge with the given ID. If the badge does not exist, ErrBadgeDoesNotExist will be returned.
 func (ds *AirtableDatastore) GetBadge(id string) (*Badge, error) {
-	badge, err := ds.getBadgeByID(id)
+	airtableBadge, err := ds.getBadgeByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	badge, err := airtableBadge.Fields.Badge()
 	if err != nil {
 		return nil, err
 	}
 
-	return &badge.Fields, nil
+	return &badge, nil
 }
 
 func (ds *AirtableDatastore) getBadgeByID(id string) (*AirtableBadge, error) {
@@ -177,3 +245,11 @@ func (ds *AirtableDatastore) listBadges() ([]AirtableBadge, error) {
 
 	return airtableBadges, nil
 }
+
+func formatAirtableTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+)
+
+func TestAirtableValidity(t *testing.T) {
+	ds, _ := givenAirtable(t, `{"records": [
+		{"id": "rec8604de7d000000", "fields": {"id": "8604de7d", "enabled": true, "type": "card",
+			"validFrom": "2021-06-01", "validUntil": "2021-06-30T18:00:00.000Z"}},
+		{"id": "rec04a1b2c3000000", "fields": {"id": "04a1b2c3", "enabled": true, "type": "card",
+			"validUntil": "2000-01-01"}},
+		{"id": "rec0c0ffee0000000", "fields": {"id": "0c0ffee0", "enabled": true, "type": "card",
+			"validFrom": "2000-01-01", "validUntil": "2999-12-31"}}
+	]}`)
+
+	badge, err := ds.GetBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error getting badge - %s", err)
+	}
+
+	validFrom := time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	if badge.ValidFrom == nil || !badge.ValidFrom.Equal(validFrom) {
+		t.Errorf("expected a date to be the start of the day '%s' but got '%v'", validFrom, badge.ValidFrom)
+	}
+
+	validUntil := time.Date(2021, 6, 30, 18, 0, 0, 0, time.UTC)
+	if badge.ValidUntil == nil || !badge.ValidUntil.Equal(validUntil) {
+		t.Errorf("expected '%s' but got '%v'", validUntil, badge.ValidUntil)
+	}
+
+	badge, err = ds.GetBadge("04a1b2c3")
+	if err != nil {
+		t.Fatalf("error getting badge - %s", err)
+	}
+
+	validUntil = time.Date(2000, 1, 2, 0, 0, 0, 0, time.Local)
+	if badge.ValidUntil == nil || !badge.ValidUntil.Equal(validUntil) {
+		t.Errorf("expected a date to be the end of the day '%s' but got '%v'", validUntil, badge.ValidUntil)
+	}
+
+	cases := map[string]bool{
+		"04a1b2c3": false,
+		"0c0ffee0": true,
+	}
+
+	for id, expected := range cases {
+		actual, err := ds.HasAccess(id)
+		if err != nil {
+			t.Fatalf("error checking access - %s", err)
+		}
+
+		if actual != expected {
+			t.Errorf("expected access '%t' for '%s' but got '%t'", expected, id, actual)
+		}
+	}
+}
+
+func TestAirtableMalformedValidity(t *testing.T) {
+	ds, _ := givenAirtable(t, `{"records": [
+		{"id": "rec8604de7d000000", "fields": {"id": "8604de7d", "enabled": true, "validUntil": "next week"}},
+		{"id": "rec04a1b2c3000000", "fields": {"id": "04a1b2c3", "enabled": true, "validUntil": "2999-12-31"}}
+	]}`)
+
+	// Only the badge with the malformed validity is denied.
+	cases := map[string]bool{
+		"8604de7d": false,
+		"04a1b2c3": true,
+	}
+
+	for id, expected := range cases {
+		actual, err := ds.HasAccess(id)
+		if err != nil {
+			t.Fatalf("error checking access - %s", err)
+		}
+
+		if actual != expected {
+			t.Errorf("expected access '%t' for '%s' but got '%t'", expected, id, actual)
+		}
+	}
+
+	badges, err := ds.ListBadges()
+	if err != nil {
+		t.Fatalf("error listing badges - %s", err)
+	}
+
+	if len(badges) != 1 || badges[0].ID != "04a1b2c3" {
+		t.Errorf("expected only the valid badge to be listed but got '%v'", badges)
+	}
+
+	_, err = ds.GetBadge("8604de7d")
+	if err == nil {
+		t.Errorf("expected an error getting a badge with a validity that is not a date")
+	}
+}
+
+func TestAirtableCreateBadgeWithValidity(t *testing.T) {
+	ds, created := givenAirtable(t, `{"records": []}`)
+
+	validFrom := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	err := ds.CreateBadgeWithValidity("8604de7d", "card", true, &validFrom, nil)
+	if err != nil {
+		t.Fatalf("error creating badge - %s", err)
+	}
+
+	var request struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	err = json.Unmarshal(<-created, &request)
+	if err != nil {
+		t.Fatalf("error decoding the created record - %s", err)
+	}
+
+	if request.Fields["validFrom"] != "2021-06-01T00:00:00Z" {
+		t.Errorf("expected validFrom '2021-06-01T00:00:00Z' but got '%v'", request.Fields["validFrom"])
+	}
+
+	if _, ok := request.Fields["validUntil"]; ok {
+		t.Errorf("expected validUntil to be left out but got '%v'", request.Fields["validUntil"])
+	}
+}
+
+// givenAirtable returns an Airtable datastore whose requests are answered in process. Listing returns the provided
+// records and the body of every created record is sent on the returned channel.
+func givenAirtable(t *testing.T, records string) (*datastore.AirtableDatastore, chan []byte) {
+	created := make(chan []byte, 1)
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body := []byte(records)
+		if r.Method == http.MethodPost {
+			request, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			created <- request
+			body = request
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	ds, err := datastore.NewAirTableDataStore(datastore.AirtableDatastoreConfig{
+		Key:        "keyTest0000000000",
+		BaseID:     "appTest0000000000",
+		HTTPClient: &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	return ds, created
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,315 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrCacheNotPopulated is returned when the cache is queried before a snapshot has been loaded from disk or
+	// fetched from the backing datastore.
+	ErrCacheNotPopulated = "the datastore cache has not been populated yet"
+
+	// DefaultCacheRefreshInterval is the refresh interval used when one is not configured.
+	DefaultCacheRefreshInterval = 5 * time.Minute
+)
+
+var (
+	cacheRefreshErrorCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_datastore_cache_refresh_errors_total",
+			Help: "The total count of failed attempts to refresh the datastore cache from the backing datastore.",
+		},
+	)
+	cacheLastRefreshGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "open_keyless_datastore_cache_last_refresh_timestamp_seconds",
+			Help: "The unix time of the last successful refresh of the datastore cache.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRefreshErrorCounter)
+	prometheus.MustRegister(cacheLastRefreshGauge)
+}
+
+// CachingDatastore implements the datastore interface by wrapping another datastore. Reads are answered from a snapshot
+// of the badge list that is kept in memory and optionally on disk, and refreshed from the backing datastore on an
+// interval. If the backing datastore can not be reached, the last snapshot continues to be used. Writes are passed
+// through to the backing datastore and refresh the snapshot.
+type CachingDatastore struct {
+	backend   Datastore
+	path      string
+	interval  time.Duration
+	mu        sync.RWMutex
+	badges    []Badge
+	populated bool
+	quit      chan bool
+	wg        *sync.WaitGroup
+	started   bool
+}
+
+// CacheConfig is a configuration struct for a CachingDatastore.
+type CacheConfig struct {
+	// Path is the file the snapshot is persisted to so that it survives a restart. If empty, the snapshot is only kept
+	// in memory.
+	Path string
+
+	// RefreshInterval is how often the snapshot is refreshed from the backing datastore.
+	RefreshInterval time.Duration
+}
+
+// NewCachingDatastore provides an initialized CachingDatastore in front of the provided backend. The snapshot on disk
+// is loaded if it exists and then an initial refresh from the backend is attempted. A failed refresh is logged rather
+// than returned so that the controller can start from the snapshot on disk while the backend is unreachable, and a
+// snapshot that can not be read is logged and ignored so that the controller can still start from the backend. Call
+// Start to begin refreshing on an interval.
+func NewCachingDatastore(backend Datastore, cfg CacheConfig) (*CachingDatastore, error) {
+	interval := cfg.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultCacheRefreshInterval
+	}
+
+	var wg sync.WaitGroup
+
+	cache := &CachingDatastore{
+		backend:  backend,
+		path:     cfg.Path,
+		interval: interval,
+		quit:     make(chan bool),
+		wg:       &wg,
+	}
+
+	err := cache.load()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  cfg.Path,
+			"error": err,
+		}).Warn("could not load datastore cache snapshot, starting without one")
+	}
+
+	err = cache.Refresh()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("could not refresh datastore cache, using the snapshot on disk")
+	}
+
+	return cache, nil
+}
+
+// Start begins refreshing the snapshot from the backing datastore on the configured interval.
+func (c *CachingDatastore) Start() {
+	if c.started {
+		return
+	}
+
+	c.started = true
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Done stops refreshing the snapshot and stops any background work of the backing datastore.
+func (c *CachingDatastore) Done() error {
+	if c.started {
+		c.quit <- true
+		c.wg.Wait()
+		c.started = false
+	}
+
+	return Done(c.backend)
+}
+
+// Refresh fetches the badge list from the backing datastore and replaces the snapshot with it. The snapshot is used
+// even if it can not be saved to disk, in which case the failure is logged rather than returned.
+func (c *CachingDatastore) Refresh() error {
+	badges, err := c.backend.ListBadges()
+	if err != nil {
+		cacheRefreshErrorCounter.Inc()
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.badges = badges
+	c.populated = true
+	cacheLastRefreshGauge.SetToCurrentTime()
+
+	err = c.save()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  c.path,
+			"error": err,
+		}).Warn("could not save datastore cache snapshot")
+	}
+
+	return nil
+}
+
+// HasAccess returns true if the badge with the given ID should be given access according to the snapshot.
+func (c *CachingDatastore) HasAccess(id string) (bool, error) {
+	badge, err := c.GetBadge(id)
+	if err != nil {
+		if err.Error() == ErrBadgeDoesNotExist {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return badge.Enabled && badge.ValidAt(time.Now()), nil
+}
+
+// ListBadges returns a list of badges from the snapshot.
+func (c *CachingDatastore) ListBadges() ([]Badge, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.populated {
+		return nil, errors.New(ErrCacheNotPopulated)
+	}
+
+	return copyBadges(c.badges), nil
+}
+
+// CreateBadge creates a badge in the backing datastore and refreshes the snapshot.
+func (c *CachingDatastore) CreateBadge(id string, badgeType string, enabled bool) error {
+	return c.writeThrough(c.backend.CreateBadge(id, badgeType, enabled))
+}
+
+// CreateBadgeWithValidity creates a badge with a validity period in the backing datastore and refreshes the snapshot.
+func (c *CachingDatastore) CreateBadgeWithValidity(id, badgeType string, enabled bool, from, until *time.Time) error {
+	return c.writeThrough(c.backend.CreateBadgeWithValidity(id, badgeType, enabled, from, until))
+}
+
+// EnableBadge enables a badge in the backing datastore and refreshes the snapshot.
+func (c *CachingDatastore) EnableBadge(id string) error {
+	return c.writeThrough(c.backend.EnableBadge(id))
+}
+
+// DisableBadge disables a badge in the backing datastore and refreshes the snapshot.
+func (c *CachingDatastore) DisableBadge(id string) error {
+	return c.writeThrough(c.backend.DisableBadge(id))
+}
+
+// DeleteBadge deletes a badge from the backing datastore and refreshes the snapshot.
+func (c *CachingDatastore) DeleteBadge(id string) error {
+	return c.writeThrough(c.backend.DeleteBadge(id))
+}
+
+// GetBadge returns a badge with the given ID from the snapshot. If the badge does not exist, ErrBadgeDoesNotExist will
+// be returned.
+func (c *CachingDatastore) GetBadge(id string) (*Badge, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.populated {
+		return nil, errors.New(ErrCacheNotPopulated)
+	}
+
+	for _, badge := range c.badges {
+		if badge.ID == id {
+			return &badge, nil
+		}
+	}
+
+	return nil, errors.New(ErrBadgeDoesNotExist)
+}
+
+func (c *CachingDatastore) writeThrough(err error) error {
+	if err != nil {
+		return err
+	}
+
+	err = c.Refresh()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("could not refresh datastore cache after a write")
+	}
+
+	return nil
+}
+
+func (c *CachingDatastore) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := c.Refresh()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error": err,
+				}).Warn("could not refresh datastore cache, continuing with the last snapshot")
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// load reads the snapshot from disk if one exists. The cache is left unpopulated if the snapshot can not be read.
+func (c *CachingDatastore) load() error {
+	if c.path == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	badges := []Badge{}
+	err = json.Unmarshal(content, &badges)
+	if err != nil {
+		return err
+	}
+
+	c.badges = badges
+	c.populated = true
+
+	return nil
+}
+
+// save persists the snapshot to disk. The caller must hold the write lock.
+func (c *CachingDatastore) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	return writeFileAtomic(c.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(c.badges)
+	})
+}
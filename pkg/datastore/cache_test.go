@@ -0,0 +1,198 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCachingDatastoreHasAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	backend := mocks.NewMockDatastore(ctrl)
+	backend.EXPECT().ListBadges().Return(givenBadges(), nil).Times(1)
+	backend.EXPECT().HasAccess(gomock.Any()).Times(0)
+
+	cache, err := datastore.NewCachingDatastore(backend, datastore.CacheConfig{})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	for x := 0; x < 10; x++ {
+		hasAccess, err := cache.HasAccess("8604de7d")
+		if err != nil {
+			t.Fatalf("error checking access - %s", err)
+		}
+
+		if !hasAccess {
+			t.Errorf("expected the cached badge to be granted access")
+		}
+	}
+}
+
+func TestCachingDatastoreOffline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := datastore.CacheConfig{
+		Path: filepath.Join(dir, "cache.json"),
+	}
+
+	online := mocks.NewMockDatastore(ctrl)
+	online.EXPECT().ListBadges().Return(givenBadges(), nil).Times(1)
+
+	_, err = datastore.NewCachingDatastore(online, cfg)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	offline := mocks.NewMockDatastore(ctrl)
+	offline.EXPECT().ListBadges().Return(nil, errors.New("network is unreachable")).Times(2)
+
+	cache, err := datastore.NewCachingDatastore(offline, cfg)
+	if err != nil {
+		t.Fatalf("error creating cache from snapshot - %s", err)
+	}
+
+	err = cache.Refresh()
+	if err == nil {
+		t.Errorf("expected an error refreshing from an unreachable backend")
+	}
+
+	hasAccess, err := cache.HasAccess("8604de7d")
+	if err != nil {
+		t.Fatalf("error checking access - %s", err)
+	}
+
+	if !hasAccess {
+		t.Errorf("expected the snapshot on disk to be used while the backend is unreachable")
+	}
+
+	hasAccess, err = cache.HasAccess("04a1b2c3")
+	if err != nil {
+		t.Fatalf("error checking access - %s", err)
+	}
+
+	if hasAccess {
+		t.Errorf("expected a disabled badge to be denied access")
+	}
+}
+
+func TestCachingDatastoreCorruptSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The snapshot was only partly written before the power was lost.
+	path := filepath.Join(dir, "cache.json")
+	err = ioutil.WriteFile(path, []byte(`[{"id": "8604de7d", "type": "ca`), 0600)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	backend := mocks.NewMockDatastore(ctrl)
+	backend.EXPECT().ListBadges().Return(givenBadges(), nil).Times(1)
+
+	cache, err := datastore.NewCachingDatastore(backend, datastore.CacheConfig{Path: path})
+	if err != nil {
+		t.Fatalf("expected the cache to start from the backend but got '%s'", err)
+	}
+
+	hasAccess, err := cache.HasAccess("8604de7d")
+	if err != nil {
+		t.Fatalf("error checking access - %s", err)
+	}
+
+	if !hasAccess {
+		t.Errorf("expected the badge refreshed from the backend to be granted access")
+	}
+}
+
+func TestCachingDatastoreRefreshSaveError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend := mocks.NewMockDatastore(ctrl)
+	backend.EXPECT().ListBadges().Return(givenBadges(), nil).Times(2)
+
+	// The snapshot can not be saved because its directory does not exist.
+	cfg := datastore.CacheConfig{Path: filepath.Join(dir, "missing", "cache.json")}
+	cache, err := datastore.NewCachingDatastore(backend, cfg)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	err = cache.Refresh()
+	if err != nil {
+		t.Errorf("expected the refresh to succeed without saving the snapshot but got '%s'", err)
+	}
+
+	hasAccess, err := cache.HasAccess("8604de7d")
+	if err != nil || !hasAccess {
+		t.Errorf("expected the refreshed snapshot to be used but got '%t' - %v", hasAccess, err)
+	}
+}
+
+func TestCachingDatastoreNotPopulated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	backend := mocks.NewMockDatastore(ctrl)
+	backend.EXPECT().ListBadges().Return(nil, errors.New("network is unreachable")).Times(1)
+
+	cache, err := datastore.NewCachingDatastore(backend, datastore.CacheConfig{})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	_, err = cache.HasAccess("8604de7d")
+	if err == nil || err.Error() != datastore.ErrCacheNotPopulated {
+		t.Errorf("expected error '%s' but got '%v'", datastore.ErrCacheNotPopulated, err)
+	}
+}
+
+func givenBadges() []datastore.Badge {
+	return []datastore.Badge{
+		{ID: "8604de7d", Type: "card", Enabled: true},
+		{ID: "04a1b2c3", Type: "sticker", Enabled: false},
+	}
+}
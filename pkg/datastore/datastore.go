@@ -16,9 +16,20 @@
 // Package datastore provides an interface and implementations for interacting with a badge datastore.
 package datastore
 
+import (
+	"fmt"
+	"time"
+)
+
 const (
 	// ErrBadgeDoesNotExist is returned when the badge requested does not exist in the datastore.
 	ErrBadgeDoesNotExist = "the badge requested does not exist"
+
+	// ErrBadgeAlreadyExists is returned when a badge is created with an ID that already exists in the datastore.
+	ErrBadgeAlreadyExists = "a badge with the requested id already exists"
+
+	// ValidityDateLayout is accepted for the validity of a badge in addition to RFC 3339 timestamps.
+	ValidityDateLayout = "2006-01-02"
 )
 
 // Datastore is an interface for accessing a badge datastore.
@@ -26,6 +37,7 @@ type Datastore interface {
 	HasAccess(id string) (bool, error)
 	ListBadges() ([]Badge, error)
 	CreateBadge(id string, badgeType string, enabled bool) error
+	CreateBadgeWithValidity(id, badgeType string, enabled bool, from, until *time.Time) error
 	EnableBadge(id string) error
 	DisableBadge(id string) error
 	DeleteBadge(id string) error
@@ -42,4 +54,64 @@ type Badge struct {
 
 	// Type is the type of badge. E.x. card, sticker, keychain.
 	Type string `json:"type"`
+
+	// Schedule is the name of the access schedule that restricts when the badge is granted access. An empty schedule
+	// allows access at any time.
+	Schedule string `json:"schedule,omitempty"`
+
+	// ValidFrom is when the badge starts being granted access. A nil ValidFrom means the badge is valid immediately.
+	ValidFrom *time.Time `json:"validFrom,omitempty"`
+
+	// ValidUntil is when the badge stops being granted access. A nil ValidUntil means the badge never expires.
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
+// ValidAt returns true if the provided time is within the validity period of the badge. The period includes ValidFrom
+// and excludes ValidUntil.
+func (b Badge) ValidAt(t time.Time) bool {
+	if b.ValidFrom != nil && t.Before(*b.ValidFrom) {
+		return false
+	}
+
+	if b.ValidUntil != nil && !t.Before(*b.ValidUntil) {
+		return false
+	}
+
+	return true
+}
+
+// Done stops any work the datastore does in the background, such as refreshing a cache or watching a file for changes.
+// Datastores that do not do any work in the background are left as they are.
+func Done(ds Datastore) error {
+	background, ok := ds.(interface{ Done() error })
+	if !ok {
+		return nil
+	}
+
+	return background.Done()
+}
+
+// ParseValidity parses the validity of a badge from either an RFC 3339 timestamp or a date in the form "2006-01-02". An
+// empty value is returned as nil. A date is the start of that day in local time, or the end of that day when endOfDay
+// is true so that a ValidUntil date includes the whole day.
+func ParseValidity(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &t, nil
+	}
+
+	t, err = time.ParseInLocation(ValidityDateLayout, value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s' as a date or RFC 3339 timestamp", value)
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return &t, nil
 }
@@ -16,15 +16,70 @@
 package datastore
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 )
 
-// TextFile implements the datastore interface with a file.
+const (
+	// ErrMalformedTextFileRecord is returned when a line in the text file can not be parsed into a badge.
+	ErrMalformedTextFileRecord = "could not parse a badge record in the text file"
+
+	// textFileHeader is written as a comment at the top of the text file to describe the record format.
+	textFileHeader = "# id,type,enabled,schedule,valid_from,valid_until\n"
+
+	// textFileFields is the maximum number of fields in a text file record.
+	textFileFields = 6
+
+	// textFileReloadDelay is how long to wait after the last change to the text file before reloading it. Editors
+	// often write a file in several steps, so this avoids parsing a file that is only partially written.
+	textFileReloadDelay = 250 * time.Millisecond
+)
+
+var (
+	textFileReloadCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_datastore_text_file_reloads_total",
+			Help: "The total count of successful reloads of the text file datastore.",
+		},
+	)
+	textFileReloadErrorCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_datastore_text_file_reload_errors_total",
+			Help: "The total count of text file datastore reloads that failed and kept the last good badge list.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(textFileReloadCounter)
+	prometheus.MustRegister(textFileReloadErrorCounter)
+}
+
+// TextFile implements the datastore interface with a file. Each line of the file describes a single badge in the form
+// "id,type,enabled,schedule,valid_from,valid_until" where the trailing fields are optional. The validity fields are
+// RFC 3339 timestamps or dates in the form "2006-01-02". A date is the start of that day in local time for valid_from
+// and the end of that day for valid_until. Lines starting with "#" are ignored. A line that only contains an id is
+// treated as an enabled badge so that files containing a bare list of ids continue to work.
 type TextFile struct {
-	ids []string
+	path    string
+	mu      sync.RWMutex
+	badges  []Badge
+	watcher *fsnotify.Watcher
+	quit    chan bool
+	wg      *sync.WaitGroup
 }
 
 // TextFileConfig is a configuration struct for a a TextFile datastore.
@@ -34,50 +89,386 @@ type TextFileConfig struct {
 
 // NewTextFile provides an instantiated datastore.
 func NewTextFile(cfg TextFileConfig) (*TextFile, error) {
-	content, err := ioutil.ReadFile(cfg.Path)
+	badges, err := readTextFile(cfg.Path)
 	if err != nil {
 		return nil, err
 	}
 
-	ids := strings.Split(string(content), "\n")
+	var wg sync.WaitGroup
+
 	return &TextFile{
-		ids: ids,
+		path:   cfg.Path,
+		badges: badges,
+		quit:   make(chan bool),
+		wg:     &wg,
 	}, nil
 }
 
+// Watch starts watching the text file for changes if it is not already being watched. When the file changes, the
+// badge list is reloaded and swapped in atomically. If the new file can not be parsed, the last good badge list is
+// kept. Be sure to call Done when you are done with the datastore to clean up.
+func (txt *TextFile) Watch() error {
+	if txt.watcher != nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// The directory is watched rather than the file itself because writes, including our own, replace the file with
+	// a rename which would leave a watch on the original file pointing at a stale inode.
+	err = watcher.Add(filepath.Dir(txt.path))
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	txt.watcher = watcher
+	txt.wg.Add(1)
+	go txt.watch()
+
+	return nil
+}
+
+// Done stops watching the text file for changes.
+func (txt *TextFile) Done() error {
+	if txt.watcher == nil {
+		return nil
+	}
+
+	txt.quit <- true
+	txt.wg.Wait()
+
+	err := txt.watcher.Close()
+	txt.watcher = nil
+	return err
+}
+
+// HasAccess returns true if the badge with the given ID should be given access.
 func (txt *TextFile) HasAccess(id string) (bool, error) {
-	log.Printf("Verifying access for %s", id)
-	for _, cur := range txt.ids {
-		if id == cur {
-			log.Printf("access granted for %s", id)
-			return true, nil
-		}
+	txt.mu.RLock()
+	defer txt.mu.RUnlock()
+
+	i := txt.indexOf(id)
+	if i < 0 {
+		return false, nil
 	}
 
-	log.Printf("access denied for %s", id)
-	return false, nil
+	return txt.badges[i].Enabled && txt.badges[i].ValidAt(time.Now()), nil
 }
 
+// ListBadges returns a list of badges from the datastore.
 func (txt *TextFile) ListBadges() ([]Badge, error) {
-	return nil, errors.New("not implemented")
+	txt.mu.RLock()
+	defer txt.mu.RUnlock()
+
+	return copyBadges(txt.badges), nil
 }
 
+// CreateBadge creates a badge in the text file with the provided values. If a badge with the same ID already exists,
+// ErrBadgeAlreadyExists will be returned.
 func (txt *TextFile) CreateBadge(id string, badgeType string, enabled bool) error {
-	return errors.New("not implemented")
+	return txt.CreateBadgeWithValidity(id, badgeType, enabled, nil, nil)
+}
+
+// CreateBadgeWithValidity creates a badge in the text file that is only granted access between from and until. A nil
+// from or until leaves that side of the validity period open. If a badge with the same ID already exists,
+// ErrBadgeAlreadyExists will be returned.
+func (txt *TextFile) CreateBadgeWithValidity(id, badgeType string, enabled bool, from, until *time.Time) error {
+	txt.mu.Lock()
+	defer txt.mu.Unlock()
+
+	if txt.indexOf(id) >= 0 {
+		return errors.New(ErrBadgeAlreadyExists)
+	}
+
+	badges := append(copyBadges(txt.badges), Badge{
+		ID:         id,
+		Type:       badgeType,
+		Enabled:    enabled,
+		ValidFrom:  from,
+		ValidUntil: until,
+	})
+
+	return txt.commit(badges)
 }
 
+// EnableBadge enables a badge that exists in the datastore.
 func (txt *TextFile) EnableBadge(id string) error {
-	return errors.New("not implemented")
+	return txt.updateBadge(id, func(badge *Badge) {
+		badge.Enabled = true
+	})
 }
 
+// DisableBadge disables a badge that exists in the datastore.
 func (txt *TextFile) DisableBadge(id string) error {
-	return errors.New("not implemented")
+	return txt.updateBadge(id, func(badge *Badge) {
+		badge.Enabled = false
+	})
 }
 
+// DeleteBadge deletes a badge from the datastore.
 func (txt *TextFile) DeleteBadge(id string) error {
-	return errors.New("not implemented")
+	txt.mu.Lock()
+	defer txt.mu.Unlock()
+
+	i := txt.indexOf(id)
+	if i < 0 {
+		return errors.New(ErrBadgeDoesNotExist)
+	}
+
+	badges := make([]Badge, 0, len(txt.badges)-1)
+	badges = append(badges, txt.badges[:i]...)
+	badges = append(badges, txt.badges[i+1:]...)
+
+	return txt.commit(badges)
 }
 
+// GetBadge returns a badge with the given ID. If the badge does not exist, ErrBadgeDoesNotExist will be returned.
 func (txt *TextFile) GetBadge(id string) (*Badge, error) {
-	return nil, errors.New("not implemented")
+	txt.mu.RLock()
+	defer txt.mu.RUnlock()
+
+	i := txt.indexOf(id)
+	if i < 0 {
+		return nil, errors.New(ErrBadgeDoesNotExist)
+	}
+
+	badge := txt.badges[i]
+	return &badge, nil
+}
+
+func (txt *TextFile) updateBadge(id string, update func(badge *Badge)) error {
+	txt.mu.Lock()
+	defer txt.mu.Unlock()
+
+	i := txt.indexOf(id)
+	if i < 0 {
+		return errors.New(ErrBadgeDoesNotExist)
+	}
+
+	badges := copyBadges(txt.badges)
+	update(&badges[i])
+
+	return txt.commit(badges)
+}
+
+// commit writes the provided badges to disk and, only if that succeeds, makes them the active badge list. The caller
+// must hold the write lock.
+func (txt *TextFile) commit(badges []Badge) error {
+	err := writeTextFile(txt.path, badges)
+	if err != nil {
+		return err
+	}
+
+	txt.badges = badges
+	return nil
+}
+
+func (txt *TextFile) watch() {
+	defer txt.wg.Done()
+
+	path := filepath.Clean(txt.path)
+	var reload <-chan time.Time
+
+	for {
+		select {
+		case event := <-txt.watcher.Events:
+			if filepath.Clean(event.Name) != path {
+				continue
+			}
+
+			reload = time.After(textFileReloadDelay)
+		case err := <-txt.watcher.Errors:
+			log.WithFields(log.Fields{
+				"path":  txt.path,
+				"error": err,
+			}).Error("error while watching text file for changes")
+		case <-reload:
+			reload = nil
+			txt.reload()
+		case <-txt.quit:
+			return
+		}
+	}
+}
+
+// reload reads and parses the file without holding the lock, so lookups are not stalled on disk I/O, and then swaps in
+// the new badge list. A write that lands in between changes the file again, which schedules another reload.
+func (txt *TextFile) reload() {
+	badges, err := readTextFile(txt.path)
+	if err != nil {
+		textFileReloadErrorCounter.Inc()
+		log.WithFields(log.Fields{
+			"path":  txt.path,
+			"error": err,
+		}).Error("could not reload text file, keeping the last good badge list")
+		return
+	}
+
+	txt.mu.Lock()
+	txt.badges = badges
+	txt.mu.Unlock()
+	textFileReloadCounter.Inc()
+
+	log.WithFields(log.Fields{
+		"path":   txt.path,
+		"badges": len(badges),
+	}).Info("reloaded text file")
+}
+
+func (txt *TextFile) indexOf(id string) int {
+	for i, badge := range txt.badges {
+		if badge.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func readTextFile(path string) ([]Badge, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTextFile(content)
+}
+
+// ParseTextFile parses badges from content in the text file format described by TextFile.
+func ParseTextFile(content []byte) ([]Badge, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	badges := []Badge{}
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		badge, err := parseTextFileRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s (record %d) - %s", ErrMalformedTextFileRecord, n, err)
+		}
+
+		badges = append(badges, badge)
+	}
+
+	return badges, nil
+}
+
+func parseTextFileRecord(record []string) (Badge, error) {
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+
+	if len(record) > textFileFields {
+		return Badge{}, fmt.Errorf("expected at most %d fields but found %d", textFileFields, len(record))
+	}
+
+	badge := Badge{
+		ID:      record[0],
+		Enabled: true,
+	}
+	if badge.ID == "" {
+		return Badge{}, errors.New("badge id is empty")
+	}
+
+	if len(record) > 1 {
+		badge.Type = record[1]
+	}
+
+	if len(record) > 2 {
+		enabled, err := strconv.ParseBool(record[2])
+		if err != nil {
+			return Badge{}, err
+		}
+		badge.Enabled = enabled
+	}
+
+	if len(record) > 3 {
+		badge.Schedule = record[3]
+	}
+
+	if len(record) > 4 {
+		validFrom, err := ParseValidity(record[4], false)
+		if err != nil {
+			return Badge{}, err
+		}
+		badge.ValidFrom = validFrom
+	}
+
+	if len(record) > 5 {
+		validUntil, err := ParseValidity(record[5], true)
+		if err != nil {
+			return Badge{}, err
+		}
+		badge.ValidUntil = validUntil
+	}
+
+	return badge, nil
+}
+
+func writeTextFile(path string, badges []Badge) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return EncodeTextFile(w, badges)
+	})
+}
+
+// EncodeTextFile writes the badges to w in the text file format described by TextFile.
+func EncodeTextFile(w io.Writer, badges []Badge) error {
+	_, err := io.WriteString(w, textFileHeader)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	for _, badge := range badges {
+		err = writer.Write(encodeTextFileRecord(badge))
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// encodeTextFileRecord encodes a badge into a record, leaving off trailing optional fields that are empty so that
+// simple files stay simple.
+func encodeTextFileRecord(badge Badge) []string {
+	record := []string{
+		badge.ID,
+		badge.Type,
+		strconv.FormatBool(badge.Enabled),
+		badge.Schedule,
+		formatTextFileTime(badge.ValidFrom),
+		formatTextFileTime(badge.ValidUntil),
+	}
+	for len(record) > 3 && record[len(record)-1] == "" {
+		record = record[:len(record)-1]
+	}
+
+	return record
+}
+
+func formatTextFileTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func copyBadges(badges []Badge) []Badge {
+	return append([]Badge{}, badges...)
 }
@@ -0,0 +1,285 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package datastore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+)
+
+func TestTextFileLegacyFormat(t *testing.T) {
+	ds, _, cleanup := givenTextFile(t, "8604de7d\n04a1b2c3d4e5f6\n")
+	defer cleanup()
+
+	hasAccess, err := ds.HasAccess("04a1b2c3d4e5f6")
+	if err != nil {
+		t.Fatalf("error checking access - %s", err)
+	}
+
+	if !hasAccess {
+		t.Errorf("expected a bare id to be granted access")
+	}
+}
+
+func TestTextFileHasAccess(t *testing.T) {
+	ds, _, cleanup := givenTextFile(t, "# id,type,enabled\n8604de7d,card,true\n04a1b2c3,sticker,false\n")
+	defer cleanup()
+
+	cases := map[string]bool{
+		"8604de7d": true,
+		"04a1b2c3": false,
+		"ffffffff": false,
+	}
+
+	for id, expected := range cases {
+		actual, err := ds.HasAccess(id)
+		if err != nil {
+			t.Fatalf("error checking access - %s", err)
+		}
+
+		if actual != expected {
+			t.Errorf("expected access '%t' for '%s' but got '%t'", expected, id, actual)
+		}
+	}
+}
+
+func TestTextFileSchedule(t *testing.T) {
+	ds, _, cleanup := givenTextFile(t, "8604de7d,card,true,cleaning\n04a1b2c3,card,true\n")
+	defer cleanup()
+
+	badges, err := ds.ListBadges()
+	if err != nil {
+		t.Fatalf("error listing badges - %s", err)
+	}
+
+	expected := []datastore.Badge{
+		{ID: "8604de7d", Type: "card", Enabled: true, Schedule: "cleaning"},
+		{ID: "04a1b2c3", Type: "card", Enabled: true},
+	}
+	if !reflect.DeepEqual(expected, badges) {
+		t.Errorf("expected '%+v' does not equal actual '%+v'", expected, badges)
+	}
+}
+
+func TestTextFileValidity(t *testing.T) {
+	ds, path, cleanup := givenTextFile(t, strings.Join([]string{
+		"8604de7d,card,true,,2000-01-01,2999-12-31",
+		"04a1b2c3,card,true,,2999-01-01",
+		"04d5e6f7,card,true,,,2000-01-01T00:00:00Z",
+	}, "\n"))
+	defer cleanup()
+
+	cases := map[string]bool{
+		"8604de7d": true,
+		"04a1b2c3": false,
+		"04d5e6f7": false,
+	}
+
+	for id, expected := range cases {
+		actual, err := ds.HasAccess(id)
+		if err != nil {
+			t.Fatalf("error checking access - %s", err)
+		}
+
+		if actual != expected {
+			t.Errorf("expected access '%t' for '%s' but got '%t'", expected, id, actual)
+		}
+	}
+
+	badge, err := ds.GetBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error getting badge - %s", err)
+	}
+
+	expectedUntil := time.Date(3000, time.January, 1, 0, 0, 0, 0, time.Local)
+	if !badge.ValidUntil.Equal(expectedUntil) {
+		t.Errorf("expected a date to be valid until the end of the day '%s' but got '%s'", expectedUntil,
+			badge.ValidUntil)
+	}
+
+	err = ds.DisableBadge("04d5e6f7")
+	if err != nil {
+		t.Fatalf("error disabling badge - %s", err)
+	}
+
+	reloaded, err := datastore.NewTextFile(datastore.TextFileConfig{Path: path})
+	if err != nil {
+		t.Fatalf("error reloading text file - %s", err)
+	}
+
+	badge, err = reloaded.GetBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error getting badge - %s", err)
+	}
+
+	if badge.ValidFrom == nil || !badge.ValidUntil.Equal(expectedUntil) {
+		t.Errorf("expected the validity period to be persisted but got '%+v'", badge)
+	}
+}
+
+func TestTextFileMalformedRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "textfile")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ids.txt")
+	err = ioutil.WriteFile(path, []byte("8604de7d,card,maybe\n"), 0644)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	_, err = datastore.NewTextFile(datastore.TextFileConfig{Path: path})
+	if err == nil {
+		t.Errorf("expected an error when parsing a malformed record")
+	}
+}
+
+func TestTextFileLifecycle(t *testing.T) {
+	ds, path, cleanup := givenTextFile(t, "")
+	defer cleanup()
+
+	err := ds.CreateBadge("8604de7d", "card", false)
+	if err != nil {
+		t.Fatalf("error creating badge - %s", err)
+	}
+
+	err = ds.CreateBadge("8604de7d", "card", false)
+	if err == nil || err.Error() != datastore.ErrBadgeAlreadyExists {
+		t.Errorf("expected error '%s' but got '%v'", datastore.ErrBadgeAlreadyExists, err)
+	}
+
+	err = ds.EnableBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error enabling badge - %s", err)
+	}
+
+	badge, err := ds.GetBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error getting badge - %s", err)
+	}
+
+	expected := datastore.Badge{ID: "8604de7d", Type: "card", Enabled: true}
+	if !reflect.DeepEqual(expected, *badge) {
+		t.Errorf("expected '%+v' does not equal actual '%+v'", expected, *badge)
+	}
+
+	reloaded, err := datastore.NewTextFile(datastore.TextFileConfig{Path: path})
+	if err != nil {
+		t.Fatalf("error reloading text file - %s", err)
+	}
+
+	badges, err := reloaded.ListBadges()
+	if err != nil {
+		t.Fatalf("error listing badges - %s", err)
+	}
+
+	if !reflect.DeepEqual([]datastore.Badge{expected}, badges) {
+		t.Errorf("expected '%+v' does not equal actual '%+v'", []datastore.Badge{expected}, badges)
+	}
+
+	err = ds.DisableBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error disabling badge - %s", err)
+	}
+
+	err = ds.DeleteBadge("8604de7d")
+	if err != nil {
+		t.Fatalf("error deleting badge - %s", err)
+	}
+
+	_, err = ds.GetBadge("8604de7d")
+	if err == nil || err.Error() != datastore.ErrBadgeDoesNotExist {
+		t.Errorf("expected error '%s' but got '%v'", datastore.ErrBadgeDoesNotExist, err)
+	}
+}
+
+func givenTextFile(t *testing.T, content string) (*datastore.TextFile, string, func()) {
+	dir, err := ioutil.TempDir("", "textfile")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	path := filepath.Join(dir, "ids.txt")
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	ds, err := datastore.NewTextFile(datastore.TextFileConfig{Path: path})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	return ds, path, func() { os.RemoveAll(dir) }
+}
+
+func TestTextFileWatch(t *testing.T) {
+	ds, path, cleanup := givenTextFile(t, "8604de7d,card,true\n")
+	defer cleanup()
+
+	err := ds.Watch()
+	if err != nil {
+		t.Fatalf("error watching text file - %s", err)
+	}
+	defer ds.Done()
+
+	err = ioutil.WriteFile(path, []byte("8604de7d,card,false\n04a1b2c3,card,true\n"), 0644)
+	if err != nil {
+		t.Fatalf("error updating text file - %s", err)
+	}
+
+	if !eventuallyHasAccess(ds, "04a1b2c3", true) {
+		t.Errorf("expected the new badge to be granted access after the file changed")
+	}
+
+	err = ioutil.WriteFile(path, []byte("04a1b2c3,card,maybe\n"), 0644)
+	if err != nil {
+		t.Fatalf("error updating text file - %s", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	hasAccess, err := ds.HasAccess("04a1b2c3")
+	if err != nil {
+		t.Fatalf("error checking access - %s", err)
+	}
+
+	if !hasAccess {
+		t.Errorf("expected the last good badge list to be kept after a malformed update")
+	}
+}
+
+func eventuallyHasAccess(ds *datastore.TextFile, id string, expected bool) bool {
+	for x := 0; x < 40; x++ {
+		hasAccess, err := ds.HasAccess(id)
+		if err == nil && hasAccess == expected {
+			return true
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return false
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package desfire
+
+import (
+	"crypto/aes"
+	"errors"
+)
+
+// CMAC returns the AES-CMAC of the message as defined in RFC 4493.
+func CMAC(key []byte, message []byte) ([]byte, error) {
+	return cmac(key, message, 1)
+}
+
+// cmac computes the AES-CMAC of the message, padding it to at least the provided number of blocks. AN10922 key
+// diversification pads its input to two blocks, which standard CMAC does not.
+func cmac(key []byte, message []byte, minBlocks int) ([]byte, error) {
+	if len(key) != KeyLength {
+		return nil, errors.New(ErrInvalidKeyLength)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	k1, k2 := subkeys(block.Encrypt)
+
+	n := (len(message) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n >= minBlocks && len(message)%aes.BlockSize == 0
+	if n < minBlocks {
+		n = minBlocks
+	}
+
+	padded := make([]byte, n*aes.BlockSize)
+	copy(padded, message)
+	last := padded[(n-1)*aes.BlockSize:]
+	if complete {
+		xor(last, k1)
+	} else {
+		padded[len(message)] = 0x80
+		xor(last, k2)
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < n; i++ {
+		xor(mac, padded[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(mac, mac)
+	}
+
+	return mac, nil
+}
+
+// subkeys generates the two CMAC subkeys from the block cipher.
+func subkeys(encrypt func(dst, src []byte)) ([]byte, []byte) {
+	l := make([]byte, aes.BlockSize)
+	encrypt(l, l)
+
+	k1 := shift(l)
+	k2 := shift(k1)
+	return k1, k2
+}
+
+// shift doubles the block in GF(2^128), shifting it left by one bit and applying the reduction polynomial on overflow.
+func shift(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := 0; i < len(b)-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[len(b)-1] = b[len(b)-1] << 1
+	if b[0]&0x80 != 0 {
+		out[len(b)-1] ^= 0x87
+	}
+
+	return out
+}
+
+func xor(dst []byte, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
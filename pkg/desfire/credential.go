@@ -0,0 +1,177 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package desfire
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// ErrInvalidCredential is returned when the signature of a credential read from a card does not match.
+	ErrInvalidCredential = "the credential signature is invalid"
+
+	// ErrInvalidCredentialConfig is returned when a CredentialConfig is missing a key or uses an invalid application
+	// id.
+	ErrInvalidCredentialConfig = "invalid secure credential config"
+)
+
+const (
+	// CredentialLength is the length in bytes of the credential file, an 8 byte credential number followed by an 8
+	// byte signature.
+	CredentialLength = 16
+
+	signatureLength = 8
+
+	// maxUIDLength is the longest ISO14443A UID, used to check that the diversification input always fits.
+	maxUIDLength = 10
+)
+
+// CredentialConfig describes where the secure credential is stored on a card and the keys used to read it.
+type CredentialConfig struct {
+	// AID is the id of the site application that holds the credential.
+	AID uint32
+
+	// KeyNumber is the number of the application key used to authenticate before reading the credential.
+	KeyNumber byte
+
+	// Key is the AES key used to authenticate with the card.
+	Key []byte
+
+	// FileNumber is the number of the standard data file that holds the credential.
+	FileNumber byte
+
+	// SigningKey is the AES key used to sign credential numbers.
+	SigningKey []byte
+
+	// Diversify treats Key as a master key and authenticates with a key derived from it for each card. See CardKey.
+	Diversify bool
+
+	// SystemID is included in the diversification input so that keys derived for one site differ from keys derived
+	// for another site with the same master key.
+	SystemID []byte
+}
+
+// Validate returns an error if the config can not be used to read a credential.
+func (cfg CredentialConfig) Validate() error {
+	if cfg.AID == 0 || cfg.AID > MaxAID {
+		return fmt.Errorf("%s - %s", ErrInvalidCredentialConfig, ErrInvalidAID)
+	}
+
+	if len(cfg.Key) != KeyLength || len(cfg.SigningKey) != KeyLength {
+		return fmt.Errorf("%s - %s", ErrInvalidCredentialConfig, ErrInvalidKeyLength)
+	}
+
+	if len(DiversificationInput(make([]byte, maxUIDLength), cfg.AID, cfg.SystemID)) > maxDiversificationInput {
+		return fmt.Errorf("%s - %s", ErrInvalidCredentialConfig, ErrDiversificationInputTooLong)
+	}
+
+	return nil
+}
+
+// CardKey returns the key used to authenticate with the card with the provided UID. This is Key itself, or the key
+// diversified from Key with the UID, application id and system id when Diversify is set.
+func (cfg CredentialConfig) CardKey(uid []byte) ([]byte, error) {
+	if !cfg.Diversify {
+		return cfg.Key, nil
+	}
+
+	return DiversifyKey(cfg.Key, DiversificationInput(uid, cfg.AID, cfg.SystemID))
+}
+
+// CredentialID formats a credential number as the badge id used in the datastore. The id is namespaced so that it can
+// never be matched by the UID of a card read by a reader that does not check credentials.
+func CredentialID(number uint64) string {
+	return fmt.Sprintf("desfire:%016x", number)
+}
+
+// ReadCredential selects the site application on the card with the provided UID, authenticates with the configured key
+// and returns the credential number after verifying its signature.
+func ReadCredential(t Transceiver, cfg CredentialConfig, uid []byte) (uint64, error) {
+	key, err := cfg.CardKey(uid)
+	if err != nil {
+		return 0, err
+	}
+
+	card := NewCard(t)
+
+	err = card.SelectApplication(cfg.AID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = card.AuthenticateAES(cfg.KeyNumber, key)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := card.ReadData(cfg.FileNumber, 0, CredentialLength)
+	if err != nil {
+		return 0, err
+	}
+
+	return VerifyCredential(cfg.SigningKey, cfg.AID, uid, data)
+}
+
+// SignCredential returns the contents of a credential file holding the credential number and its signature. The
+// signature is the truncated AES-CMAC of the application id, credential number and card UID, so a credential can not be
+// moved to another application or card or altered without the signing key.
+func SignCredential(signingKey []byte, aid uint32, uid []byte, number uint64) ([]byte, error) {
+	signature, err := sign(signingKey, aid, uid, number)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 8, CredentialLength)
+	binary.BigEndian.PutUint64(data, number)
+	return append(data, signature...), nil
+}
+
+// VerifyCredential checks the signature of the contents of a credential file read from the card with the provided UID
+// and returns the credential number.
+func VerifyCredential(signingKey []byte, aid uint32, uid []byte, data []byte) (uint64, error) {
+	if len(data) != CredentialLength {
+		return 0, errors.New(ErrShortResponse)
+	}
+
+	number := binary.BigEndian.Uint64(data[:8])
+	signature, err := sign(signingKey, aid, uid, number)
+	if err != nil {
+		return 0, err
+	}
+
+	if subtle.ConstantTimeCompare(signature, data[8:]) != 1 {
+		return 0, errors.New(ErrInvalidCredential)
+	}
+
+	return number, nil
+}
+
+func sign(signingKey []byte, aid uint32, uid []byte, number uint64) ([]byte, error) {
+	message := make([]byte, 11, 11+len(uid))
+	copy(message, uint24(int(aid)))
+	binary.BigEndian.PutUint64(message[3:], number)
+	message = append(message, uid...)
+
+	mac, err := CMAC(signingKey, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return mac[:signatureLength], nil
+}
@@ -0,0 +1,408 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package desfire implements the subset of the MIFARE DESFire EV1, EV2 and EV3 native command set that is used to read
+// secure credentials. Commands are wrapped in ISO 7816-4 APDUs and exchanged with the card through a Transceiver.
+package desfire
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	// ErrShortResponse is returned when the card responds with fewer bytes than the command requires.
+	ErrShortResponse = "the card returned a response that was too short"
+
+	// ErrAuthenticationFailed is returned when the card does not prove that it holds the same key as the reader.
+	ErrAuthenticationFailed = "the card failed mutual authentication"
+
+	// ErrInvalidKeyLength is returned when an AES key is not 16 bytes long.
+	ErrInvalidKeyLength = "aes keys must be 16 bytes"
+
+	// ErrInvalidAID is returned when an application id does not fit in 3 bytes.
+	ErrInvalidAID = "application ids must fit in 3 bytes"
+
+	// ErrNotAuthenticated is returned by ChangeKey when it does not immediately follow AES authentication.
+	ErrNotAuthenticated = "change key must immediately follow aes authentication"
+
+	// ErrWriteTooLong is returned when data does not fit in a single WriteData frame.
+	ErrWriteTooLong = "the data is too long to write in a single frame"
+)
+
+const (
+	// TransceiveTimeout is the timeout in milliseconds passed to the Transceiver for every command.
+	TransceiveTimeout = 500
+
+	// KeyLength is the length of an AES key in bytes.
+	KeyLength = 16
+
+	// MaxAID is the largest application id that can be selected.
+	MaxAID = 0xFFFFFF
+
+	// MaxWriteLength is the most data that WriteData can write, so that the command fits in a single frame.
+	MaxWriteLength = 40
+)
+
+// Native command codes.
+const (
+	CommandAuthenticateISO   byte = 0x1A
+	CommandAuthenticateAES   byte = 0xAA
+	CommandChangeKey         byte = 0xC4
+	CommandFormatPICC        byte = 0xFC
+	CommandCreateApplication byte = 0xCA
+	CommandSelectApplication byte = 0x5A
+	CommandCreateStdDataFile byte = 0xCD
+	CommandReadData          byte = 0xBD
+	CommandWriteData         byte = 0x3D
+	CommandAdditionalFrame   byte = 0xAF
+)
+
+// Communication modes of a file.
+const (
+	CommunicationPlain byte = 0x00
+)
+
+// KeyTypeAES is combined with the number of keys when creating an application that uses AES keys.
+const KeyTypeAES byte = 0x80
+
+// Status codes returned by the card.
+const (
+	StatusOK              byte = 0x00
+	StatusAdditionalFrame byte = 0xAF
+)
+
+// Transceiver exchanges raw frames with the selected card. It is satisfied by nfc.Device and scanner.LibNFCDevice.
+type Transceiver interface {
+	InitiatorTransceiveBytes(tx, rx []byte, timeout int) (int, error)
+}
+
+// StatusError is returned when the card responds to a command with an error status.
+type StatusError struct {
+	// Command is the native command code that failed.
+	Command byte
+
+	// Status is the status code returned by the card.
+	Status byte
+}
+
+// Error describes the failed command and status.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("desfire command %02x failed with status %02x", e.Command, e.Status)
+}
+
+// Card is a DESFire card that has been selected by the reader.
+type Card struct {
+	t          Transceiver
+	sessionKey []byte
+	keyNumber  byte
+
+	// fresh is true until a command is sent after AES authentication. ChangeKey relies on the IV being reset by the
+	// authentication, because the IV chaining of later commands is not tracked.
+	fresh bool
+}
+
+// NewCard provides a Card that sends commands through the provided Transceiver.
+func NewCard(t Transceiver) *Card {
+	return &Card{t: t}
+}
+
+// SelectApplication selects the application with the provided id. Selecting an application resets authentication.
+func (c *Card) SelectApplication(aid uint32) error {
+	if aid > MaxAID {
+		return errors.New(ErrInvalidAID)
+	}
+
+	c.sessionKey = nil
+	_, err := c.command(CommandSelectApplication, uint24(int(aid)))
+	return err
+}
+
+// AuthenticateAES performs AES mutual authentication with the provided key number and key in the selected
+// application. The card and the reader each prove that they hold the key by encrypting a random challenge from the
+// other.
+func (c *Card) AuthenticateAES(keyNumber byte, key []byte) error {
+	if len(key) != KeyLength {
+		return errors.New(ErrInvalidKeyLength)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	rndA, rndB, err := c.authenticate(CommandAuthenticateAES, keyNumber, block)
+	if err != nil {
+		return err
+	}
+
+	c.sessionKey = SessionKey(rndA, rndB)
+	c.keyNumber = keyNumber
+	c.fresh = true
+	return nil
+}
+
+// AuthenticateISO performs mutual authentication with the provided key number and 16 byte 2K3DES key in the selected
+// application. It is used with the PICC master key of blank cards, which is a DES key of all zeros.
+func (c *Card) AuthenticateISO(keyNumber byte, key []byte) error {
+	if len(key) != KeyLength {
+		return errors.New(ErrInvalidKeyLength)
+	}
+
+	block, err := des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+	if err != nil {
+		return err
+	}
+
+	rndA, rndB, err := c.authenticate(CommandAuthenticateISO, keyNumber, block)
+	if err != nil {
+		return err
+	}
+
+	c.sessionKey = append(append(append(append([]byte{}, rndA[0:4]...), rndB[0:4]...), rndA[4:8]...), rndB[4:8]...)
+	c.keyNumber = keyNumber
+	return nil
+}
+
+// authenticate runs the three pass mutual authentication shared by the AES and ISO commands and returns the random
+// numbers chosen by the reader and the card.
+func (c *Card) authenticate(cmd byte, keyNumber byte, block cipher.Block) ([]byte, []byte, error) {
+	c.sessionKey = nil
+	c.fresh = false
+	size := block.BlockSize()
+
+	encRndB, status, err := c.transceive(cmd, []byte{keyNumber})
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != StatusAdditionalFrame {
+		return nil, nil, &StatusError{Command: cmd, Status: status}
+	}
+	if len(encRndB) != size {
+		return nil, nil, errors.New(ErrShortResponse)
+	}
+
+	rndB := decryptCBC(block, make([]byte, size), encRndB)
+	rndA := make([]byte, size)
+	_, err = rand.Read(rndA)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := encryptCBC(block, encRndB, append(append([]byte{}, rndA...), rotate(rndB)...))
+	encRndA, status, err := c.transceive(CommandAdditionalFrame, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != StatusOK {
+		return nil, nil, &StatusError{Command: cmd, Status: status}
+	}
+	if len(encRndA) != size {
+		return nil, nil, errors.New(ErrShortResponse)
+	}
+
+	if !bytes.Equal(decryptCBC(block, token[size:], encRndA), rotate(rndA)) {
+		return nil, nil, errors.New(ErrAuthenticationFailed)
+	}
+
+	return rndA, rndB, nil
+}
+
+// Authenticated returns true if the last call to AuthenticateAES succeeded in the selected application.
+func (c *Card) Authenticated() bool {
+	return c.sessionKey != nil
+}
+
+// ReadData reads length bytes starting at offset from a standard data file in plain communication mode.
+func (c *Card) ReadData(file byte, offset int, length int) ([]byte, error) {
+	data, err := c.command(CommandReadData, append([]byte{file}, append(uint24(offset), uint24(length)...)...))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < length {
+		return nil, errors.New(ErrShortResponse)
+	}
+
+	return data[:length], nil
+}
+
+// FormatPICC deletes every application on the card. It requires authentication with the PICC master key.
+func (c *Card) FormatPICC() error {
+	_, err := c.command(CommandFormatPICC, nil)
+	return err
+}
+
+// CreateApplication creates an application with the provided key settings and number of AES keys. Every key of a new
+// application is an AES key of all zeros.
+func (c *Card) CreateApplication(aid uint32, keySettings byte, keys byte) error {
+	if aid > MaxAID {
+		return errors.New(ErrInvalidAID)
+	}
+
+	_, err := c.command(CommandCreateApplication, append(uint24(int(aid)), keySettings, KeyTypeAES|keys))
+	return err
+}
+
+// CreateStdDataFile creates a standard data file of the provided size in the selected application. See AccessRights
+// for how the access rights are encoded.
+func (c *Card) CreateStdDataFile(file byte, communication byte, accessRights uint16, size int) error {
+	data := []byte{file, communication, byte(accessRights), byte(accessRights >> 8)}
+	_, err := c.command(CommandCreateStdDataFile, append(data, uint24(size)...))
+	return err
+}
+
+// WriteData writes data starting at offset to a standard data file in plain communication mode.
+func (c *Card) WriteData(file byte, offset int, data []byte) error {
+	if len(data) > MaxWriteLength {
+		return errors.New(ErrWriteTooLong)
+	}
+
+	params := append([]byte{file}, append(uint24(offset), uint24(len(data))...)...)
+	_, err := c.command(CommandWriteData, append(params, data...))
+	return err
+}
+
+// ChangeKey changes an AES key of the selected application to newKey with the provided key version. oldKey is the
+// current value of the key and is only used when changing a key other than the one used to authenticate. ChangeKey
+// must be the first command after AuthenticateAES. Changing the key used to authenticate ends the session.
+func (c *Card) ChangeKey(keyNumber byte, newKey []byte, oldKey []byte, version byte) error {
+	if c.sessionKey == nil || !c.fresh {
+		return errors.New(ErrNotAuthenticated)
+	}
+	if len(newKey) != KeyLength || (keyNumber != c.keyNumber && len(oldKey) != KeyLength) {
+		return errors.New(ErrInvalidKeyLength)
+	}
+
+	key := append([]byte{}, newKey...)
+	if keyNumber != c.keyNumber {
+		xor(key, oldKey)
+	}
+
+	plaintext := append(key, version)
+	plaintext = append(plaintext, crc(append([]byte{CommandChangeKey, keyNumber}, plaintext...))...)
+	if keyNumber != c.keyNumber {
+		plaintext = append(plaintext, crc(newKey)...)
+	}
+	plaintext = append(plaintext, make([]byte, 2*aes.BlockSize-len(plaintext))...)
+
+	block, err := aes.NewCipher(c.sessionKey)
+	if err != nil {
+		return err
+	}
+
+	cryptogram := encryptCBC(block, make([]byte, aes.BlockSize), plaintext)
+	_, err = c.command(CommandChangeKey, append([]byte{keyNumber}, cryptogram...))
+	if keyNumber == c.keyNumber {
+		c.sessionKey = nil
+	}
+
+	return err
+}
+
+// AccessRights encodes the key numbers required to read, write, read and write, and change the settings of a file.
+// The key number 0xE allows free access and 0xF denies access.
+func AccessRights(read byte, write byte, readWrite byte, change byte) uint16 {
+	return uint16(read&0x0F)<<12 | uint16(write&0x0F)<<8 | uint16(readWrite&0x0F)<<4 | uint16(change&0x0F)
+}
+
+// command sends a native command and collects every frame of the response.
+func (c *Card) command(cmd byte, data []byte) ([]byte, error) {
+	c.fresh = false
+	response := []byte{}
+	next := cmd
+	for {
+		frame, status, err := c.transceive(next, data)
+		if err != nil {
+			return nil, err
+		}
+
+		response = append(response, frame...)
+		switch status {
+		case StatusOK:
+			return response, nil
+		case StatusAdditionalFrame:
+			next = CommandAdditionalFrame
+			data = nil
+		default:
+			return nil, &StatusError{Command: cmd, Status: status}
+		}
+	}
+}
+
+// transceive sends a single native command wrapped in an APDU and returns the response data and status.
+func (c *Card) transceive(cmd byte, data []byte) ([]byte, byte, error) {
+	tx := []byte{0x90, cmd, 0x00, 0x00}
+	if len(data) > 0 {
+		tx = append(tx, byte(len(data)))
+		tx = append(tx, data...)
+	}
+	tx = append(tx, 0x00)
+
+	rx := make([]byte, 264)
+	n, err := c.t.InitiatorTransceiveBytes(tx, rx, TransceiveTimeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n < 2 || rx[n-2] != 0x91 {
+		return nil, 0, errors.New(ErrShortResponse)
+	}
+
+	return rx[:n-2], rx[n-1], nil
+}
+
+// SessionKey derives the AES session key from the random numbers exchanged during authentication.
+func SessionKey(rndA []byte, rndB []byte) []byte {
+	key := make([]byte, 0, KeyLength)
+	key = append(key, rndA[0:4]...)
+	key = append(key, rndB[0:4]...)
+	key = append(key, rndA[12:16]...)
+	key = append(key, rndB[12:16]...)
+	return key
+}
+
+// rotate returns a copy of b rotated left by one byte, as required by the authentication protocol.
+func rotate(b []byte) []byte {
+	return append(append([]byte{}, b[1:]...), b[0])
+}
+
+// crc returns the little endian CRC32 used by DESFire, which is the IEEE CRC32 without the final inversion.
+func crc(data []byte) []byte {
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, ^crc32.ChecksumIEEE(data))
+	return out
+}
+
+func uint24(v int) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16)}
+}
+
+func encryptCBC(block cipher.Block, iv []byte, plaintext []byte) []byte {
+	out := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, plaintext)
+	return out
+}
+
+func decryptCBC(block cipher.Block, iv []byte, ciphertext []byte) []byte {
+	out := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, ciphertext)
+	return out
+}
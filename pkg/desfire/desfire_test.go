@@ -0,0 +1,194 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package desfire_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+	"github.com/betterengineering/open-keyless/pkg/desfire/desfiretest"
+)
+
+const testAID = 0xF51CD0
+
+var testUID = []byte{0x04, 0x78, 0x2e, 0x21, 0x80, 0x1d, 0x80}
+
+var (
+	testKey        = bytes.Repeat([]byte{0x11}, desfire.KeyLength)
+	testSigningKey = bytes.Repeat([]byte{0x22}, desfire.KeyLength)
+)
+
+func TestCMAC(t *testing.T) {
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	message, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+
+	// Test vectors from RFC 4493.
+	tests := map[int]string{
+		0:  "bb1d6929e95937287fa37d129b756746",
+		16: "070a16b46b4d4144f79bdd9dd04a287c",
+		40: "dfa66747de9ae63030ca32611497c827",
+	}
+
+	for length, expected := range tests {
+		mac, err := desfire.CMAC(key, message[:length])
+		if err != nil {
+			t.Fatalf("error computing cmac - %s", err)
+		}
+
+		if hex.EncodeToString(mac) != expected {
+			t.Errorf("expected cmac '%s' for a %d byte message but got '%x'", expected, length, mac)
+		}
+	}
+}
+
+func TestReadCredential(t *testing.T) {
+	card, err := givenProvisionedCard(42)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	number, err := desfire.ReadCredential(card, givenCredentialConfig(), testUID)
+	if err != nil {
+		t.Fatalf("error reading credential - %s", err)
+	}
+
+	if number != 42 {
+		t.Errorf("expected credential 42 but got %d", number)
+	}
+}
+
+func TestReadCredentialWithWrongKey(t *testing.T) {
+	card, err := givenProvisionedCard(42)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	cfg := givenCredentialConfig()
+	cfg.Key = bytes.Repeat([]byte{0x33}, desfire.KeyLength)
+
+	_, err = desfire.ReadCredential(card, cfg, testUID)
+	statusErr, ok := err.(*desfire.StatusError)
+	if !ok || statusErr.Status != desfiretest.StatusAuthenticationError {
+		t.Errorf("expected an authentication error but got '%v'", err)
+	}
+}
+
+func TestReadCredentialWithTamperedCredential(t *testing.T) {
+	card, err := givenProvisionedCard(42)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	data, err := desfire.SignCredential(testSigningKey, testAID, testUID, 42)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	data[7] = 43
+	card.AddApplication(testAID, &desfiretest.Application{
+		Keys:  [][]byte{testKey},
+		Files: map[byte][]byte{1: data},
+	})
+
+	_, err = desfire.ReadCredential(card, givenCredentialConfig(), testUID)
+	if err == nil || err.Error() != desfire.ErrInvalidCredential {
+		t.Errorf("expected '%s' but got '%v'", desfire.ErrInvalidCredential, err)
+	}
+}
+
+func TestReadCredentialFromAnotherCard(t *testing.T) {
+	card, err := givenProvisionedCard(42)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	// The credential was copied from the card with testUID to a card with another UID.
+	uid := []byte{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}
+	_, err = desfire.ReadCredential(card, givenCredentialConfig(), uid)
+	if err == nil || err.Error() != desfire.ErrInvalidCredential {
+		t.Errorf("expected '%s' but got '%v'", desfire.ErrInvalidCredential, err)
+	}
+}
+
+func TestReadCredentialWithMissingApplication(t *testing.T) {
+	card := desfiretest.NewCard()
+
+	_, err := desfire.ReadCredential(card, givenCredentialConfig(), testUID)
+	statusErr, ok := err.(*desfire.StatusError)
+	if !ok || statusErr.Status != desfiretest.StatusApplicationNotFound {
+		t.Errorf("expected an application not found error but got '%v'", err)
+	}
+}
+
+func TestCredentialConfigValidate(t *testing.T) {
+	cfg := givenCredentialConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected a valid config but got '%s'", err)
+	}
+
+	cfg.Key = []byte{0x01}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("expected an error for a short key")
+	}
+
+	cfg = givenCredentialConfig()
+	cfg.AID = 0x1000000
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("expected an error for an application id that does not fit in 3 bytes")
+	}
+}
+
+func givenCredentialConfig() desfire.CredentialConfig {
+	return desfire.CredentialConfig{
+		AID:        testAID,
+		KeyNumber:  0,
+		Key:        testKey,
+		FileNumber: 1,
+		SigningKey: testSigningKey,
+	}
+}
+
+func givenProvisionedCard(number uint64) (*desfiretest.Card, error) {
+	data, err := desfire.SignCredential(testSigningKey, testAID, testUID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	card := desfiretest.NewCard()
+	card.AddApplication(testAID, &desfiretest.Application{
+		Keys:  [][]byte{testKey},
+		Files: map[byte][]byte{1: data},
+	})
+
+	return card, nil
+}
+
+func TestDiversifyKey(t *testing.T) {
+	masterKey, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	uid, _ := hex.DecodeString("04782e21801d80")
+	systemID, _ := hex.DecodeString("4e585020416275")
+
+	// Test vector from NXP AN10922.
+	key, err := desfire.DiversifyKey(masterKey, desfire.DiversificationInput(uid, 0x3042F5, systemID))
+	if err != nil {
+		t.Fatalf("error diversifying key - %s", err)
+	}
+
+	if hex.EncodeToString(key) != "a8dd63a3b89d54b37ca802473fda9175" {
+		t.Errorf("expected key 'a8dd63a3b89d54b37ca802473fda9175' but got '%x'", key)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package desfiretest provides a simulated DESFire card for testing code that talks to cards through a
+// desfire.Transceiver.
+package desfiretest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sync"
+
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+)
+
+// Status codes returned by the simulated card.
+const (
+	StatusAuthenticationError byte = 0xAE
+	StatusPermissionDenied    byte = 0x9D
+	StatusApplicationNotFound byte = 0xA0
+	StatusFileNotFound        byte = 0xF0
+	StatusLengthError         byte = 0x7E
+	StatusBoundaryError       byte = 0xBE
+	StatusNoSuchKey           byte = 0x40
+	StatusIllegalCommand      byte = 0x1C
+	StatusIntegrityError      byte = 0x1E
+	StatusDuplicateError      byte = 0xDE
+)
+
+// Application is a simulated application on the card.
+type Application struct {
+	// Keys are the AES keys of the application, indexed by key number.
+	Keys [][]byte
+
+	// Files are the contents of the standard data files in the application, indexed by file number.
+	Files map[byte][]byte
+
+	// ReadKey is the number of the key that must be authenticated before a file can be read.
+	ReadKey byte
+}
+
+// Card is a simulated DESFire card. The PICC level application 000000 has a single DES master key of all zeros, like a
+// blank card, and files are written with key 0. Card implements desfire.Transceiver, so it can be used directly or
+// with DoAndReturn on a mock device.
+type Card struct {
+	mu           sync.Mutex
+	applications map[uint32]*Application
+	selected     uint32
+	authKey      int
+	pendingKey   int
+	authBlock    cipher.Block
+	rndB         []byte
+	lastSent     []byte
+	sessionKey   []byte
+	fresh        bool
+}
+
+// NewCard provides a simulated blank card.
+func NewCard() *Card {
+	c := &Card{
+		applications: map[uint32]*Application{},
+		authKey:      -1,
+		pendingKey:   -1,
+	}
+	c.applications[0] = &Application{Keys: [][]byte{make([]byte, desfire.KeyLength)}}
+
+	return c
+}
+
+// AddApplication adds an application to the card.
+func (c *Card) AddApplication(aid uint32, app *Application) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if app.Files == nil {
+		app.Files = map[byte][]byte{}
+	}
+	c.applications[aid] = app
+}
+
+// Application returns the application with the provided id, or nil if it does not exist.
+func (c *Card) Application(aid uint32) *Application {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.applications[aid]
+}
+
+// InitiatorTransceiveBytes handles a single APDU wrapped native command and writes the response to rx.
+func (c *Card) InitiatorTransceiveBytes(tx []byte, rx []byte, timeout int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(tx) < 5 || tx[0] != 0x90 {
+		return 0, errors.New("desfiretest: malformed apdu")
+	}
+
+	var data []byte
+	if len(tx) > 5 {
+		data = tx[5 : 5+int(tx[4])]
+	}
+
+	response, status := c.handle(tx[1], data)
+	n := copy(rx, response)
+	n += copy(rx[n:], []byte{0x91, status})
+	return n, nil
+}
+
+func (c *Card) handle(cmd byte, data []byte) ([]byte, byte) {
+	if cmd != desfire.CommandAdditionalFrame {
+		c.pendingKey = -1
+	}
+
+	fresh := c.fresh
+	c.fresh = false
+
+	switch cmd {
+	case desfire.CommandSelectApplication:
+		return c.selectApplication(data)
+	case desfire.CommandAuthenticateISO, desfire.CommandAuthenticateAES:
+		return c.authenticate(cmd, data)
+	case desfire.CommandAdditionalFrame:
+		if c.pendingKey < 0 {
+			return nil, StatusIllegalCommand
+		}
+		return c.completeAuthentication(data)
+	case desfire.CommandReadData:
+		return c.readData(data)
+	case desfire.CommandFormatPICC:
+		return c.format()
+	case desfire.CommandCreateApplication:
+		return c.createApplication(data)
+	case desfire.CommandCreateStdDataFile:
+		return c.createStdDataFile(data)
+	case desfire.CommandWriteData:
+		return c.writeData(data)
+	case desfire.CommandChangeKey:
+		return c.changeKey(data, fresh)
+	}
+
+	return nil, StatusIllegalCommand
+}
+
+func (c *Card) selectApplication(data []byte) ([]byte, byte) {
+	if len(data) != 3 {
+		return nil, StatusLengthError
+	}
+
+	aid := uint24(data)
+	if _, ok := c.applications[aid]; !ok {
+		return nil, StatusApplicationNotFound
+	}
+
+	c.selected = aid
+	c.authKey = -1
+	return nil, desfire.StatusOK
+}
+
+func (c *Card) authenticate(cmd byte, data []byte) ([]byte, byte) {
+	c.authKey = -1
+	if len(data) != 1 {
+		return nil, StatusLengthError
+	}
+
+	app := c.applications[c.selected]
+	if int(data[0]) >= len(app.Keys) {
+		return nil, StatusNoSuchKey
+	}
+
+	key := app.Keys[data[0]]
+	var block cipher.Block
+	var err error
+	if cmd == desfire.CommandAuthenticateAES {
+		block, err = aes.NewCipher(key)
+	} else {
+		block, err = des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+	}
+	if err != nil {
+		return nil, StatusNoSuchKey
+	}
+
+	c.rndB = make([]byte, block.BlockSize())
+	rand.Read(c.rndB)
+	c.lastSent = make([]byte, block.BlockSize())
+	cipher.NewCBCEncrypter(block, make([]byte, block.BlockSize())).CryptBlocks(c.lastSent, c.rndB)
+
+	c.authBlock = block
+	c.pendingKey = int(data[0])
+	return c.lastSent, desfire.StatusAdditionalFrame
+}
+
+func (c *Card) completeAuthentication(data []byte) ([]byte, byte) {
+	keyNumber := c.pendingKey
+	c.pendingKey = -1
+	size := c.authBlock.BlockSize()
+	if len(data) != 2*size {
+		return nil, StatusLengthError
+	}
+
+	token := make([]byte, len(data))
+	cipher.NewCBCDecrypter(c.authBlock, c.lastSent).CryptBlocks(token, data)
+
+	rndA := token[:size]
+	if !bytes.Equal(token[size:], rotate(c.rndB)) {
+		return nil, StatusAuthenticationError
+	}
+
+	response := make([]byte, size)
+	cipher.NewCBCEncrypter(c.authBlock, data[size:]).CryptBlocks(response, rotate(rndA))
+
+	c.authKey = keyNumber
+	c.sessionKey = nil
+	if size == aes.BlockSize {
+		c.sessionKey = desfire.SessionKey(rndA, c.rndB)
+		c.fresh = true
+	}
+
+	return response, desfire.StatusOK
+}
+
+func (c *Card) readData(data []byte) ([]byte, byte) {
+	if len(data) != 7 {
+		return nil, StatusLengthError
+	}
+
+	app := c.applications[c.selected]
+	file, ok := app.Files[data[0]]
+	if !ok {
+		return nil, StatusFileNotFound
+	}
+	if c.authKey != int(app.ReadKey) {
+		return nil, StatusAuthenticationError
+	}
+
+	offset := int(uint24(data[1:4]))
+	length := int(uint24(data[4:7]))
+	if length == 0 {
+		length = len(file) - offset
+	}
+	if offset+length > len(file) {
+		return nil, StatusBoundaryError
+	}
+
+	return file[offset : offset+length], desfire.StatusOK
+}
+
+func (c *Card) format() ([]byte, byte) {
+	if c.selected != 0 || c.authKey != 0 {
+		return nil, StatusAuthenticationError
+	}
+
+	for aid := range c.applications {
+		if aid != 0 {
+			delete(c.applications, aid)
+		}
+	}
+
+	return nil, desfire.StatusOK
+}
+
+func (c *Card) createApplication(data []byte) ([]byte, byte) {
+	if len(data) != 5 {
+		return nil, StatusLengthError
+	}
+	if c.selected != 0 {
+		return nil, StatusPermissionDenied
+	}
+
+	aid := uint24(data)
+	if _, ok := c.applications[aid]; ok {
+		return nil, StatusDuplicateError
+	}
+
+	keys := make([][]byte, data[4]&0x0F)
+	for i := range keys {
+		keys[i] = make([]byte, desfire.KeyLength)
+	}
+
+	c.applications[aid] = &Application{Keys: keys, Files: map[byte][]byte{}}
+	return nil, desfire.StatusOK
+}
+
+func (c *Card) createStdDataFile(data []byte) ([]byte, byte) {
+	if len(data) != 7 {
+		return nil, StatusLengthError
+	}
+	if c.selected == 0 || c.authKey != 0 {
+		return nil, StatusAuthenticationError
+	}
+
+	app := c.applications[c.selected]
+	if _, ok := app.Files[data[0]]; ok {
+		return nil, StatusDuplicateError
+	}
+
+	app.Files[data[0]] = make([]byte, uint24(data[4:7]))
+	app.ReadKey = data[3] >> 4
+	return nil, desfire.StatusOK
+}
+
+func (c *Card) writeData(data []byte) ([]byte, byte) {
+	if len(data) < 7 {
+		return nil, StatusLengthError
+	}
+	if c.selected == 0 || c.authKey != 0 {
+		return nil, StatusAuthenticationError
+	}
+
+	file, ok := c.applications[c.selected].Files[data[0]]
+	if !ok {
+		return nil, StatusFileNotFound
+	}
+
+	offset := int(uint24(data[1:4]))
+	length := int(uint24(data[4:7]))
+	if len(data) != 7+length {
+		return nil, StatusLengthError
+	}
+	if offset+length > len(file) {
+		return nil, StatusBoundaryError
+	}
+
+	copy(file[offset:], data[7:])
+	return nil, desfire.StatusOK
+}
+
+func (c *Card) changeKey(data []byte, fresh bool) ([]byte, byte) {
+	if len(data) != 1+2*aes.BlockSize {
+		return nil, StatusLengthError
+	}
+	if c.sessionKey == nil || c.selected == 0 || !fresh {
+		return nil, StatusAuthenticationError
+	}
+
+	app := c.applications[c.selected]
+	keyNumber := data[0]
+	if int(keyNumber) >= len(app.Keys) {
+		return nil, StatusNoSuchKey
+	}
+
+	block, _ := aes.NewCipher(c.sessionKey)
+	plaintext := make([]byte, 2*aes.BlockSize)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(plaintext, data[1:])
+
+	key := append([]byte{}, plaintext[:desfire.KeyLength]...)
+	checked := append([]byte{desfire.CommandChangeKey, keyNumber}, plaintext[:desfire.KeyLength+1]...)
+	if !bytes.Equal(plaintext[17:21], crc(checked)) {
+		return nil, StatusIntegrityError
+	}
+
+	if int(keyNumber) != c.authKey {
+		for i := range key {
+			key[i] ^= app.Keys[keyNumber][i]
+		}
+		if !bytes.Equal(plaintext[21:25], crc(key)) {
+			return nil, StatusIntegrityError
+		}
+	} else {
+		c.authKey = -1
+		c.sessionKey = nil
+	}
+
+	app.Keys[keyNumber] = key
+	return nil, desfire.StatusOK
+}
+
+func crc(data []byte) []byte {
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, ^crc32.ChecksumIEEE(data))
+	return out
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func rotate(b []byte) []byte {
+	return append(append([]byte{}, b[1:]...), b[0])
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package desfire
+
+import (
+	"errors"
+)
+
+const (
+	// ErrDiversificationInputTooLong is returned when the diversification input is longer than AN10922 allows.
+	ErrDiversificationInputTooLong = "the diversification input must be at most 31 bytes"
+
+	// maxDiversificationInput is the longest diversification input allowed by AN10922 for AES-128 keys.
+	maxDiversificationInput = 31
+)
+
+// DiversifyKey derives a card specific AES-128 key from the master key and diversification input using the CMAC based
+// method of NXP AN10922. A card that is compromised only reveals its own keys, not the master key or the keys of other
+// cards.
+func DiversifyKey(masterKey []byte, input []byte) ([]byte, error) {
+	if len(input) > maxDiversificationInput {
+		return nil, errors.New(ErrDiversificationInputTooLong)
+	}
+
+	return cmac(masterKey, append([]byte{0x01}, input...), 2)
+}
+
+// DiversificationInput builds the diversification input for a card from its UID, the application id and a system
+// identifier, in the order used by AN10922.
+func DiversificationInput(uid []byte, aid uint32, systemID []byte) []byte {
+	input := append([]byte{}, uid...)
+	input = append(input, byte(aid>>16), byte(aid>>8), byte(aid))
+	return append(input, systemID...)
+}
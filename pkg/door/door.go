@@ -0,0 +1,350 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package door monitors a door position sensor, such as a reed switch, wired to a Raspberry Pi GPIO input. It reports
+// when the door opens and closes and raises an alarm when the door is held open for too long or is opened without
+// first being granted access.
+package door
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/host"
+)
+
+const (
+	// ErrCouldNotInitializeGPIOPin is returned when the GPIO pin for the door sensor or request to exit can not be
+	// initialized.
+	ErrCouldNotInitializeGPIOPin = "could not initialize the GPIO pin for the door"
+
+	// EventOpened is sent when the door opens after access was granted.
+	EventOpened = "opened"
+
+	// EventClosed is sent when the door closes.
+	EventClosed = "closed"
+
+	// AlarmForcedOpen is sent when the door opens without access being granted first.
+	AlarmForcedOpen = "forced open"
+
+	// AlarmHeldOpen is sent when the door stays open for longer than the held open threshold.
+	AlarmHeldOpen = "held open"
+
+	// EventRequestToExit is sent when the request to exit button is pressed or motion sensor is triggered.
+	EventRequestToExit = "request to exit"
+
+	// DefaultDebounce is how long the sensor must settle after an edge when a debounce is not configured.
+	DefaultDebounce = 50 * time.Millisecond
+
+	// DefaultHeldOpenThreshold is how long the door can stay open before the held open alarm is raised when a
+	// threshold is not configured.
+	DefaultHeldOpenThreshold = 30 * time.Second
+
+	// edgeTimeout is how long the monitor waits for an edge before checking if it was stopped or the door has been
+	// held open.
+	edgeTimeout = 100 * time.Millisecond
+)
+
+var (
+	doorOpenGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "open_keyless_door_open",
+			Help: "Whether the door is currently open, 1 for open and 0 for closed.",
+		},
+	)
+	doorAlarmCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "open_keyless_door_alarms_total",
+			Help: "The total count of door alarms raised by alarm.",
+		},
+		[]string{"alarm"},
+	)
+	doorAlarmActiveGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "open_keyless_door_alarm_active",
+			Help: "Whether a door alarm is currently active, 1 for active and 0 for cleared.",
+		},
+		[]string{"alarm"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(doorOpenGauge)
+	prometheus.MustRegister(doorAlarmCounter)
+	prometheus.MustRegister(doorAlarmActiveGauge)
+}
+
+// Config is a configuration struct for a Monitor.
+type Config struct {
+	// Pin is the name of the GPIO pin the door sensor is connected to. Ex "20" or "GPIO20".
+	Pin string
+
+	// ActiveLow is true when the pin reads low while the door is open. The pin is pulled up, so by default it reads
+	// high while the door is open, which matches a reed switch wired to ground that closes while the door is closed.
+	ActiveLow bool
+
+	// Debounce is how long the sensor must settle after an edge before it is read. Defaults to DefaultDebounce.
+	Debounce time.Duration
+
+	// HeldOpenThreshold is how long the door can stay open before the held open alarm is raised. Defaults to
+	// DefaultHeldOpenThreshold.
+	HeldOpenThreshold time.Duration
+}
+
+// Event is sent by a Monitor every time the door opens or closes or an alarm is raised, and by a Rex every time an exit
+// is requested.
+type Event struct {
+	// Type is one of EventOpened, EventClosed, EventRequestToExit, AlarmForcedOpen or AlarmHeldOpen.
+	Type string
+
+	// Timestamp is the time the event happened.
+	Timestamp time.Time
+
+	// OpenFor is how long the door was open for. It is only set on EventClosed and AlarmHeldOpen events.
+	OpenFor time.Duration
+}
+
+// Alarm returns true if the event is an alarm.
+func (e Event) Alarm() bool {
+	return e.Type == AlarmForcedOpen || e.Type == AlarmHeldOpen
+}
+
+// Monitor watches a door position sensor and sends an Event for every change in the state of the door.
+type Monitor struct {
+	pin       gpio.PinIn
+	openLevel gpio.Level
+	debounce  time.Duration
+	heldOpen  time.Duration
+	events    chan Event
+	cancel    context.CancelFunc
+	wg        *sync.WaitGroup
+	started   bool
+
+	mu              sync.Mutex
+	authorizedUntil time.Time
+
+	open        bool
+	openedAt    time.Time
+	heldAlarmed bool
+}
+
+// OpenMonitor opens the GPIO pin described by the provided configuration and provides an initialized Monitor for it.
+// See NewMonitor for how the channel is used.
+func OpenMonitor(cfg Config, events chan Event) (*Monitor, error) {
+	p, err := openPin(cfg.Pin)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMonitor(p, cfg, events)
+}
+
+// NewMonitor provides an initialized Monitor for the provided pin. Events are written to the provided channel, which
+// should be buffered, otherwise the monitor will block until events are read off of it. Call Start to begin
+// monitoring the door.
+func NewMonitor(pin gpio.PinIn, cfg Config, events chan Event) (*Monitor, error) {
+	openLevel, err := configureInput(pin, cfg.ActiveLow)
+	if err != nil {
+		return nil, err
+	}
+
+	debounce := cfg.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	heldOpen := cfg.HeldOpenThreshold
+	if heldOpen <= 0 {
+		heldOpen = DefaultHeldOpenThreshold
+	}
+
+	var wg sync.WaitGroup
+
+	return &Monitor{
+		pin:       pin,
+		openLevel: openLevel,
+		debounce:  debounce,
+		heldOpen:  heldOpen,
+		events:    events,
+		wg:        &wg,
+	}, nil
+}
+
+// openPin initializes the host and returns the GPIO pin with the provided name.
+func openPin(name string) (gpio.PinIO, error) {
+	_, err := host.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	p := gpioreg.ByName(name)
+	if p == nil {
+		return nil, errors.New(ErrCouldNotInitializeGPIOPin)
+	}
+
+	return p, nil
+}
+
+// configureInput configures the pin as a pulled up input with edge detection and returns the level the pin reads while
+// it is active.
+func configureInput(pin gpio.PinIn, activeLow bool) (gpio.Level, error) {
+	active := gpio.High
+	if activeLow {
+		active = gpio.Low
+	}
+
+	return active, pin.In(gpio.PullUp, gpio.BothEdges)
+}
+
+// Start starts monitoring the door if it has not already been started. A door that is already open when the monitor
+// starts is treated as opened with access. The monitor stops once the provided context is cancelled or Done is
+// called.
+func (m *Monitor) Start(ctx context.Context) {
+	if m.started {
+		return
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.started = true
+	m.wg.Add(1)
+	go m.run(ctx, m.pin.Read() == m.openLevel)
+}
+
+// Done stops monitoring the door.
+func (m *Monitor) Done() {
+	if !m.started {
+		return
+	}
+
+	m.cancel()
+	m.wg.Wait()
+	m.started = false
+}
+
+// Authorize allows the door to be opened within the provided duration without raising the forced open alarm. It
+// should be called every time the strike is unlocked. Authorize is thread safe.
+func (m *Monitor) Authorize(dur time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until := time.Now().Add(dur)
+	if until.After(m.authorizedUntil) {
+		m.authorizedUntil = until
+	}
+}
+
+func (m *Monitor) run(ctx context.Context, open bool) {
+	defer m.wg.Done()
+
+	if open {
+		m.opened(ctx, time.Now(), true)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if m.pin.WaitForEdge(edgeTimeout) {
+			// Wait for the switch to settle so that a bouncing contact is only reported once.
+			time.Sleep(m.debounce)
+			m.update(ctx, m.pin.Read() == m.openLevel, time.Now())
+		}
+
+		m.checkHeldOpen(ctx, time.Now())
+	}
+}
+
+func (m *Monitor) update(ctx context.Context, open bool, now time.Time) {
+	if open == m.open {
+		return
+	}
+
+	if open {
+		m.opened(ctx, now, m.authorized(now))
+		return
+	}
+
+	m.closed(ctx, now)
+}
+
+func (m *Monitor) opened(ctx context.Context, now time.Time, authorized bool) {
+	m.open = true
+	m.openedAt = now
+	doorOpenGauge.Set(1)
+
+	if authorized {
+		m.send(ctx, Event{Type: EventOpened, Timestamp: now})
+		return
+	}
+
+	doorAlarmCounter.WithLabelValues(AlarmForcedOpen).Inc()
+	doorAlarmActiveGauge.WithLabelValues(AlarmForcedOpen).Set(1)
+	m.send(ctx, Event{Type: AlarmForcedOpen, Timestamp: now})
+}
+
+func (m *Monitor) closed(ctx context.Context, now time.Time) {
+	openFor := now.Sub(m.openedAt)
+	m.open = false
+	m.heldAlarmed = false
+	doorOpenGauge.Set(0)
+	doorAlarmActiveGauge.WithLabelValues(AlarmForcedOpen).Set(0)
+	doorAlarmActiveGauge.WithLabelValues(AlarmHeldOpen).Set(0)
+
+	// The access that was granted has been used, so opening the door again requires access to be granted again.
+	m.mu.Lock()
+	m.authorizedUntil = time.Time{}
+	m.mu.Unlock()
+
+	m.send(ctx, Event{Type: EventClosed, Timestamp: now, OpenFor: openFor})
+}
+
+func (m *Monitor) checkHeldOpen(ctx context.Context, now time.Time) {
+	if !m.open || m.heldAlarmed {
+		return
+	}
+
+	openFor := now.Sub(m.openedAt)
+	if openFor < m.heldOpen {
+		return
+	}
+
+	m.heldAlarmed = true
+	doorAlarmCounter.WithLabelValues(AlarmHeldOpen).Inc()
+	doorAlarmActiveGauge.WithLabelValues(AlarmHeldOpen).Set(1)
+	m.send(ctx, Event{Type: AlarmHeldOpen, Timestamp: now, OpenFor: openFor})
+}
+
+func (m *Monitor) authorized(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return !now.After(m.authorizedUntil)
+}
+
+// send sends the event unless the monitor is stopped first.
+func (m *Monitor) send(ctx context.Context, event Event) {
+	select {
+	case m.events <- event:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package door_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/door"
+	"github.com/golang/mock/gomock"
+	"periph.io/x/periph/conn/gpio"
+)
+
+// fakeInput simulates a switch behind a mock pin.
+type fakeInput struct {
+	mu    sync.Mutex
+	level gpio.Level
+	edges chan bool
+}
+
+func givenInput(ctrl *gomock.Controller, level gpio.Level) (*mocks.MockPinIO, *fakeInput) {
+	d := &fakeInput{level: level, edges: make(chan bool, 10)}
+
+	pin := mocks.NewMockPinIO(ctrl)
+	pin.EXPECT().In(gpio.PullUp, gpio.BothEdges).Return(nil).Times(1)
+	pin.EXPECT().Read().DoAndReturn(func() gpio.Level {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.level
+	}).AnyTimes()
+	pin.EXPECT().WaitForEdge(gomock.Any()).DoAndReturn(func(timeout time.Duration) bool {
+		select {
+		case <-d.edges:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}).AnyTimes()
+
+	return pin, d
+}
+
+func (d *fakeInput) set(level gpio.Level) {
+	d.mu.Lock()
+	d.level = level
+	d.mu.Unlock()
+	d.edges <- true
+}
+
+func expectEvent(t *testing.T, events chan door.Event, eventType string) door.Event {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		if event.Type != eventType {
+			t.Fatalf("expected event '%s' but got '%s'", eventType, event.Type)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("expected event '%s' but got none", eventType)
+	}
+
+	return door.Event{}
+}
+
+func TestMonitorOpenedAndClosed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pin, d := givenInput(ctrl, gpio.Low)
+	events := make(chan door.Event, 10)
+	m, err := door.NewMonitor(pin, door.Config{Debounce: time.Millisecond}, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	m.Start(context.Background())
+	defer m.Done()
+
+	m.Authorize(time.Second)
+	d.set(gpio.High)
+	expectEvent(t, events, door.EventOpened)
+
+	d.set(gpio.Low)
+	event := expectEvent(t, events, door.EventClosed)
+	if event.OpenFor <= 0 {
+		t.Errorf("expected the closed event to include how long the door was open")
+	}
+
+	// The grant was used up when the door closed, so opening it again is forced.
+	d.set(gpio.High)
+	expectEvent(t, events, door.AlarmForcedOpen)
+}
+
+func TestMonitorForcedOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pin, d := givenInput(ctrl, gpio.Low)
+	events := make(chan door.Event, 10)
+	m, err := door.NewMonitor(pin, door.Config{Debounce: time.Millisecond}, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	m.Start(context.Background())
+	defer m.Done()
+
+	d.set(gpio.High)
+	event := expectEvent(t, events, door.AlarmForcedOpen)
+	if !event.Alarm() {
+		t.Errorf("expected forced open to be an alarm")
+	}
+}
+
+func TestMonitorDebounce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pin, d := givenInput(ctrl, gpio.Low)
+	events := make(chan door.Event, 10)
+	m, err := door.NewMonitor(pin, door.Config{Debounce: 20 * time.Millisecond}, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	m.Start(context.Background())
+	defer m.Done()
+
+	// The contact bounces and settles closed within the debounce, so nothing is reported.
+	m.Authorize(time.Second)
+	d.set(gpio.High)
+	d.set(gpio.Low)
+
+	select {
+	case event := <-events:
+		t.Errorf("expected no events for a bouncing contact but got '%s'", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMonitorHeldOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pin, d := givenInput(ctrl, gpio.High)
+	events := make(chan door.Event, 10)
+	cfg := door.Config{Debounce: time.Millisecond, HeldOpenThreshold: 10 * time.Millisecond}
+	m, err := door.NewMonitor(pin, cfg, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	m.Start(context.Background())
+	defer m.Done()
+
+	// A door that is open when the monitor starts is not forced.
+	expectEvent(t, events, door.EventOpened)
+	event := expectEvent(t, events, door.AlarmHeldOpen)
+	if event.OpenFor < 10*time.Millisecond {
+		t.Errorf("expected the door to be open for at least the threshold but got %s", event.OpenFor)
+	}
+
+	d.set(gpio.Low)
+	expectEvent(t, events, door.EventClosed)
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package door
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"periph.io/x/periph/conn/gpio"
+)
+
+const (
+	// DefaultRexUnlockDuration is how long the strike is unlocked for a request to exit when a duration is not
+	// configured.
+	DefaultRexUnlockDuration = 5 * time.Second
+)
+
+var (
+	rexCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_door_request_to_exit_total",
+			Help: "The total count of exits requested with the request to exit button or motion sensor.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(rexCounter)
+}
+
+// RexConfig is a configuration struct for a Rex.
+type RexConfig struct {
+	// Pin is the name of the GPIO pin the request to exit button or motion sensor is connected to. Ex "21".
+	Pin string
+
+	// ActiveLow is true when the pin reads low while the button is pressed or motion is detected, such as with a
+	// button wired to ground. The pin is pulled up.
+	ActiveLow bool
+
+	// Debounce is how long the input must settle after an edge before it is read. Defaults to DefaultDebounce.
+	Debounce time.Duration
+}
+
+// Authorizer allows the door to be opened for a duration without raising the forced open alarm. It is implemented by
+// Monitor.
+type Authorizer interface {
+	Authorize(dur time.Duration)
+}
+
+// Rex watches a request to exit button or motion sensor and sends an EventRequestToExit every time it becomes active.
+type Rex struct {
+	pin          gpio.PinIn
+	activeLevel  gpio.Level
+	debounce     time.Duration
+	events       chan Event
+	authorizer   Authorizer
+	authorizeFor time.Duration
+	cancel       context.CancelFunc
+	wg           *sync.WaitGroup
+	started      bool
+}
+
+// OpenRex opens the GPIO pin described by the provided configuration and provides an initialized Rex for it. See
+// NewRex for how the channel is used.
+func OpenRex(cfg RexConfig, events chan Event) (*Rex, error) {
+	p, err := openPin(cfg.Pin)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRex(p, cfg, events)
+}
+
+// NewRex provides an initialized Rex for the provided pin. Events are written to the provided channel, which should be
+// buffered, otherwise the Rex will block until events are read off of it. Call Start to begin watching the input.
+func NewRex(pin gpio.PinIn, cfg RexConfig, events chan Event) (*Rex, error) {
+	activeLevel, err := configureInput(pin, cfg.ActiveLow)
+	if err != nil {
+		return nil, err
+	}
+
+	debounce := cfg.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	var wg sync.WaitGroup
+
+	return &Rex{
+		pin:         pin,
+		activeLevel: activeLevel,
+		debounce:    debounce,
+		events:      events,
+		wg:          &wg,
+	}, nil
+}
+
+// AuthorizeDoor makes the Rex authorize the provided door while its input is active and for the provided duration after
+// it is released. The door can then be opened for an exit without raising the forced open alarm, even if the request to
+// exit event is delayed or the strike fails to unlock. It must be called before Start.
+func (r *Rex) AuthorizeDoor(authorizer Authorizer, dur time.Duration) {
+	r.authorizer = authorizer
+	r.authorizeFor = dur
+}
+
+// Start starts watching the input if it has not already been started. The Rex stops once the provided context is
+// cancelled or Done is called.
+func (r *Rex) Start(ctx context.Context) {
+	if r.started {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.started = true
+	r.wg.Add(1)
+	go r.run(ctx, r.pin.Read() == r.activeLevel)
+}
+
+// Done stops watching the input.
+func (r *Rex) Done() {
+	if !r.started {
+		return
+	}
+
+	r.cancel()
+	r.wg.Wait()
+	r.started = false
+}
+
+func (r *Rex) run(ctx context.Context, active bool) {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if !r.pin.WaitForEdge(edgeTimeout) {
+			// Keep the door authorized while the input is held active, such as by a motion sensor that still sees
+			// someone at the door. The margin covers the time until the input is checked again.
+			if active {
+				r.authorize(r.authorizeFor + 2*edgeTimeout)
+			}
+			continue
+		}
+
+		// Authorize the door before debouncing so that a door pushed right after the button is pressed is never
+		// reported as forced open.
+		if r.pin.Read() == r.activeLevel {
+			r.authorize(r.authorizeFor)
+		}
+
+		// Wait for the input to settle so that a bouncing contact only requests a single exit.
+		time.Sleep(r.debounce)
+
+		wasActive := active
+		active = r.pin.Read() == r.activeLevel
+		if active || wasActive {
+			// The door stays authorized for the full duration after the input is released.
+			r.authorize(r.authorizeFor)
+		}
+
+		if !active || wasActive {
+			continue
+		}
+
+		rexCounter.Inc()
+
+		select {
+		case r.events <- Event{Type: EventRequestToExit, Timestamp: time.Now()}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Rex) authorize(dur time.Duration) {
+	if r.authorizer != nil {
+		r.authorizer.Authorize(dur)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package door_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/door"
+	"github.com/golang/mock/gomock"
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestRex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The button is wired to ground, so the pin reads high until it is pressed.
+	pin, button := givenInput(ctrl, gpio.High)
+	events := make(chan door.Event, 10)
+	rex, err := door.NewRex(pin, door.RexConfig{ActiveLow: true, Debounce: time.Millisecond}, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	rex.Start(context.Background())
+	defer rex.Done()
+
+	button.set(gpio.Low)
+	expectEvent(t, events, door.EventRequestToExit)
+
+	// Releasing the button does not request another exit.
+	button.set(gpio.High)
+	select {
+	case event := <-events:
+		t.Errorf("expected no event when the button is released but got '%s'", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	button.set(gpio.Low)
+	expectEvent(t, events, door.EventRequestToExit)
+}
+
+func TestRexDebounce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pin, button := givenInput(ctrl, gpio.High)
+	events := make(chan door.Event, 10)
+	rex, err := door.NewRex(pin, door.RexConfig{ActiveLow: true, Debounce: 20 * time.Millisecond}, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	rex.Start(context.Background())
+	defer rex.Done()
+
+	// The contact bounces while the button is pressed, which only requests a single exit.
+	button.set(gpio.Low)
+	button.set(gpio.High)
+	button.set(gpio.Low)
+	expectEvent(t, events, door.EventRequestToExit)
+
+	select {
+	case event := <-events:
+		t.Errorf("expected a single event for a bouncing contact but got '%s'", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRexAuthorizesDoor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sensorPin, sensor := givenInput(ctrl, gpio.Low)
+	doorEvents := make(chan door.Event, 10)
+	m, err := door.NewMonitor(sensorPin, door.Config{Debounce: time.Millisecond}, doorEvents)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	pin, button := givenInput(ctrl, gpio.High)
+	events := make(chan door.Event, 10)
+	rex, err := door.NewRex(pin, door.RexConfig{ActiveLow: true, Debounce: time.Millisecond}, events)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	rex.AuthorizeDoor(m, time.Second)
+
+	m.Start(context.Background())
+	defer m.Done()
+	rex.Start(context.Background())
+	defer rex.Done()
+
+	// Nothing else authorizes the door, as when the strike fails to unlock for the request to exit, so only the Rex
+	// keeps the exit from being reported as forced open.
+	button.set(gpio.Low)
+	sensor.set(gpio.High)
+	expectEvent(t, doorEvents, door.EventOpened)
+	expectEvent(t, events, door.EventRequestToExit)
+}
+
+func TestRexAuthorizesDoorWhileActive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sensorPin, sensor := givenInput(ctrl, gpio.Low)
+	doorEvents := make(chan door.Event, 10)
+	m, err := door.NewMonitor(sensorPin, door.Config{Debounce: time.Millisecond}, doorEvents)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	pin, motion := givenInput(ctrl, gpio.Low)
+	rex, err := door.NewRex(pin, door.RexConfig{Debounce: time.Millisecond}, make(chan door.Event, 10))
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	rex.AuthorizeDoor(m, 50*time.Millisecond)
+
+	m.Start(context.Background())
+	defer m.Done()
+	rex.Start(context.Background())
+	defer rex.Done()
+
+	// The motion sensor stays active for longer than the duration before the door is opened.
+	motion.set(gpio.High)
+	time.Sleep(300 * time.Millisecond)
+	sensor.set(gpio.High)
+	expectEvent(t, doorEvents, door.EventOpened)
+	sensor.set(gpio.Low)
+	expectEvent(t, doorEvents, door.EventClosed)
+
+	// Once the duration has passed after the motion sensor is released, the door is no longer authorized.
+	motion.set(gpio.Low)
+	time.Sleep(300 * time.Millisecond)
+	sensor.set(gpio.High)
+	expectEvent(t, doorEvents, door.AlarmForcedOpen)
+}
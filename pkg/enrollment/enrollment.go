@@ -0,0 +1,311 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package enrollment provides an enrollment mode where the next unknown badge scanned at a reader is added to the
+// datastore.
+package enrollment
+
+import (
+	"sync"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/feedback"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBadgeType is the type given to enrolled badges when one is not provided.
+	DefaultBadgeType = "card"
+
+	// DefaultTimeout is how long enrollment waits for a badge when a timeout is not provided.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultMaxTimeout is the longest enrollment can wait for a badge when a maximum is not configured.
+	DefaultMaxTimeout = 5 * time.Minute
+)
+
+var (
+	enrollmentCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "open_keyless_enrollment_attempts_total",
+			Help: "The total count of badges captured in enrollment mode by result.",
+		},
+		[]string{"reader", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(enrollmentCounter)
+}
+
+// Request describes a single enrollment.
+type Request struct {
+	// BadgeType is the type given to the enrolled badge. E.x. card, sticker, keychain.
+	BadgeType string
+
+	// Enabled determines if the enrolled badge is created enabled.
+	Enabled bool
+
+	// Reader restricts enrollment to badges scanned at the named reader. An empty reader enrolls from any reader.
+	Reader string
+
+	// Timeout is how long to wait for a badge before enrollment ends on its own.
+	Timeout time.Duration
+}
+
+// Status describes the current state of enrollment.
+type Status struct {
+	// Active is true while enrollment is waiting for a badge.
+	Active bool `json:"active"`
+
+	// BadgeType is the type the enrolled badge will be given.
+	BadgeType string `json:"type,omitempty"`
+
+	// Enabled determines if the enrolled badge will be created enabled.
+	Enabled bool `json:"enabled"`
+
+	// Reader is the reader enrollment is restricted to.
+	Reader string `json:"reader,omitempty"`
+
+	// ExpiresAt is when enrollment ends if a badge is not scanned.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// LastEnrolledID is the id of the most recently enrolled badge.
+	LastEnrolledID string `json:"lastEnrolledId,omitempty"`
+
+	// LastEnrolledAt is when the most recently enrolled badge was enrolled.
+	LastEnrolledAt *time.Time `json:"lastEnrolledAt,omitempty"`
+}
+
+// Config is a configuration struct for a Manager.
+type Config struct {
+	// MaxTimeout is the longest enrollment can wait for a badge. Longer timeouts are capped.
+	MaxTimeout time.Duration
+}
+
+// Manager controls enrollment mode. It is safe to use from multiple goroutines.
+type Manager struct {
+	datastore  datastore.Datastore
+	feedback   feedback.Feedback
+	maxTimeout time.Duration
+
+	mu         sync.Mutex
+	active     bool
+	request    Request
+	expiresAt  time.Time
+	timer      *time.Timer
+	generation int
+	lastID     string
+	lastAt     time.Time
+}
+
+// NewManager provides an initialized Manager that creates enrolled badges in the provided datastore and signals
+// progress with the provided feedback.
+func NewManager(ds datastore.Datastore, fb feedback.Feedback, cfg Config) *Manager {
+	maxTimeout := cfg.MaxTimeout
+	if maxTimeout <= 0 {
+		maxTimeout = DefaultMaxTimeout
+	}
+
+	return &Manager{
+		datastore:  ds,
+		feedback:   fb,
+		maxTimeout: maxTimeout,
+	}
+}
+
+// Start starts waiting for the next unknown badge. If enrollment is already active, it is restarted with the new
+// request.
+func (m *Manager) Start(request Request) Status {
+	if request.BadgeType == "" {
+		request.BadgeType = DefaultBadgeType
+	}
+
+	if request.Timeout <= 0 {
+		request.Timeout = DefaultTimeout
+	}
+
+	if request.Timeout > m.maxTimeout {
+		request.Timeout = m.maxTimeout
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stop()
+	m.active = true
+	m.request = request
+	m.expiresAt = time.Now().Add(request.Timeout)
+	m.generation++
+
+	generation := m.generation
+	m.timer = time.AfterFunc(request.Timeout, func() {
+		m.expire(generation)
+	})
+
+	log.WithFields(log.Fields{
+		"reader":  request.Reader,
+		"type":    request.BadgeType,
+		"enabled": request.Enabled,
+		"timeout": request.Timeout.String(),
+	}).Info("enrollment started")
+	m.feedback.Signal(request.Reader, feedback.SignalEnrollmentStarted)
+
+	return m.status()
+}
+
+// Cancel ends enrollment without enrolling a badge.
+func (m *Manager) Cancel() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active {
+		m.stop()
+		log.WithFields(log.Fields{
+			"reader": m.request.Reader,
+		}).Info("enrollment cancelled")
+		m.feedback.Signal(m.request.Reader, feedback.SignalEnrollmentEnded)
+	}
+
+	return m.status()
+}
+
+// Status returns the current state of enrollment.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.status()
+}
+
+// Active returns true while enrollment is waiting for a badge.
+func (m *Manager) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.active
+}
+
+// Capture enrolls the badge with the provided id if enrollment is active for the reader and the badge does not exist
+// yet. It returns true if the scan was consumed by enrollment, in which case it should not be treated as an access
+// request. Scans of badges that already exist are not consumed and enrollment keeps waiting.
+func (m *Manager) Capture(reader string, id string) (bool, error) {
+	// The datastore is not called with the lock held so that a slow datastore does not block the other readers or the
+	// enrollment API.
+	m.mu.Lock()
+	active := m.active
+	request := m.request
+	generation := m.generation
+	m.mu.Unlock()
+
+	if !active || (request.Reader != "" && request.Reader != reader) {
+		return false, nil
+	}
+
+	_, err := m.datastore.GetBadge(id)
+	if err == nil {
+		return false, nil
+	}
+	if err.Error() != datastore.ErrBadgeDoesNotExist {
+		return false, err
+	}
+
+	err = m.datastore.CreateBadge(id, request.BadgeType, request.Enabled)
+	if err != nil {
+		enrollmentCounter.WithLabelValues(reader, "error").Inc()
+		m.feedback.Signal(reader, feedback.SignalEnrollmentFailed)
+		return true, err
+	}
+
+	m.mu.Lock()
+	// Enrollment may have been cancelled, timed out or restarted while the badge was created. Only the session that
+	// enrolled the badge is ended.
+	if m.active && m.generation == generation {
+		m.stop()
+	}
+	m.lastID = id
+	m.lastAt = time.Now()
+	m.mu.Unlock()
+
+	enrollmentCounter.WithLabelValues(reader, "enrolled").Inc()
+	log.WithFields(log.Fields{
+		"reader":  reader,
+		"id":      id,
+		"type":    request.BadgeType,
+		"enabled": request.Enabled,
+	}).Info("enrolled badge")
+	m.feedback.Signal(reader, feedback.SignalEnrolled)
+
+	return true, nil
+}
+
+// Done stops waiting for a badge without signalling the readers.
+func (m *Manager) Done() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stop()
+}
+
+func (m *Manager) expire(generation int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A newer enrollment was started or this one already ended, so this timer is stale.
+	if !m.active || generation != m.generation {
+		return
+	}
+
+	m.stop()
+	log.WithFields(log.Fields{
+		"reader": m.request.Reader,
+	}).Info("enrollment timed out")
+	m.feedback.Signal(m.request.Reader, feedback.SignalEnrollmentEnded)
+}
+
+// stop ends enrollment. The caller must hold the lock.
+func (m *Manager) stop() {
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+
+	m.active = false
+}
+
+// status returns the current state of enrollment. The caller must hold the lock.
+func (m *Manager) status() Status {
+	status := Status{
+		Active:         m.active,
+		LastEnrolledID: m.lastID,
+	}
+
+	if m.active {
+		expiresAt := m.expiresAt
+		status.BadgeType = m.request.BadgeType
+		status.Enabled = m.request.Enabled
+		status.Reader = m.request.Reader
+		status.ExpiresAt = &expiresAt
+	}
+
+	if !m.lastAt.IsZero() {
+		lastAt := m.lastAt
+		status.LastEnrolledAt = &lastAt
+	}
+
+	return status
+}
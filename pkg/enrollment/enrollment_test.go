@@ -0,0 +1,184 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package enrollment_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/enrollment"
+	"github.com/betterengineering/open-keyless/pkg/feedback"
+	"github.com/golang/mock/gomock"
+)
+
+type recordedFeedback struct {
+	mu      sync.Mutex
+	signals []feedback.Signal
+}
+
+func (f *recordedFeedback) Signal(reader string, signal feedback.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.signals = append(f.signals, signal)
+}
+
+func (f *recordedFeedback) last() feedback.Signal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.signals) == 0 {
+		return ""
+	}
+
+	return f.signals[len(f.signals)-1]
+}
+
+func TestManagerCapture(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge("8604de7d").Return(&datastore.Badge{ID: "8604de7d", Enabled: true}, nil)
+	ds.EXPECT().GetBadge("04a1b2c3").Return(nil, errors.New(datastore.ErrBadgeDoesNotExist))
+	ds.EXPECT().CreateBadge("04a1b2c3", "sticker", true).Return(nil)
+
+	fb := &recordedFeedback{}
+	manager := enrollment.NewManager(ds, fb, enrollment.Config{})
+	defer manager.Done()
+
+	manager.Start(enrollment.Request{BadgeType: "sticker", Enabled: true, Reader: "entry"})
+	if fb.last() != feedback.SignalEnrollmentStarted {
+		t.Errorf("expected '%s' to be signalled but got '%s'", feedback.SignalEnrollmentStarted, fb.last())
+	}
+
+	cases := []struct {
+		reader   string
+		id       string
+		consumed bool
+	}{
+		{"exit", "04a1b2c3", false},
+		{"entry", "8604de7d", false},
+		{"entry", "04a1b2c3", true},
+	}
+
+	for _, c := range cases {
+		consumed, err := manager.Capture(c.reader, c.id)
+		if err != nil {
+			t.Fatalf("error capturing badge - %s", err)
+		}
+
+		if consumed != c.consumed {
+			t.Errorf("expected the scan of '%s' at '%s' to be consumed '%t' but got '%t'", c.id, c.reader, c.consumed,
+				consumed)
+		}
+	}
+
+	status := manager.Status()
+	if status.Active || status.LastEnrolledID != "04a1b2c3" {
+		t.Errorf("expected enrollment to end after enrolling a badge but got '%+v'", status)
+	}
+
+	if fb.last() != feedback.SignalEnrolled {
+		t.Errorf("expected '%s' to be signalled but got '%s'", feedback.SignalEnrolled, fb.last())
+	}
+
+	consumed, err := manager.Capture("entry", "04d5e6f7")
+	if err != nil || consumed {
+		t.Errorf("expected scans after enrollment ended to not be consumed")
+	}
+}
+
+func TestManagerCaptureDoesNotHoldLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	creating := make(chan struct{})
+	release := make(chan struct{})
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge("04a1b2c3").Return(nil, errors.New(datastore.ErrBadgeDoesNotExist))
+	ds.EXPECT().CreateBadge("04a1b2c3", "sticker", true).DoAndReturn(func(string, string, bool) error {
+		close(creating)
+		<-release
+		return nil
+	})
+
+	fb := &recordedFeedback{}
+	manager := enrollment.NewManager(ds, fb, enrollment.Config{})
+	defer manager.Done()
+
+	manager.Start(enrollment.Request{BadgeType: "sticker", Enabled: true})
+
+	captured := make(chan bool)
+	go func() {
+		consumed, _ := manager.Capture("entry", "04a1b2c3")
+		captured <- consumed
+	}()
+
+	<-creating
+	status := make(chan enrollment.Status)
+	go func() {
+		status <- manager.Start(enrollment.Request{BadgeType: "tag"})
+	}()
+
+	select {
+	case s := <-status:
+		if !s.Active || s.BadgeType != "tag" {
+			t.Errorf("expected enrollment to restart while a badge is created but got '%+v'", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected enrollment to be usable while a badge is created")
+	}
+
+	close(release)
+	if !<-captured {
+		t.Errorf("expected the scan to be consumed")
+	}
+
+	s := manager.Status()
+	if !s.Active || s.LastEnrolledID != "04a1b2c3" {
+		t.Errorf("expected the restarted enrollment to keep waiting but got '%+v'", s)
+	}
+}
+
+func TestManagerTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fb := &recordedFeedback{}
+	cfg := enrollment.Config{MaxTimeout: 50 * time.Millisecond}
+	manager := enrollment.NewManager(mocks.NewMockDatastore(ctrl), fb, cfg)
+	defer manager.Done()
+
+	status := manager.Start(enrollment.Request{Timeout: time.Hour})
+	if status.ExpiresAt.After(time.Now().Add(time.Second)) {
+		t.Errorf("expected the timeout to be capped at the max timeout but it expires at '%s'", status.ExpiresAt)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if manager.Active() {
+		t.Errorf("expected enrollment to time out")
+	}
+
+	if fb.last() != feedback.SignalEnrollmentEnded {
+		t.Errorf("expected '%s' to be signalled but got '%s'", feedback.SignalEnrollmentEnded, fb.last())
+	}
+}
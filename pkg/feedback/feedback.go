@@ -0,0 +1,156 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package feedback provides a way to signal events back to the person standing at a reader.
+package feedback
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SignalGranted is signalled when a badge was granted access.
+	SignalGranted Signal = "granted"
+
+	// SignalDenied is signalled when a badge was denied access.
+	SignalDenied Signal = "denied"
+
+	// SignalError is signalled when access could not be decided or the strike could not be unlocked, such as when the
+	// datastore can not be reached.
+	SignalError Signal = "error"
+
+	// SignalAlarm is signalled when the door raised an alarm, such as being forced open.
+	SignalAlarm Signal = "alarm"
+
+	// SignalEnrollmentStarted is signalled when a reader starts waiting for a new badge to enroll.
+	SignalEnrollmentStarted Signal = "enrollment started"
+
+	// SignalEnrolled is signalled when a new badge was enrolled.
+	SignalEnrolled Signal = "enrolled"
+
+	// SignalEnrollmentFailed is signalled when a badge could not be enrolled.
+	SignalEnrollmentFailed Signal = "enrollment failed"
+
+	// SignalEnrollmentEnded is signalled when enrollment was cancelled or timed out without enrolling a badge.
+	SignalEnrollmentEnded Signal = "enrollment ended"
+)
+
+// Signal is an event that can be signalled at a reader.
+type Signal string
+
+// Signals are every signal that can be signalled at a reader.
+var Signals = []Signal{
+	SignalGranted,
+	SignalDenied,
+	SignalError,
+	SignalAlarm,
+	SignalEnrollmentStarted,
+	SignalEnrolled,
+	SignalEnrollmentFailed,
+	SignalEnrollmentEnded,
+}
+
+// Step is a single step of a feedback pattern.
+type Step struct {
+	// Tone is the frequency of the tone played on the buzzer in hertz. Zero is silent.
+	Tone int
+
+	// LED determines if the LED is lit.
+	LED bool
+
+	// Duration is how long the step lasts.
+	Duration time.Duration
+}
+
+// Pattern is a sequence of steps played for a signal. The buzzer is silenced and the LED is turned off once the
+// pattern ends.
+type Pattern []Step
+
+// DefaultPatterns provides the patterns played for each signal when a pattern is not configured.
+func DefaultPatterns() map[Signal]Pattern {
+	return map[Signal]Pattern{
+		SignalGranted: {
+			{Tone: 2700, LED: true, Duration: 100 * time.Millisecond},
+			{Tone: 0, LED: true, Duration: 50 * time.Millisecond},
+			{Tone: 2700, LED: true, Duration: 100 * time.Millisecond},
+			{Tone: 0, LED: true, Duration: 750 * time.Millisecond},
+		},
+		SignalDenied: {
+			{Tone: 400, LED: true, Duration: 200 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 100 * time.Millisecond},
+			{Tone: 400, LED: true, Duration: 200 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 100 * time.Millisecond},
+			{Tone: 400, LED: true, Duration: 200 * time.Millisecond},
+		},
+		SignalError: {
+			{Tone: 1000, LED: true, Duration: 50 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 50 * time.Millisecond},
+			{Tone: 1000, LED: true, Duration: 50 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 50 * time.Millisecond},
+			{Tone: 1000, LED: true, Duration: 50 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 50 * time.Millisecond},
+			{Tone: 1000, LED: true, Duration: 50 * time.Millisecond},
+		},
+		SignalAlarm: {
+			{Tone: 3000, LED: true, Duration: 250 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 250 * time.Millisecond},
+			{Tone: 3000, LED: true, Duration: 250 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 250 * time.Millisecond},
+			{Tone: 3000, LED: true, Duration: 250 * time.Millisecond},
+			{Tone: 0, LED: false, Duration: 250 * time.Millisecond},
+			{Tone: 3000, LED: true, Duration: 250 * time.Millisecond},
+		},
+		SignalEnrollmentStarted: {
+			{Tone: 1500, LED: true, Duration: 100 * time.Millisecond},
+			{Tone: 2000, LED: true, Duration: 100 * time.Millisecond},
+			{Tone: 2500, LED: true, Duration: 100 * time.Millisecond},
+		},
+		SignalEnrolled: {
+			{Tone: 2500, LED: true, Duration: 300 * time.Millisecond},
+		},
+		SignalEnrollmentFailed: {
+			{Tone: 800, LED: true, Duration: 500 * time.Millisecond},
+		},
+		SignalEnrollmentEnded: {
+			{Tone: 2500, LED: true, Duration: 100 * time.Millisecond},
+			{Tone: 2000, LED: true, Duration: 100 * time.Millisecond},
+			{Tone: 1500, LED: true, Duration: 100 * time.Millisecond},
+		},
+	}
+}
+
+// Feedback is an interface for signalling events at a reader. An empty reader signals every reader.
+type Feedback interface {
+	Signal(reader string, signal Signal)
+}
+
+// LogFeedback implements the Feedback interface by logging each signal. It is used when a reader has no way of
+// signalling the person standing at it.
+type LogFeedback struct{}
+
+// NewLogFeedback provides an initialized LogFeedback.
+func NewLogFeedback() *LogFeedback {
+	return &LogFeedback{}
+}
+
+// Signal logs the signal for the reader.
+func (f *LogFeedback) Signal(reader string, signal Signal) {
+	log.WithFields(log.Fields{
+		"reader": reader,
+		"signal": signal,
+	}).Debug("reader feedback")
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package feedback_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/feedback"
+	"github.com/golang/mock/gomock"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+)
+
+type recordingFeedback struct {
+	mu      sync.Mutex
+	signals []string
+}
+
+func (r *recordingFeedback) Signal(reader string, signal feedback.Signal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.signals = append(r.signals, reader+":"+string(signal))
+}
+
+func TestRouter(t *testing.T) {
+	entry := &recordingFeedback{}
+	exit := &recordingFeedback{}
+
+	router := feedback.NewRouter()
+	router.Add("entry", entry)
+	router.Add("exit", exit)
+
+	router.Signal("entry", feedback.SignalGranted)
+	router.Signal("", feedback.SignalAlarm)
+	router.Signal("unknown", feedback.SignalDenied)
+
+	if len(entry.signals) != 2 || entry.signals[0] != "entry:granted" || entry.signals[1] != "entry:alarm" {
+		t.Errorf("unexpected signals for the entry reader '%v'", entry.signals)
+	}
+
+	if len(exit.signals) != 1 || exit.signals[0] != "exit:alarm" {
+		t.Errorf("unexpected signals for the exit reader '%v'", exit.signals)
+	}
+}
+
+func TestGPIOFeedback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	buzzer := mocks.NewMockPinIO(ctrl)
+	led := mocks.NewMockPinIO(ctrl)
+
+	played := make(chan bool, 1)
+	tone := buzzer.EXPECT().PWM(gpio.DutyHalf, 2000*physic.Hertz).Return(nil).Times(1)
+	led.EXPECT().Out(gpio.High).Return(nil).Times(1)
+	buzzer.EXPECT().Out(gpio.Low).Return(nil).MinTimes(1).After(tone)
+	led.EXPECT().Out(gpio.Low).Return(nil).MinTimes(1).Do(func(gpio.Level) {
+		select {
+		case played <- true:
+		default:
+		}
+	})
+
+	f := feedback.NewGPIOFeedback(buzzer, led, map[feedback.Signal]feedback.Pattern{
+		feedback.SignalGranted: {{Tone: 2000, LED: true, Duration: time.Millisecond}},
+	})
+	defer f.Done()
+
+	// Signals without a pattern are ignored.
+	f.Signal("entry", feedback.SignalDenied)
+	f.Signal("entry", feedback.SignalGranted)
+
+	select {
+	case <-played:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the pattern to be played")
+	}
+}
+
+func TestDefaultPatterns(t *testing.T) {
+	patterns := feedback.DefaultPatterns()
+	for _, signal := range feedback.Signals {
+		if len(patterns[signal]) == 0 {
+			t.Errorf("expected a default pattern for '%s'", signal)
+		}
+	}
+}
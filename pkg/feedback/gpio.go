@@ -0,0 +1,206 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package feedback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/host"
+)
+
+const (
+	// ErrCouldNotInitializeGPIOPin is returned when a GPIO pin for reader feedback can not be initialized.
+	ErrCouldNotInitializeGPIOPin = "could not initialize the GPIO pin for reader feedback"
+)
+
+// GPIOConfig is a configuration struct for a GPIOFeedback.
+type GPIOConfig struct {
+	// BuzzerPin is the name of the PWM capable GPIO pin driving the piezo buzzer. Ex "12". An empty pin disables the
+	// buzzer.
+	BuzzerPin string
+
+	// LEDPin is the name of the GPIO pin driving the LED. Ex "25". An empty pin disables the LED.
+	LEDPin string
+}
+
+// Enabled returns true if the configuration has a buzzer or an LED.
+func (c GPIOConfig) Enabled() bool {
+	return c.BuzzerPin != "" || c.LEDPin != ""
+}
+
+// GPIOFeedback implements the Feedback interface by playing a pattern of tones on a piezo buzzer driven with PWM and
+// lighting an LED. A new signal interrupts the pattern that is playing.
+type GPIOFeedback struct {
+	buzzer   gpio.PinOut
+	led      gpio.PinOut
+	patterns map[Signal]Pattern
+	play     chan Pattern
+	cancel   context.CancelFunc
+	wg       *sync.WaitGroup
+	once     sync.Once
+}
+
+// OpenGPIOFeedback opens the GPIO pins described by the provided configuration and provides an initialized
+// GPIOFeedback for them.
+func OpenGPIOFeedback(cfg GPIOConfig, patterns map[Signal]Pattern) (*GPIOFeedback, error) {
+	_, err := host.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	var buzzer, led gpio.PinOut
+	if cfg.BuzzerPin != "" {
+		p := gpioreg.ByName(cfg.BuzzerPin)
+		if p == nil {
+			return nil, errors.New(ErrCouldNotInitializeGPIOPin)
+		}
+		buzzer = p
+	}
+
+	if cfg.LEDPin != "" {
+		p := gpioreg.ByName(cfg.LEDPin)
+		if p == nil {
+			return nil, errors.New(ErrCouldNotInitializeGPIOPin)
+		}
+		led = p
+	}
+
+	return NewGPIOFeedback(buzzer, led, patterns), nil
+}
+
+// NewGPIOFeedback provides an initialized GPIOFeedback that plays the provided patterns on the buzzer and LED. Either
+// pin may be nil. Signals without a pattern are ignored. Be sure to call Done when you are done with the feedback to
+// silence the buzzer and turn off the LED.
+func NewGPIOFeedback(buzzer gpio.PinOut, led gpio.PinOut, patterns map[Signal]Pattern) *GPIOFeedback {
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f := &GPIOFeedback{
+		buzzer:   buzzer,
+		led:      led,
+		patterns: patterns,
+		play:     make(chan Pattern, 1),
+		cancel:   cancel,
+		wg:       &wg,
+	}
+
+	f.wg.Add(1)
+	go f.run(ctx)
+
+	return f
+}
+
+// Signal plays the pattern for the signal, interrupting the pattern that is playing. Signal does not block.
+func (f *GPIOFeedback) Signal(reader string, signal Signal) {
+	pattern, ok := f.patterns[signal]
+	if !ok {
+		return
+	}
+
+	// Replace a pattern that has not started playing yet so that only the latest signal is played.
+	select {
+	case <-f.play:
+	default:
+	}
+
+	select {
+	case f.play <- pattern:
+	default:
+	}
+}
+
+// Done stops playing, silences the buzzer and turns off the LED.
+func (f *GPIOFeedback) Done() {
+	f.once.Do(func() {
+		f.cancel()
+		f.wg.Wait()
+	})
+}
+
+func (f *GPIOFeedback) run(ctx context.Context) {
+	defer f.wg.Done()
+	defer f.off()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case pattern := <-f.play:
+			for pattern != nil {
+				pattern = f.playPattern(ctx, pattern)
+			}
+		}
+	}
+}
+
+// playPattern plays the pattern and returns the pattern that interrupted it, if any.
+func (f *GPIOFeedback) playPattern(ctx context.Context, pattern Pattern) Pattern {
+	defer f.off()
+
+	for _, step := range pattern {
+		f.apply(step)
+
+		timer := time.NewTimer(step.Duration)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case next := <-f.play:
+			timer.Stop()
+			return next
+		case <-timer.C:
+		}
+	}
+
+	return nil
+}
+
+func (f *GPIOFeedback) apply(step Step) {
+	if f.buzzer != nil {
+		var err error
+		if step.Tone > 0 {
+			err = f.buzzer.PWM(gpio.DutyHalf, physic.Frequency(step.Tone)*physic.Hertz)
+		} else {
+			err = f.buzzer.Out(gpio.Low)
+		}
+		f.logError(err, "could not drive the reader buzzer")
+	}
+
+	if f.led != nil {
+		f.logError(f.led.Out(gpio.Level(step.LED)), "could not drive the reader LED")
+	}
+}
+
+func (f *GPIOFeedback) off() {
+	f.apply(Step{})
+}
+
+func (f *GPIOFeedback) logError(err error, message string) {
+	if err == nil {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"error": err,
+	}).Warn(message)
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package feedback
+
+import (
+	"sort"
+)
+
+// Router implements the Feedback interface by logging each signal and sending it on to the feedback of the reader it
+// is for. A signal for an empty reader is sent to every reader.
+type Router struct {
+	log     Feedback
+	readers map[string]Feedback
+}
+
+// NewRouter provides an initialized Router without any readers.
+func NewRouter() *Router {
+	return &Router{
+		log:     NewLogFeedback(),
+		readers: map[string]Feedback{},
+	}
+}
+
+// Add sends signals for the reader to the provided feedback. Readers must be added before signals are sent.
+func (r *Router) Add(reader string, fb Feedback) {
+	r.readers[reader] = fb
+}
+
+// Signal logs the signal and sends it to the feedback of the reader.
+func (r *Router) Signal(reader string, signal Signal) {
+	r.log.Signal(reader, signal)
+
+	if reader != "" {
+		if fb, ok := r.readers[reader]; ok {
+			fb.Signal(reader, signal)
+		}
+		return
+	}
+
+	names := []string{}
+	for name := range r.readers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.readers[name].Signal(name, signal)
+	}
+}
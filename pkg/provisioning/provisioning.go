@@ -0,0 +1,266 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package provisioning writes secure credentials to blank MIFARE DESFire cards and records them in the datastore.
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+	"github.com/betterengineering/open-keyless/pkg/scanner"
+	"github.com/fuzxxl/nfc/2.0/nfc"
+)
+
+const (
+	// ErrNoCard is returned when there is no card on the reader.
+	ErrNoCard = "no card was found on the reader"
+
+	// ErrMultipleCards is returned when there is more than one card on the reader.
+	ErrMultipleCards = "more than one card was found on the reader"
+
+	// ErrReadKeyIsMasterKey is returned when the credential is configured to be read with the application master key.
+	ErrReadKeyIsMasterKey = "the credential key number must not be 0, which is the application master key"
+
+	// ErrVerificationFailed is returned when the credential read back from the card does not match the one written.
+	ErrVerificationFailed = "the credential read back from the card does not match"
+
+	// ErrBadgeExists is returned when a badge for the credential number is already in the datastore. The card is left
+	// untouched.
+	ErrBadgeExists = "a badge for the credential number already exists in the datastore"
+
+	// ErrCreateBadge is returned when the card was provisioned but the badge could not be added to the datastore.
+	ErrCreateBadge = "the card was provisioned but the badge could not be added to the datastore"
+)
+
+const (
+	// ApplicationKeySettings allows the application master key to be changed and the configuration to be changed,
+	// and requires the application master key to list, create or delete files.
+	ApplicationKeySettings byte = 0x09
+
+	// keyVersion is the version given to every key written to a card.
+	keyVersion byte = 0x01
+)
+
+// Config describes the application, keys and credential file written to every card.
+type Config struct {
+	// Credential describes the application, read key, file and signing key of the credential. It must match the
+	// secure credential settings of the readers. When Credential.Diversify is set, Credential.Key is the master key
+	// that the read key of each card is derived from.
+	Credential desfire.CredentialConfig
+
+	// ApplicationKey is the AES key that becomes the application master key. It is diversified for each card in the
+	// same way as the read key.
+	ApplicationKey []byte
+
+	// PICCKey is the 2K3DES PICC master key used to format the card. A nil key uses the all zero key of blank cards.
+	PICCKey []byte
+
+	// Format deletes every application on the card before the site application is created.
+	Format bool
+}
+
+// Result describes a provisioned card.
+type Result struct {
+	// ID is the badge id added to the datastore.
+	ID string
+
+	// UID is the UID of the card.
+	UID []byte
+
+	// Number is the credential number written to the card.
+	Number uint64
+}
+
+// Provisioner writes secure credentials to the cards placed on a libnfc reader.
+type Provisioner struct {
+	device    scanner.LibNFCDevice
+	datastore datastore.Datastore
+	config    Config
+	mod       nfc.Modulation
+}
+
+// NewProvisioner provides a Provisioner that writes to cards through the provided device and records each card in the
+// provided datastore. The device must already be initialized as an initiator.
+func NewProvisioner(device scanner.LibNFCDevice, ds datastore.Datastore, cfg Config) (*Provisioner, error) {
+	err := cfg.Credential.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Credential.KeyNumber == 0 {
+		return nil, errors.New(ErrReadKeyIsMasterKey)
+	}
+
+	if len(cfg.ApplicationKey) != desfire.KeyLength {
+		return nil, errors.New(desfire.ErrInvalidKeyLength)
+	}
+
+	if cfg.PICCKey == nil {
+		cfg.PICCKey = make([]byte, desfire.KeyLength)
+	}
+
+	return &Provisioner{
+		device:    device,
+		datastore: ds,
+		config:    cfg,
+		mod: nfc.Modulation{
+			Type:     nfc.ISO14443a,
+			BaudRate: 1,
+		},
+	}, nil
+}
+
+// Provision writes the credential number to the single card on the reader, reads it back to verify it, and adds the
+// badge to the datastore with the provided type and enabled flag. Nothing is written to the card if a badge for the
+// credential number already exists.
+func (p *Provisioner) Provision(number uint64, badgeType string, enabled bool) (Result, error) {
+	uid, err := p.findCard()
+	if err != nil {
+		return Result{}, err
+	}
+
+	id := desfire.CredentialID(number)
+	_, err = p.datastore.GetBadge(id)
+	if err == nil {
+		return Result{}, errors.New(ErrBadgeExists)
+	}
+	if err.Error() != datastore.ErrBadgeDoesNotExist {
+		return Result{}, err
+	}
+
+	_, err = p.device.InitiatorSelectPassiveTarget(p.mod, uid)
+	if err != nil {
+		return Result{}, err
+	}
+	defer p.device.InitiatorDeselectTarget()
+
+	err = p.write(uid, number)
+	if err != nil {
+		return Result{}, err
+	}
+
+	read, err := desfire.ReadCredential(p.device, p.config.Credential, uid)
+	if err != nil {
+		return Result{}, fmt.Errorf("%s - %s", ErrVerificationFailed, err)
+	}
+	if read != number {
+		return Result{}, errors.New(ErrVerificationFailed)
+	}
+
+	result := Result{
+		ID:     id,
+		UID:    uid,
+		Number: number,
+	}
+
+	err = p.datastore.CreateBadge(result.ID, badgeType, enabled)
+	if err != nil {
+		return result, fmt.Errorf("%s - %s", ErrCreateBadge, err)
+	}
+
+	return result, nil
+}
+
+// findCard returns the UID of the only ISO14443A card on the reader.
+func (p *Provisioner) findCard() ([]byte, error) {
+	targets, err := p.device.InitiatorListPassiveTargets(p.mod)
+	if err != nil {
+		return nil, err
+	}
+
+	uids := [][]byte{}
+	for _, target := range targets {
+		t, ok := target.(*nfc.ISO14443aTarget)
+		if ok {
+			uids = append(uids, append([]byte{}, t.UID[:t.UIDLen]...))
+		}
+	}
+
+	switch len(uids) {
+	case 0:
+		return nil, errors.New(ErrNoCard)
+	case 1:
+		return uids[0], nil
+	default:
+		return nil, errors.New(ErrMultipleCards)
+	}
+}
+
+// write creates the site application and credential file on the selected card, writes the signed credential, and
+// replaces the default application keys with the keys for the card.
+func (p *Provisioner) write(uid []byte, number uint64) error {
+	cred := p.config.Credential
+	defaultKey := make([]byte, desfire.KeyLength)
+	card := desfire.NewCard(p.device)
+
+	readKey, err := cred.CardKey(uid)
+	if err != nil {
+		return err
+	}
+
+	applicationKey := p.config.ApplicationKey
+	if cred.Diversify {
+		input := desfire.DiversificationInput(uid, cred.AID, cred.SystemID)
+		applicationKey, err = desfire.DiversifyKey(applicationKey, input)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := desfire.SignCredential(cred.SigningKey, cred.AID, uid, number)
+	if err != nil {
+		return err
+	}
+
+	steps := []func() error{
+		func() error { return card.SelectApplication(0) },
+		func() error {
+			if !p.config.Format {
+				return nil
+			}
+
+			err := card.AuthenticateISO(0, p.config.PICCKey)
+			if err != nil {
+				return err
+			}
+
+			return card.FormatPICC()
+		},
+		func() error { return card.CreateApplication(cred.AID, ApplicationKeySettings, cred.KeyNumber+1) },
+		func() error { return card.SelectApplication(cred.AID) },
+		func() error { return card.AuthenticateAES(0, defaultKey) },
+		func() error {
+			access := desfire.AccessRights(cred.KeyNumber, 0, 0, 0)
+			return card.CreateStdDataFile(cred.FileNumber, desfire.CommunicationPlain, access, desfire.CredentialLength)
+		},
+		func() error { return card.WriteData(cred.FileNumber, 0, data) },
+		func() error { return card.AuthenticateAES(0, defaultKey) },
+		func() error { return card.ChangeKey(cred.KeyNumber, readKey, defaultKey, keyVersion) },
+		func() error { return card.AuthenticateAES(0, defaultKey) },
+		func() error { return card.ChangeKey(0, applicationKey, nil, keyVersion) },
+	}
+
+	for _, step := range steps {
+		err := step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
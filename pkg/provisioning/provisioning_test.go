@@ -0,0 +1,231 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package provisioning_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+	"github.com/betterengineering/open-keyless/pkg/desfire/desfiretest"
+	"github.com/betterengineering/open-keyless/pkg/provisioning"
+	"github.com/fuzxxl/nfc/2.0/nfc"
+	"github.com/golang/mock/gomock"
+)
+
+var testUID = []byte{0x04, 0x78, 0x2e, 0x21, 0x80, 0x1d, 0x80}
+
+const testID = "desfire:000000000000002a"
+
+func TestProvision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	card := desfiretest.NewCard()
+	card.AddApplication(0x123456, &desfiretest.Application{Keys: [][]byte{make([]byte, desfire.KeyLength)}})
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge(testID).Return(nil, errors.New(datastore.ErrBadgeDoesNotExist)).Times(1)
+	ds.EXPECT().CreateBadge(testID, "card", true).Return(nil).Times(1)
+
+	cfg := givenConfig()
+	p, err := provisioning.NewProvisioner(givenDevice(ctrl, card), ds, cfg)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	result, err := p.Provision(42, "card", true)
+	if err != nil {
+		t.Fatalf("error provisioning card - %s", err)
+	}
+
+	if result.ID != testID || !bytes.Equal(result.UID, testUID) {
+		t.Errorf("unexpected result '%+v'", result)
+	}
+
+	if card.Application(0x123456) != nil {
+		t.Errorf("expected the card to be formatted")
+	}
+
+	app := card.Application(cfg.Credential.AID)
+	readKey, err := cfg.Credential.CardKey(testUID)
+	if err != nil {
+		t.Fatalf("error diversifying key - %s", err)
+	}
+
+	if bytes.Equal(readKey, cfg.Credential.Key) || !bytes.Equal(app.Keys[cfg.Credential.KeyNumber], readKey) {
+		t.Errorf("expected the read key to be diversified for the card")
+	}
+
+	if bytes.Equal(app.Keys[0], make([]byte, desfire.KeyLength)) || bytes.Equal(app.Keys[0], cfg.ApplicationKey) {
+		t.Errorf("expected the application master key to be diversified for the card")
+	}
+
+	number, err := desfire.ReadCredential(card, cfg.Credential, testUID)
+	if err != nil || number != 42 {
+		t.Errorf("expected to read credential 42 from the card but got %d - %v", number, err)
+	}
+}
+
+func TestProvisionExistingApplication(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := givenConfig()
+	cfg.Format = false
+
+	card := desfiretest.NewCard()
+	card.AddApplication(cfg.Credential.AID, &desfiretest.Application{Keys: [][]byte{make([]byte, desfire.KeyLength)}})
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge(testID).Return(nil, errors.New(datastore.ErrBadgeDoesNotExist)).Times(1)
+	ds.EXPECT().CreateBadge(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	p, err := provisioning.NewProvisioner(givenDevice(ctrl, card), ds, cfg)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	_, err = p.Provision(42, "card", true)
+	statusErr, ok := err.(*desfire.StatusError)
+	if !ok || statusErr.Status != desfiretest.StatusDuplicateError {
+		t.Errorf("expected a duplicate application error but got '%v'", err)
+	}
+}
+
+func TestProvisionCreateBadgeError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge(testID).Return(nil, errors.New(datastore.ErrBadgeDoesNotExist)).Times(1)
+	ds.EXPECT().CreateBadge(testID, "card", true).Return(errors.New("the badge already exists")).Times(1)
+
+	p, err := provisioning.NewProvisioner(givenDevice(ctrl, desfiretest.NewCard()), ds, givenConfig())
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	result, err := p.Provision(42, "card", true)
+	if err == nil || !strings.HasPrefix(err.Error(), provisioning.ErrCreateBadge) {
+		t.Errorf("expected error '%s' but got '%v'", provisioning.ErrCreateBadge, err)
+	}
+
+	if result.ID != testID {
+		t.Errorf("expected the result of the provisioned card but got '%+v'", result)
+	}
+}
+
+func TestProvisionExistingBadge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ds := mocks.NewMockDatastore(ctrl)
+	ds.EXPECT().GetBadge(testID).Return(&datastore.Badge{ID: testID}, nil).Times(1)
+	ds.EXPECT().CreateBadge(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	// The card is never selected, so nothing is written to it.
+	device := mocks.NewMockLibNFCDevice(ctrl)
+	device.EXPECT().InitiatorListPassiveTargets(gomock.Any()).Return(givenTargets(), nil).Times(1)
+
+	p, err := provisioning.NewProvisioner(device, ds, givenConfig())
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	_, err = p.Provision(42, "card", true)
+	if err == nil || err.Error() != provisioning.ErrBadgeExists {
+		t.Errorf("expected error '%s' but got '%v'", provisioning.ErrBadgeExists, err)
+	}
+}
+
+func TestProvisionNoCard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	device := mocks.NewMockLibNFCDevice(ctrl)
+	device.EXPECT().InitiatorListPassiveTargets(gomock.Any()).Return(nil, nil).Times(1)
+
+	p, err := provisioning.NewProvisioner(device, mocks.NewMockDatastore(ctrl), givenConfig())
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	_, err = p.Provision(42, "card", true)
+	if err == nil || err.Error() != provisioning.ErrNoCard {
+		t.Errorf("expected error '%s' but got '%v'", provisioning.ErrNoCard, err)
+	}
+}
+
+func TestNewProvisionerWithMasterReadKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := givenConfig()
+	cfg.Credential.KeyNumber = 0
+
+	_, err := provisioning.NewProvisioner(mocks.NewMockLibNFCDevice(ctrl), mocks.NewMockDatastore(ctrl), cfg)
+	if err == nil || err.Error() != provisioning.ErrReadKeyIsMasterKey {
+		t.Errorf("expected error '%s' but got '%v'", provisioning.ErrReadKeyIsMasterKey, err)
+	}
+}
+
+func givenConfig() provisioning.Config {
+	return provisioning.Config{
+		Credential: desfire.CredentialConfig{
+			AID:        0xF51CD0,
+			KeyNumber:  1,
+			Key:        bytes.Repeat([]byte{0x11}, desfire.KeyLength),
+			FileNumber: 1,
+			SigningKey: bytes.Repeat([]byte{0x22}, desfire.KeyLength),
+			Diversify:  true,
+			SystemID:   []byte("open-keyless"),
+		},
+		ApplicationKey: bytes.Repeat([]byte{0x33}, desfire.KeyLength),
+		Format:         true,
+	}
+}
+
+// givenDevice provides a device with the simulated card on it. Every transceive is handled by the card.
+func givenDevice(ctrl *gomock.Controller, card *desfiretest.Card) *mocks.MockLibNFCDevice {
+	targets := givenTargets()
+
+	device := mocks.NewMockLibNFCDevice(ctrl)
+	device.EXPECT().InitiatorListPassiveTargets(gomock.Any()).Return(targets, nil).Times(1)
+	device.EXPECT().InitiatorSelectPassiveTarget(gomock.Any(), testUID).Return(targets[0], nil).Times(1)
+	device.EXPECT().InitiatorTransceiveBytes(gomock.Any(), gomock.Any(), desfire.TransceiveTimeout).
+		DoAndReturn(card.InitiatorTransceiveBytes).AnyTimes()
+	device.EXPECT().InitiatorDeselectTarget().Return(nil).Times(1)
+
+	return device
+}
+
+// givenTargets provides the target found for a card with testUID.
+func givenTargets() []nfc.Target {
+	var uid [10]byte
+	copy(uid[:], testUID)
+
+	return []nfc.Target{&nfc.ISO14443aTarget{
+		Atqa:   [2]byte{0x03, 0x44},
+		Sak:    0x20,
+		UID:    uid,
+		UIDLen: len(testUID),
+	}}
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Debouncer sits between one or more scanners and their consumer to suppress repeated reads of a badge that is held
+// against a reader. The first read of a badge on a reader is forwarded and any further reads of the same UID on the
+// same reader are dropped until the badge has not been seen for the configured window. At that point a single event
+// with Removed set is forwarded to signal that the badge has left the reader's field.
+type Debouncer struct {
+	window  time.Duration
+	in      chan ScanEvent
+	out     chan ScanEvent
+	present map[debounceKey]*presence
+	cancel  context.CancelFunc
+	wg      *sync.WaitGroup
+	started bool
+}
+
+type debounceKey struct {
+	reader string
+	id     string
+}
+
+type presence struct {
+	event    ScanEvent
+	lastSeen time.Time
+}
+
+// NewDebouncer provides an initialized Debouncer that reads events from in and writes the debounced events to out. A
+// window of zero disables debouncing and forwards every event as is. Call Start to begin debouncing.
+func NewDebouncer(window time.Duration, in chan ScanEvent, out chan ScanEvent) *Debouncer {
+	var wg sync.WaitGroup
+
+	return &Debouncer{
+		window:  window,
+		in:      in,
+		out:     out,
+		present: map[debounceKey]*presence{},
+		wg:      &wg,
+	}
+}
+
+// Start starts the debouncer if it has not already been started. The debouncer stops once the provided context is
+// cancelled or Done is called.
+func (d *Debouncer) Start(ctx context.Context) {
+	if d.started {
+		return
+	}
+
+	ctx, d.cancel = context.WithCancel(ctx)
+	d.started = true
+	d.wg.Add(1)
+	go d.run(ctx)
+}
+
+// Done stops the debouncer.
+func (d *Debouncer) Done() {
+	if !d.started {
+		return
+	}
+
+	d.cancel()
+	d.wg.Wait()
+	d.started = false
+}
+
+func (d *Debouncer) run(ctx context.Context) {
+	defer d.wg.Done()
+
+	var expire <-chan time.Time
+	if d.window > 0 {
+		ticker := time.NewTicker(d.window / 4)
+		defer ticker.Stop()
+		expire = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-d.in:
+			d.receive(ctx, event, time.Now())
+		case now := <-expire:
+			d.expire(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Debouncer) receive(ctx context.Context, event ScanEvent, now time.Time) {
+	if d.window <= 0 {
+		d.send(ctx, event)
+		return
+	}
+
+	key := debounceKey{reader: event.Reader, id: event.ID}
+	if p, ok := d.present[key]; ok {
+		p.lastSeen = now
+		return
+	}
+
+	d.present[key] = &presence{
+		event:    event,
+		lastSeen: now,
+	}
+	d.send(ctx, event)
+}
+
+func (d *Debouncer) expire(ctx context.Context, now time.Time) {
+	for key, p := range d.present {
+		if now.Sub(p.lastSeen) < d.window {
+			continue
+		}
+
+		delete(d.present, key)
+
+		removed := p.event
+		removed.Timestamp = now
+		removed.Removed = true
+		d.send(ctx, removed)
+	}
+}
+
+// send forwards the event unless the debouncer is stopped first.
+func (d *Debouncer) send(ctx context.Context, event ScanEvent) {
+	select {
+	case d.out <- event:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package scanner_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/scanner"
+)
+
+func TestDebouncer(t *testing.T) {
+	in := make(chan scanner.ScanEvent, 100)
+	out := make(chan scanner.ScanEvent, 100)
+
+	d := scanner.NewDebouncer(20*time.Millisecond, in, out)
+	d.Start(context.Background())
+
+	for x := 0; x < 10; x++ {
+		in <- scanner.ScanEvent{ID: "8604de7d", Reader: "entry"}
+		in <- scanner.ScanEvent{ID: "8604de7d", Reader: "exit"}
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	d.Done()
+	close(out)
+
+	reads := map[string]int{}
+	removals := map[string]int{}
+	for event := range out {
+		if event.Removed {
+			removals[event.Reader]++
+			continue
+		}
+
+		reads[event.Reader]++
+	}
+
+	for _, reader := range []string{"entry", "exit"} {
+		if reads[reader] != 1 {
+			t.Errorf("expected 1 read on reader '%s' but got %d", reader, reads[reader])
+		}
+
+		if removals[reader] != 1 {
+			t.Errorf("expected 1 removal on reader '%s' but got %d", reader, removals[reader])
+		}
+	}
+}
+
+func TestDebouncerDisabled(t *testing.T) {
+	in := make(chan scanner.ScanEvent, 100)
+	out := make(chan scanner.ScanEvent, 100)
+
+	d := scanner.NewDebouncer(0, in, out)
+	d.Start(context.Background())
+
+	for x := 0; x < 10; x++ {
+		in <- scanner.ScanEvent{ID: "8604de7d", Reader: "entry"}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	d.Done()
+
+	if len(out) != 10 {
+		t.Errorf("expected every event to be forwarded but got %d", len(out))
+	}
+}
@@ -17,97 +17,163 @@ package scanner
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
-	"log"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/karalabe/hid"
 )
 
+const (
+	// ErrHidDeviceNotFound is returned when no HID device matches the provided configuration.
+	ErrHidDeviceNotFound = "no HID device found"
+
+	// ErrHidWrite is returned when a command could not be written to the HID device.
+	ErrHidWrite = "error writing to device"
+
+	// ErrHidRead is returned when a report could not be read from the HID device.
+	ErrHidRead = "error reading from device"
+)
+
 // HidScanner implements the scanner interface for HID based scanning devices.
 type HidScanner struct {
+	reader  string
 	device  *hid.Device
-	ids     chan string
+	events  chan ScanEvent
 	errors  chan error
-	quit    chan bool
+	cancel  context.CancelFunc
 	wg      *sync.WaitGroup
 	started bool
 }
 
+// HidScannerConfig is a configuration struct for a HidScanner.
+type HidScannerConfig struct {
+	// Reader is the name of the reader included in every ScanEvent. Ex "entry".
+	Reader string
+
+	// VendorID is the USB vendor ID of the device. Zero matches any vendor.
+	VendorID uint16
+
+	// ProductID is the USB product ID of the device. Zero matches any product.
+	ProductID uint16
+
+	// Path is the platform specific path of the device. This can be used to pick between several devices with the
+	// same vendor and product ID. An empty path matches the first device found.
+	Path string
+}
+
 // NewDefaultHidScanner provides an instantiated hid scanner device.
-func NewDefaultHidScanner(ids chan string, errs chan error) (*HidScanner, error) {
-	devices := hid.Enumerate(0, 0)
-	if len(devices) == 0 {
-		return nil, errors.New("no HID device found")
-	}
+func NewDefaultHidScanner(events chan ScanEvent, errs chan error) (*HidScanner, error) {
+	return NewHidScanner(HidScannerConfig{Reader: DefaultReaderName}, events, errs)
+}
 
-	device, err := devices[0].Open()
-	if err != nil {
-		return nil, err
+// NewHidScanner provides an instantiated hid scanner for the first device matching the provided configuration.
+func NewHidScanner(cfg HidScannerConfig, events chan ScanEvent, errs chan error) (*HidScanner, error) {
+	for _, info := range hid.Enumerate(cfg.VendorID, cfg.ProductID) {
+		if cfg.Path != "" && info.Path != cfg.Path {
+			continue
+		}
+
+		device, err := info.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		return newHidScanner(cfg.Reader, device, events, errs), nil
 	}
 
+	return nil, errors.New(ErrHidDeviceNotFound)
+}
+
+func newHidScanner(reader string, device *hid.Device, events chan ScanEvent, errs chan error) *HidScanner {
 	var wg sync.WaitGroup
 
 	return &HidScanner{
+		reader:  reader,
 		device:  device,
-		ids:     ids,
+		events:  events,
 		errors:  errs,
 		started: false,
-		quit:    make(chan bool),
 		wg:      &wg,
-	}, nil
+	}
 }
 
-// Scan starts the scanner
-func (hid *HidScanner) Scan() {
+// Scan starts the scanner. The scanner stops once the provided context is cancelled or Done is called.
+func (hid *HidScanner) Scan(ctx context.Context) {
 	if hid.started {
 		return
 	}
 
+	ctx, hid.cancel = context.WithCancel(ctx)
+	hid.started = true
 	hid.wg.Add(1)
-	go hid.run()
+	go hid.run(ctx)
 }
 
 // Done closes down the scanner.
 func (hid *HidScanner) Done() error {
-	hid.quit <- true
-	hid.wg.Wait()
-	hid.started = false
+	if hid.started {
+		hid.cancel()
+		hid.wg.Wait()
+		hid.started = false
+	}
+
 	return hid.device.Close()
 }
 
-func (hid *HidScanner) run() {
+func (hid *HidScanner) run(ctx context.Context) {
 	defer hid.wg.Done()
 
 	for {
 		select {
-		case <-hid.quit:
+		case <-ctx.Done():
 			return
 		default:
-			hid.scan()
+			hid.scan(ctx)
 		}
 	}
 }
 
-func (hid *HidScanner) scan() {
+func (hid *HidScanner) scan(ctx context.Context) {
 	err := hid.write(0x8f)
 	if err != nil {
-		log.Println("error writing to device")
+		hid.sendError(ctx, fmt.Errorf("%s - %s", ErrHidWrite, err))
 		return
 	}
 
 	cardData, err := hid.read()
 	if err != nil {
-		log.Println("error reading from device")
+		hid.sendError(ctx, fmt.Errorf("%s - %s", ErrHidRead, err))
 		return
 	}
 	if cardData == nil {
 		return
 	}
 
-	id := hex.EncodeToString(cardData)
-	hid.ids <- id
+	event := ScanEvent{
+		ID:         hex.EncodeToString(cardData),
+		Reader:     hid.reader,
+		Timestamp:  time.Now(),
+		Technology: TechnologyUnknown,
+		UID:        cardData,
+	}
+
+	select {
+	case hid.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// sendError sends the error wrapped in a ReaderError unless the scanner is stopped first, so that a full error channel
+// can not block shutdown.
+func (hid *HidScanner) sendError(ctx context.Context, err error) {
+	select {
+	case hid.errors <- &ReaderError{Reader: hid.reader, Err: err}:
+	case <-ctx.Done():
+	}
 }
 
 func (hid *HidScanner) write(cmd byte) error {
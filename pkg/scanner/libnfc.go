@@ -16,127 +16,270 @@
 package scanner
 
 import (
-	"encoding/hex"
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/betterengineering/open-keyless/pkg/desfire"
 	"github.com/fuzxxl/nfc/2.0/nfc"
 )
 
 const (
 	// ErrUnsupportedTagType is returned when a tag with an unsupported tag type was read from the scanner.
 	ErrUnsupportedTagType = "read a device, but could not cast it to the proper tag type"
+
+	// ErrSecureCredential is returned when a secure credential could not be read from a badge.
+	ErrSecureCredential = "could not read the secure credential from the badge"
+
+	// ErrSecureCredentialTechnology is returned when a badge that is not an ISO14443A badge is read by a scanner that
+	// requires secure credentials.
+	ErrSecureCredentialTechnology = "secure credentials can only be read from ISO14443A badges"
 )
 
-// LibNFCDevice is an interface used to generate a mock for nfc.Device.
+// LibNFCDevice is an interface used to generate a mock for nfc.Device. The select, transceive and deselect methods are
+// used to exchange commands with a badge when reading secure credentials.
 type LibNFCDevice interface {
 	InitiatorInit() error
 	Close() error
 	InitiatorListPassiveTargets(mod nfc.Modulation) ([]nfc.Target, error)
+	InitiatorSelectPassiveTarget(mod nfc.Modulation, initData []byte) (nfc.Target, error)
+	InitiatorTransceiveBytes(tx, rx []byte, timeout int) (int, error)
+	InitiatorDeselectTarget() error
+}
+
+// LibNFCScannerConfig is a configuration struct for a LibNFCScanner.
+type LibNFCScannerConfig struct {
+	// Reader is the name of the reader included in every ScanEvent. Ex "entry".
+	Reader string
+
+	// Connection is the libnfc connection string for the device. Ex "pn532_uart:/dev/ttyS0". An empty connection
+	// string uses the first device found by libnfc.
+	Connection string
+
+	// Modulations are polled in order on every scan. Ex the modulations returned by ParseModulation for "iso14443a"
+	// and "felica". DefaultModulations is used if it is empty.
+	Modulations []nfc.Modulation
+
+	// SecureCredential enables reading a signed credential from a DESFire application on every badge. The credential
+	// number is used as the badge id instead of the UID, and badges without a valid credential are reported as errors.
+	// The credential is only read once while a badge stays on the reader.
+	SecureCredential bool
+
+	// Credential describes the application, keys and file used to read the secure credential.
+	Credential desfire.CredentialConfig
 }
 
 // LibNFCScanner implements the scanner interface for a libnfc compatible device.
 type LibNFCScanner struct {
+	reader  string
 	device  LibNFCDevice
-	mod     nfc.Modulation
-	ids     chan string
+	mods    []nfc.Modulation
+	secure  bool
+	cred    desfire.CredentialConfig
+	present map[string]credentialRead
+	events  chan ScanEvent
 	errors  chan error
-	quit    chan bool
+	cancel  context.CancelFunc
 	wg      *sync.WaitGroup
 	started bool
 }
 
+// credentialRead is the result of reading the secure credential from a badge that is still on the reader.
+type credentialRead struct {
+	id  string
+	err error
+}
+
 // NewDefaultLibNFCScanner provides an initialized libnfc scanner. The provided channels can be read off of in order to
-// get a stream of id strings or errors from the device. The id and error channel should be buffered, otherwise the
-// scanner will block until ids/errors are read off of the respective channel. Be sure to call Close when you are done
-// with the scanner to clean up.
-func NewDefaultLibNFCScanner(ids chan string, errs chan error) (*LibNFCScanner, error) {
-	device, err := nfc.Open("")
+// get a stream of scan events or errors from the device. The event and error channel should be buffered, otherwise the
+// scanner will block until events/errors are read off of the respective channel. Be sure to call Close when you are
+// done with the scanner to clean up.
+func NewDefaultLibNFCScanner(events chan ScanEvent, errs chan error) (*LibNFCScanner, error) {
+	return OpenLibNFCScanner(LibNFCScannerConfig{Reader: DefaultReaderName}, events, errs)
+}
+
+// OpenLibNFCScanner opens the libnfc device described by the provided configuration and provides an initialized libnfc
+// scanner for it. See NewDefaultLibNFCScanner for how the channels are used.
+func OpenLibNFCScanner(cfg LibNFCScannerConfig, events chan ScanEvent, errs chan error) (*LibNFCScanner, error) {
+	device, err := nfc.Open(cfg.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewLibNFCScannerWithConfig(device, cfg, events, errs)
 	if err != nil {
+		device.Close()
 		return nil, err
 	}
 
-	return NewLibNFCScanner(device, ids, errs)
+	return s, nil
 }
 
-// NewLibNFCScanner provides an initialized libnfc scanner with the provided LibNFCDevice. The provided channels can be
-// read off of in order to get a stream of id strings or errors from the device. The id and error channel should be
-// buffered, otherwise the scanner will block until ids/errors are read off of the respective channel. Be sure to call
-// Close when you are done with the scanner to clean up.
-func NewLibNFCScanner(device LibNFCDevice, ids chan string, errs chan error) (*LibNFCScanner, error) {
+// NewLibNFCScanner provides an initialized libnfc scanner with the provided LibNFCDevice. Every ScanEvent is tagged
+// with the provided reader name. The provided channels can be read off of in order to get a stream of scan events or
+// errors from the device. The event and error channel should be buffered, otherwise the scanner will block until
+// events/errors are read off of the respective channel. Be sure to call Close when you are done with the scanner to
+// clean up.
+func NewLibNFCScanner(reader string, device LibNFCDevice, events chan ScanEvent,
+	errs chan error) (*LibNFCScanner, error) {
+	return NewLibNFCScannerWithConfig(device, LibNFCScannerConfig{Reader: reader}, events, errs)
+}
+
+// NewLibNFCScannerWithConfig provides an initialized libnfc scanner with the provided LibNFCDevice and configuration.
+// The connection string in the configuration is ignored. See NewLibNFCScanner for how the channels are used.
+func NewLibNFCScannerWithConfig(device LibNFCDevice, cfg LibNFCScannerConfig, events chan ScanEvent,
+	errs chan error) (*LibNFCScanner, error) {
+	if cfg.SecureCredential {
+		err := cfg.Credential.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err := device.InitiatorInit()
 	if err != nil {
 		return nil, err
 	}
 
-	mod := nfc.Modulation{
-		Type:     nfc.ISO14443a,
-		BaudRate: 1,
+	mods := cfg.Modulations
+	if len(mods) == 0 {
+		mods = DefaultModulations
 	}
 
 	var wg sync.WaitGroup
 
 	return &LibNFCScanner{
+		reader:  cfg.Reader,
 		device:  device,
-		mod:     mod,
-		ids:     ids,
+		mods:    mods,
+		secure:  cfg.SecureCredential,
+		cred:    cfg.Credential,
+		events:  events,
 		errors:  errs,
 		started: false,
-		quit:    make(chan bool),
 		wg:      &wg,
 	}, nil
 }
 
-// Scan starts the scanner if it has not already been started.
-func (s *LibNFCScanner) Scan() {
+// Scan starts the scanner if it has not already been started. The scanner stops once the provided context is
+// cancelled or Done is called.
+func (s *LibNFCScanner) Scan(ctx context.Context) {
 	if s.started {
 		return
 	}
 
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.started = true
 	s.wg.Add(1)
-	go s.run()
+	go s.run(ctx)
 }
 
 // Done will stop all goroutines and close the LibNFCDevice.
 func (s *LibNFCScanner) Done() error {
-	s.quit <- true
-	s.wg.Wait()
-	s.started = false
+	if s.started {
+		s.cancel()
+		s.wg.Wait()
+		s.started = false
+	}
+
 	return s.device.Close()
 }
 
-func (s *LibNFCScanner) run() {
+func (s *LibNFCScanner) run(ctx context.Context) {
 	defer s.wg.Done()
 
 	for {
 		select {
-		case <-s.quit:
+		case <-ctx.Done():
 			return
 		default:
-			s.scan()
+			s.scan(ctx)
 		}
 	}
 }
 
-func (s *LibNFCScanner) scan() {
-	targets, err := s.device.InitiatorListPassiveTargets(s.mod)
-	if err != nil {
-		s.errors <- err
-		return
-	}
+func (s *LibNFCScanner) scan(ctx context.Context) {
+	// Only the badges found by this scan are kept, so a badge is read again once it has left the reader.
+	present := map[string]credentialRead{}
+	defer func() {
+		s.present = present
+	}()
 
-	for _, target := range targets {
-		t, ok := target.(*nfc.ISO14443aTarget)
-		if !ok {
-			s.errors <- errors.New(ErrUnsupportedTagType)
+	for _, mod := range s.mods {
+		targets, err := s.device.InitiatorListPassiveTargets(mod)
+		if err != nil {
+			s.sendError(ctx, &ReaderError{Reader: s.reader, Err: err})
 			continue
 		}
 
-		id := s.convert(t.UID[:t.UIDLen])
-		s.ids <- id
+		for _, target := range targets {
+			event, ok := targetEvent(target)
+			if !ok {
+				s.sendError(ctx, &ReaderError{Reader: s.reader, Err: errors.New(ErrUnsupportedTagType)})
+				continue
+			}
+
+			event.Reader = s.reader
+			event.Timestamp = time.Now()
+
+			if s.secure {
+				// A badge that stays on the reader is only authenticated, and its failure only reported, once.
+				read, ok := s.present[event.ID]
+				if !ok {
+					read.id, read.err = s.readCredential(mod, event)
+					if read.err != nil {
+						s.sendError(ctx, &ReaderError{
+							Reader: s.reader,
+							Err:    fmt.Errorf("%s %s - %s", ErrSecureCredential, event.ID, read.err),
+						})
+					}
+				}
+
+				present[event.ID] = read
+				if read.err != nil {
+					continue
+				}
+
+				event.ID = read.id
+				event.Credential = true
+			}
+
+			select {
+			case s.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// sendError sends the error unless the scanner is stopped first, so that a full error channel can not block shutdown.
+func (s *LibNFCScanner) sendError(ctx context.Context, err error) {
+	select {
+	case s.errors <- err:
+	case <-ctx.Done():
 	}
 }
 
-func (s *LibNFCScanner) convert(uid []byte) string {
-	return hex.EncodeToString(uid)
+// readCredential selects the badge with the modulation it was found with and reads its secure credential. The badge is
+// deselected afterwards so that it is found again by the next scan.
+func (s *LibNFCScanner) readCredential(mod nfc.Modulation, event ScanEvent) (string, error) {
+	if event.Technology != TechnologyISO14443A {
+		return "", errors.New(ErrSecureCredentialTechnology)
+	}
+
+	_, err := s.device.InitiatorSelectPassiveTarget(mod, event.UID)
+	if err != nil {
+		return "", err
+	}
+	defer s.device.InitiatorDeselectTarget()
+
+	number, err := desfire.ReadCredential(s.device, s.cred, event.UID)
+	if err != nil {
+		return "", err
+	}
+
+	return desfire.CredentialID(number), nil
 }
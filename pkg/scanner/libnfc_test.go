@@ -16,12 +16,20 @@
 package scanner_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/datastore"
+	"github.com/betterengineering/open-keyless/pkg/desfire"
+	"github.com/betterengineering/open-keyless/pkg/desfire/desfiretest"
 	"github.com/betterengineering/open-keyless/pkg/scanner"
 	"github.com/fuzxxl/nfc/2.0/nfc"
 	"github.com/golang/mock/gomock"
@@ -31,41 +39,48 @@ func TestLibNFCScanner(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	ids := make(chan string, 100)
+	events := make(chan scanner.ScanEvent, 100)
 	errs := make(chan error, 100)
 	device, err := givenInitializedDevice(ctrl)
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
 
-	s, err := scanner.NewLibNFCScanner(device, ids, errs)
+	s, err := scanner.NewLibNFCScanner("entry", device, events, errs)
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
 
-	var errorFound bool
-	var idFound bool
-	go func() {
-		for {
-			select {
-			case <-ids:
-				idFound = true
-			case <-errs:
-				errorFound = true
-			}
-		}
-	}()
+	s.Scan(context.Background())
+	defer s.Done()
+
+	var event scanner.ScanEvent
+	select {
+	case event = <-events:
+	case err := <-errs:
+		t.Fatalf("error found while scanning for devices - %s", err)
+	case <-time.After(time.Second):
+		t.Fatalf("there were no ids found while scanning")
+	}
+
+	if event.ID != "8604de7d" {
+		t.Errorf("expected id '8604de7d' but got '%s'", event.ID)
+	}
 
-	s.Scan()
-	time.Sleep(5 * time.Millisecond)
-	s.Done()
+	if event.Reader != "entry" {
+		t.Errorf("expected reader 'entry' but got '%s'", event.Reader)
+	}
+
+	if event.Technology != scanner.TechnologyISO14443A {
+		t.Errorf("expected technology '%s' but got '%s'", scanner.TechnologyISO14443A, event.Technology)
+	}
 
-	if errorFound {
-		t.Errorf("error found while scanning for devices")
+	if event.UIDLength() != 4 {
+		t.Errorf("expected a uid length of 4 but got %d", event.UIDLength())
 	}
 
-	if !idFound {
-		t.Errorf("there were no ids found while scanning")
+	if event.SAK != 0x08 {
+		t.Errorf("expected sak '08' but got '%02x'", event.SAK)
 	}
 }
 
@@ -73,44 +88,421 @@ func TestLibNFCScannerWithError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	ids := make(chan string, 100)
+	events := make(chan scanner.ScanEvent, 100)
 	errs := make(chan error, 100)
 	device, err := givenInitializedErrorDevice(ctrl)
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
 
-	s, err := scanner.NewLibNFCScanner(device, ids, errs)
+	s, err := scanner.NewLibNFCScanner("entry", device, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s.Scan(context.Background())
+	defer s.Done()
+
+	select {
+	case event := <-events:
+		t.Errorf("expected no ids but got id '%s'", event.ID)
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Errorf("there was not an error found when one was expected")
+	}
+}
+
+func TestLibNFCScannerStopsWhenContextCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing reads from the channels, so the scanner blocks sending the first event until it is stopped.
+	events := make(chan scanner.ScanEvent)
+	errs := make(chan error)
+	device, err := givenInitializedDevice(ctrl)
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
 
-	var errorFound bool
-	var idFound bool
+	s, err := scanner.NewLibNFCScanner("entry", device, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Scan(ctx)
+	time.Sleep(1 * time.Millisecond)
+	cancel()
+
+	done := make(chan error)
 	go func() {
-		for {
-			select {
-			case <-ids:
-				idFound = true
-			case <-errs:
-				errorFound = true
-			}
-		}
+		done <- s.Done()
 	}()
 
-	s.Scan()
-	time.Sleep(1 * time.Millisecond)
-	s.Done()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("error closing the scanner - %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("scanner did not stop after its context was cancelled")
+	}
+}
 
-	if !errorFound {
-		t.Errorf("there was not an error found when one was expected")
+func TestLibNFCScannerWithModulations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	iso14443a, err := scanner.ParseModulation("iso14443a")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	felica, err := scanner.ParseModulation("FeliCa")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	targets, err := generateFakeTargets()
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	felicaTarget := &nfc.FelicaTarget{
+		ID:      [8]byte{0x86, 0x04, 0xde, 0x7d, 0x00, 0x00, 0x00, 0x00},
+		SysCode: [2]byte{0x00, 0x03},
+	}
+
+	device := mocks.NewMockLibNFCDevice(ctrl)
+	device.EXPECT().InitiatorInit().Return(nil).Times(1)
+	device.EXPECT().InitiatorListPassiveTargets(iso14443a).Return(targets, nil).AnyTimes()
+	device.EXPECT().InitiatorListPassiveTargets(felica).Return([]nfc.Target{felicaTarget}, nil).AnyTimes()
+	device.EXPECT().Close().Return(nil).Times(1)
+
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+	s, err := scanner.NewLibNFCScannerWithConfig(device, scanner.LibNFCScannerConfig{
+		Reader:      "entry",
+		Modulations: []nfc.Modulation{iso14443a, felica},
+	}, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s.Scan(context.Background())
+	defer s.Done()
+
+	ids := map[string]string{}
+	for len(ids) < 2 {
+		select {
+		case event := <-events:
+			ids[event.ID] = event.Technology
+		case err := <-errs:
+			t.Fatalf("unexpected error while scanning - %s", err)
+		case <-time.After(time.Second):
+			t.Fatalf("expected events for both technologies but got '%v'", ids)
+		}
+	}
+
+	if ids["8604de7d"] != scanner.TechnologyISO14443A {
+		t.Errorf("expected the ISO14443A id to not be namespaced but got '%v'", ids)
+	}
+
+	if ids["felica:8604de7d00000000"] != scanner.TechnologyFeliCa {
+		t.Errorf("expected the FeliCa id to be namespaced but got '%v'", ids)
+	}
+}
+
+func TestLibNFCScannerWithUnsupportedTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	device := mocks.NewMockLibNFCDevice(ctrl)
+	device.EXPECT().InitiatorInit().Return(nil).Times(1)
+	device.EXPECT().InitiatorListPassiveTargets(gomock.Any()).Return([]nfc.Target{&nfc.DEPTarget{}}, nil).AnyTimes()
+	device.EXPECT().Close().Return(nil).Times(1)
+
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+	s, err := scanner.NewLibNFCScanner("entry", device, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
 	}
 
-	if idFound {
-		t.Errorf("there were no ids found while scanning")
+	s.Scan(context.Background())
+	defer s.Done()
+
+	select {
+	case event := <-events:
+		t.Fatalf("expected no events but got id '%s'", event.ID)
+	case err := <-errs:
+		if err.Error() != scanner.ErrUnsupportedTagType {
+			t.Errorf("expected error '%s' but got '%s'", scanner.ErrUnsupportedTagType, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected an error for the unsupported target")
 	}
 }
 
+func TestParseModulation(t *testing.T) {
+	for _, name := range scanner.Modulations() {
+		_, err := scanner.ParseModulation(name)
+		if err != nil {
+			t.Errorf("error parsing modulation '%s' - %s", name, err)
+		}
+	}
+
+	_, err := scanner.ParseModulation("ISO15693")
+	if err == nil || err.Error() != scanner.ErrISO15693Unsupported {
+		t.Errorf("expected error '%s' but got '%v'", scanner.ErrISO15693Unsupported, err)
+	}
+
+	_, err = scanner.ParseModulation("mifare")
+	if err == nil || !strings.HasPrefix(err.Error(), scanner.ErrUnsupportedModulation) {
+		t.Errorf("expected error '%s' but got '%v'", scanner.ErrUnsupportedModulation, err)
+	}
+}
+
+func TestLibNFCScannerWithSecureCredential(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := givenSecureCredentialConfig()
+	uid, err := hex.DecodeString("8604de7d")
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	data, err := desfire.SignCredential(cfg.Credential.SigningKey, cfg.Credential.AID, uid, 0x1234)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	card := desfiretest.NewCard()
+	card.AddApplication(cfg.Credential.AID, &desfiretest.Application{
+		Keys:  [][]byte{cfg.Credential.Key},
+		Files: map[byte][]byte{cfg.Credential.FileNumber: data},
+	})
+
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+	device, err := givenSecureCredentialDevice(ctrl, card)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s, err := scanner.NewLibNFCScannerWithConfig(device, cfg, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s.Scan(context.Background())
+	defer s.Done()
+
+	select {
+	case event := <-events:
+		if event.ID != "desfire:0000000000001234" {
+			t.Errorf("expected id 'desfire:0000000000001234' but got '%s'", event.ID)
+		}
+
+		if !event.Credential {
+			t.Errorf("expected the event to be marked as a credential")
+		}
+
+		if event.UIDLength() != 4 {
+			t.Errorf("expected a uid length of 4 but got %d", event.UIDLength())
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error reading the credential - %s", err)
+	case <-time.After(time.Second):
+		t.Fatalf("there were no ids found while scanning")
+	}
+}
+
+func TestLibNFCScannerUIDDoesNotMatchCredential(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// A card emulating a UID that equals an enrolled credential number, read by a reader that does not check
+	// credentials.
+	var uid [10]byte
+	copy(uid[:], []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x34})
+	targets := []nfc.Target{
+		&nfc.ISO14443aTarget{
+			Atqa:   [2]byte{0x00, 0x04},
+			Sak:    0x08,
+			UID:    uid,
+			UIDLen: 8,
+		},
+	}
+
+	device := mocks.NewMockLibNFCDevice(ctrl)
+	device.EXPECT().InitiatorInit().Return(nil).Times(1)
+	device.EXPECT().InitiatorListPassiveTargets(gomock.Any()).Return(targets, nil).AnyTimes()
+	device.EXPECT().Close().Return(nil).Times(1)
+
+	ds, cleanup, err := givenCredentialDatastore(desfire.CredentialID(0x1234))
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer cleanup()
+
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+	s, err := scanner.NewLibNFCScanner("entry", device, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s.Scan(context.Background())
+	defer s.Done()
+
+	select {
+	case event := <-events:
+		if event.ID != "0000000000001234" {
+			t.Errorf("expected id '0000000000001234' but got '%s'", event.ID)
+		}
+
+		_, err := ds.GetBadge(event.ID)
+		if err == nil || err.Error() != datastore.ErrBadgeDoesNotExist {
+			t.Errorf("expected the uid to not match the credential badge but got '%v'", err)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error while scanning - %s", err)
+	case <-time.After(time.Second):
+		t.Fatalf("there were no ids found while scanning")
+	}
+}
+
+func TestLibNFCScannerWithoutSecureCredential(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The card does not have the site application, like a cloned UID would not.
+	card := desfiretest.NewCard()
+
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+	device, err := givenSecureCredentialDevice(ctrl, card)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s, err := scanner.NewLibNFCScannerWithConfig(device, givenSecureCredentialConfig(), events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s.Scan(context.Background())
+	defer s.Done()
+
+	select {
+	case event := <-events:
+		t.Fatalf("expected no events but got id '%s'", event.ID)
+	case err := <-errs:
+		readerErr, ok := err.(*scanner.ReaderError)
+		if !ok || readerErr.Reader != "entry" {
+			t.Errorf("expected a reader error for 'entry' but got '%v'", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected an error reading the credential")
+	}
+
+	// The badge stays on the reader, which does not authenticate with it or report the failure again.
+	select {
+	case event := <-events:
+		t.Fatalf("expected no events but got id '%s'", event.ID)
+	case err := <-errs:
+		t.Errorf("expected a single error while the badge stays on the reader but got '%s'", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLibNFCScannerWithInvalidSecureCredentialConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := givenSecureCredentialConfig()
+	cfg.Credential.Key = nil
+
+	_, err := scanner.NewLibNFCScannerWithConfig(mocks.NewMockLibNFCDevice(ctrl), cfg, nil, nil)
+	if err == nil {
+		t.Errorf("expected an error for a secure credential config without a key")
+	}
+}
+
+func givenSecureCredentialConfig() scanner.LibNFCScannerConfig {
+	return scanner.LibNFCScannerConfig{
+		Reader:           "entry",
+		SecureCredential: true,
+		Credential: desfire.CredentialConfig{
+			AID:        0xF51CD0,
+			KeyNumber:  0,
+			Key:        bytes.Repeat([]byte{0x11}, desfire.KeyLength),
+			FileNumber: 1,
+			SigningKey: bytes.Repeat([]byte{0x22}, desfire.KeyLength),
+		},
+	}
+}
+
+func givenCredentialDatastore(id string) (datastore.Datastore, func(), error) {
+	file, err := ioutil.TempFile("", "badges")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		os.Remove(file.Name())
+	}
+
+	_, err = file.WriteString(id + ",card,true\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	ds, err := datastore.NewTextFile(datastore.TextFileConfig{Path: file.Name()})
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return ds, cleanup, nil
+}
+
+func givenSecureCredentialDevice(ctrl *gomock.Controller, card *desfiretest.Card) (scanner.LibNFCDevice, error) {
+	device := mocks.NewMockLibNFCDevice(ctrl)
+
+	mod := nfc.Modulation{
+		Type:     nfc.ISO14443a,
+		BaudRate: 1,
+	}
+
+	targets, err := generateFakeTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := hex.DecodeString("8604de7d")
+	if err != nil {
+		return nil, err
+	}
+
+	device.EXPECT().InitiatorInit().Return(nil).Times(1)
+	device.EXPECT().InitiatorListPassiveTargets(mod).Return(targets, nil).AnyTimes()
+	// The credential is only read once because the badge never leaves the reader.
+	device.EXPECT().InitiatorSelectPassiveTarget(mod, uid).Return(targets[0], nil).Times(1)
+	device.EXPECT().InitiatorTransceiveBytes(gomock.Any(), gomock.Any(), desfire.TransceiveTimeout).
+		DoAndReturn(card.InitiatorTransceiveBytes).AnyTimes()
+	device.EXPECT().InitiatorDeselectTarget().Return(nil).AnyTimes()
+	device.EXPECT().Close().Return(nil).Times(1)
+
+	return device, nil
+}
+
 func givenInitializedDevice(ctrl *gomock.Controller) (scanner.LibNFCDevice, error) {
 	device := mocks.NewMockLibNFCDevice(ctrl)
 
@@ -157,6 +549,8 @@ func generateFakeTargets() ([]nfc.Target, error) {
 
 	targets := []nfc.Target{
 		&nfc.ISO14443aTarget{
+			Atqa:   [2]byte{0x00, 0x04},
+			Sak:    0x08,
 			UID:    uid,
 			UIDLen: len(b),
 		},
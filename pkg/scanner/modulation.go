@@ -0,0 +1,120 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package scanner
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fuzxxl/nfc/2.0/nfc"
+)
+
+const (
+	// ErrUnsupportedModulation is returned when a modulation name is not one of the names returned by Modulations.
+	ErrUnsupportedModulation = "the modulation is not supported"
+
+	// ErrISO15693Unsupported is returned when ISO15693 is requested. libnfc has no ISO15693 initiator modulation, so
+	// vicinity tags can not be polled by a libnfc reader.
+	ErrISO15693Unsupported = "iso15693 is not supported because libnfc can not poll ISO15693 tags"
+)
+
+// modulations maps the names accepted by ParseModulation to libnfc modulations. FeliCa tags are polled at 212 kbps,
+// which every FeliCa tag supports, unless the 424 kbps variant is requested.
+var modulations = map[string]nfc.Modulation{
+	"iso14443a":    {Type: nfc.ISO14443a, BaudRate: nfc.Nbr106},
+	"iso14443b":    {Type: nfc.ISO14443b, BaudRate: nfc.Nbr106},
+	"iso14443bi":   {Type: nfc.ISO14443bi, BaudRate: nfc.Nbr106},
+	"iso14443b2sr": {Type: nfc.ISO14443b2sr, BaudRate: nfc.Nbr106},
+	"iso14443b2ct": {Type: nfc.ISO14443b2ct, BaudRate: nfc.Nbr106},
+	"felica":       {Type: nfc.Felica, BaudRate: nfc.Nbr212},
+	"felica424":    {Type: nfc.Felica, BaudRate: nfc.Nbr424},
+	"jewel":        {Type: nfc.Jewel, BaudRate: nfc.Nbr106},
+}
+
+// DefaultModulations are the modulations polled by a libnfc scanner that is not configured with any.
+var DefaultModulations = []nfc.Modulation{modulations["iso14443a"]}
+
+// Modulations returns the names accepted by ParseModulation in sorted order.
+func Modulations() []string {
+	names := []string{}
+	for name := range modulations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseModulation returns the libnfc modulation with the provided case insensitive name. Ex "felica".
+func ParseModulation(name string) (nfc.Modulation, error) {
+	name = strings.ToLower(name)
+	if name == "iso15693" {
+		return nfc.Modulation{}, errors.New(ErrISO15693Unsupported)
+	}
+
+	mod, ok := modulations[name]
+	if !ok {
+		return nfc.Modulation{}, fmt.Errorf("%s - %s", ErrUnsupportedModulation, name)
+	}
+
+	return mod, nil
+}
+
+// targetEvent provides a ScanEvent describing the target, without the reader and timestamp. It returns false if the
+// target is not a supported tag type. The identifiers used are the ones that tags keep across reads: the UID of
+// ISO14443A, SRx, CTx and Jewel tags, the PUPI of ISO14443B tags, the DIV of B' tags and the IDm of FeliCa tags.
+func targetEvent(target nfc.Target) (ScanEvent, bool) {
+	switch t := target.(type) {
+	case *nfc.ISO14443aTarget:
+		event := newEvent(TechnologyISO14443A, t.UID[:t.UIDLen])
+		event.ATQA = t.Atqa
+		event.SAK = t.Sak
+		return event, true
+	case *nfc.ISO14443bTarget:
+		return newEvent(TechnologyISO14443B, t.Pupi[:]), true
+	case *nfc.ISO14443biTarget:
+		return newEvent(TechnologyISO14443BI, t.DIV[:]), true
+	case *nfc.ISO14443b2srTarget:
+		return newEvent(TechnologyISO14443B2SR, t.UID[:]), true
+	case *nfc.ISO14443b2ctTarget:
+		return newEvent(TechnologyISO14443B2CT, t.UID[:]), true
+	case *nfc.FelicaTarget:
+		return newEvent(TechnologyFeliCa, t.ID[:]), true
+	case *nfc.JewelTarget:
+		return newEvent(TechnologyJewel, t.ID[:]), true
+	}
+
+	return ScanEvent{}, false
+}
+
+// newEvent provides a ScanEvent with the id namespaced by the technology. ISO14443A ids are not namespaced so that
+// badges added before other technologies were supported keep working.
+func newEvent(technology string, uid []byte) ScanEvent {
+	uid = append([]byte{}, uid...)
+	id := hex.EncodeToString(uid)
+	if technology != TechnologyISO14443A {
+		id = strings.ToLower(technology) + ":" + id
+	}
+
+	return ScanEvent{
+		ID:         id,
+		Technology: technology,
+		UID:        uid,
+	}
+}
@@ -16,8 +16,105 @@
 // Package scanner is used to communicate with an RFID badge scanners.
 package scanner
 
-// Scanner is an interface used to start and stop an RFID badge scanner.
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultReaderName is the reader name used by scanners created with one of the default constructors.
+	DefaultReaderName = "default"
+
+	// TechnologyISO14443A is the tag technology of ISO/IEC 14443 type A tags such as MIFARE cards.
+	TechnologyISO14443A = "ISO14443A"
+
+	// TechnologyISO14443B is the tag technology of ISO/IEC 14443 type B tags.
+	TechnologyISO14443B = "ISO14443B"
+
+	// TechnologyISO14443BI is the tag technology of pre-standard ISO/IEC 14443 type B' tags, such as Calypso transit
+	// cards.
+	TechnologyISO14443BI = "ISO14443BI"
+
+	// TechnologyISO14443B2SR is the tag technology of ST SRx tags.
+	TechnologyISO14443B2SR = "ISO14443B2SR"
+
+	// TechnologyISO14443B2CT is the tag technology of ASK CTx tags.
+	TechnologyISO14443B2CT = "ISO14443B2CT"
+
+	// TechnologyFeliCa is the tag technology of Sony FeliCa tags such as many transit cards.
+	TechnologyFeliCa = "FeliCa"
+
+	// TechnologyJewel is the tag technology of Innovision Jewel and Topaz tags.
+	TechnologyJewel = "Jewel"
+
+	// TechnologyUnknown is used when the scanner can not determine the tag technology, such as HID readers that only
+	// report the UID.
+	TechnologyUnknown = "unknown"
+)
+
+// Scanner is an interface used to start and stop an RFID badge scanner. A scanner stops scanning once the context
+// provided to Scan is cancelled or Done is called.
 type Scanner interface {
-	Scan()
+	Scan(ctx context.Context)
 	Done() error
 }
+
+// ScanEvent is emitted by a scanner every time a badge is read.
+type ScanEvent struct {
+	// ID is the identifier used to look up the badge in the datastore. For ISO14443A tags this is the hex encoded UID,
+	// or the hex encoded credential number when Credential is true. For other technologies the hex encoded identifier
+	// is prefixed with the lower case technology so that identical identifiers from different technologies can not
+	// collide. Ex "felica:0123456789abcdef". Wiegand badges use the frame length, facility code and card number. Ex
+	// "wiegand26:123:4567".
+	ID string
+
+	// Reader is the name of the reader that read the badge.
+	Reader string
+
+	// Timestamp is the time the badge was read.
+	Timestamp time.Time
+
+	// Technology is the tag technology of the badge. Ex "ISO14443A".
+	Technology string
+
+	// UID is the unique identifier read from the badge.
+	UID []byte
+
+	// ATQA is the answer to request sent by ISO14443A tags. It is empty for other technologies.
+	ATQA [2]byte
+
+	// SAK is the select acknowledge sent by ISO14443A tags. It is zero for other technologies.
+	SAK byte
+
+	// FacilityCode is the facility or company code of badges read by a Wiegand reader. It is zero for other scanners.
+	FacilityCode uint32
+
+	// CardNumber is the card number of badges read by a Wiegand reader. It is zero for other scanners.
+	CardNumber uint64
+
+	// Credential is true when the ID is the number of a secure credential read from an authenticated badge rather than
+	// the UID.
+	Credential bool
+
+	// Removed is true when the event signals that the badge has left the reader's field rather than being read.
+	Removed bool
+}
+
+// UIDLength returns the length of the UID in bytes.
+func (e ScanEvent) UIDLength() int {
+	return len(e.UID)
+}
+
+// ReaderError is sent on a scanner's error channel and wraps an error with the name of the reader that produced it.
+type ReaderError struct {
+	// Reader is the name of the reader that produced the error.
+	Reader string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *ReaderError) Error() string {
+	return e.Err.Error()
+}
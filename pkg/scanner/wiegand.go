@@ -0,0 +1,416 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/host"
+)
+
+const (
+	// ErrWiegandPinNotFound is returned when a Wiegand data pin can not be found.
+	ErrWiegandPinNotFound = "could not find the Wiegand data pin"
+
+	// ErrWiegandUnsupportedFormat is returned when a Wiegand frame does not have the length of a supported format.
+	ErrWiegandUnsupportedFormat = "the Wiegand frame length does not match a supported format"
+
+	// ErrWiegandParity is returned when a Wiegand frame fails a parity check.
+	ErrWiegandParity = "the Wiegand frame failed a parity check"
+)
+
+const (
+	// TechnologyWiegand is the technology of badges read by a Wiegand reader, which does not report the technology of
+	// the badge itself.
+	TechnologyWiegand = "Wiegand"
+
+	// DefaultWiegandFrameTimeout is how long the data lines must be idle before the bits received are decoded as a
+	// frame. Readers send a bit every 1 to 2ms.
+	DefaultWiegandFrameTimeout = 25 * time.Millisecond
+
+	// wiegandEdgeTimeout is how long each data line is waited on before checking if the scanner was stopped.
+	wiegandEdgeTimeout = 100 * time.Millisecond
+
+	// maxWiegandBits is the most bits kept for a single frame. Longer frames are reported as unsupported.
+	maxWiegandBits = 64
+)
+
+// WiegandScannerConfig is a configuration struct for a WiegandScanner.
+type WiegandScannerConfig struct {
+	// Reader is the name of the reader included in every ScanEvent. Ex "entry".
+	Reader string
+
+	// D0Pin is the name of the GPIO pin connected to the DATA0 line of the reader, which pulses low for a 0 bit.
+	D0Pin string
+
+	// D1Pin is the name of the GPIO pin connected to the DATA1 line of the reader, which pulses low for a 1 bit.
+	D1Pin string
+
+	// FrameTimeout is how long the data lines must be idle to end a frame. Defaults to DefaultWiegandFrameTimeout.
+	FrameTimeout time.Duration
+}
+
+// WiegandCredential is the credential decoded from a Wiegand frame.
+type WiegandCredential struct {
+	// Format is the name of the card format. Ex "H10301".
+	Format string
+
+	// Bits is the length of the frame.
+	Bits int
+
+	// FacilityCode is the facility or company code of the card.
+	FacilityCode uint32
+
+	// CardNumber is the number of the card within the facility.
+	CardNumber uint64
+}
+
+// ID provides the badge id of the credential, namespaced by the frame length and made up of the facility code and card
+// number as printed on most cards. Ex "wiegand26:123:4567".
+func (c WiegandCredential) ID() string {
+	return fmt.Sprintf("wiegand%d:%d:%d", c.Bits, c.FacilityCode, c.CardNumber)
+}
+
+// wiegandBit is a bit received on one of the data lines and when its pulse was seen.
+type wiegandBit struct {
+	value bool
+	at    time.Time
+}
+
+// wiegandParity is a parity bit and the bits it covers.
+type wiegandParity struct {
+	bit    int
+	odd    bool
+	covers []int
+}
+
+// wiegandFormat describes the layout of a Wiegand card format. Bit positions start at 0 with the first bit received.
+type wiegandFormat struct {
+	name     string
+	bits     int
+	facility [2]int
+	card     [2]int
+	parity   []wiegandParity
+}
+
+// wiegandFormats are the supported formats by frame length.
+var wiegandFormats = map[int]wiegandFormat{
+	26: {
+		name:     "H10301",
+		bits:     26,
+		facility: [2]int{1, 8},
+		card:     [2]int{9, 24},
+		parity: []wiegandParity{
+			{bit: 0, covers: bitRange(1, 12, nil)},
+			{bit: 25, odd: true, covers: bitRange(13, 24, nil)},
+		},
+	},
+	34: {
+		name:     "H10306",
+		bits:     34,
+		facility: [2]int{1, 16},
+		card:     [2]int{17, 32},
+		parity: []wiegandParity{
+			{bit: 0, covers: bitRange(1, 16, nil)},
+			{bit: 33, odd: true, covers: bitRange(17, 32, nil)},
+		},
+	},
+	35: {
+		name:     "Corporate1000",
+		bits:     35,
+		facility: [2]int{2, 13},
+		card:     [2]int{14, 33},
+		parity: []wiegandParity{
+			{bit: 1, covers: bitRange(2, 33, func(i int) bool { return (i-2)%3 != 2 })},
+			{bit: 34, odd: true, covers: bitRange(1, 32, func(i int) bool { return (i-1)%3 != 2 })},
+			{bit: 0, odd: true, covers: bitRange(1, 34, nil)},
+		},
+	},
+	37: {
+		name:     "H10304",
+		bits:     37,
+		facility: [2]int{1, 16},
+		card:     [2]int{17, 35},
+		parity: []wiegandParity{
+			{bit: 0, covers: bitRange(1, 18, nil)},
+			{bit: 36, odd: true, covers: bitRange(18, 35, nil)},
+		},
+	},
+}
+
+// bitRange returns the bit positions from first to last, inclusive, that are accepted by the filter. A nil filter
+// accepts every position.
+func bitRange(first int, last int, filter func(int) bool) []int {
+	bits := []int{}
+	for i := first; i <= last; i++ {
+		if filter == nil || filter(i) {
+			bits = append(bits, i)
+		}
+	}
+
+	return bits
+}
+
+// DecodeWiegand decodes a Wiegand frame, validating its parity bits and extracting the facility code and card number.
+// The first bit received is the first bit of the frame.
+func DecodeWiegand(frame []bool) (WiegandCredential, error) {
+	format, ok := wiegandFormats[len(frame)]
+	if !ok {
+		return WiegandCredential{}, fmt.Errorf("%s - %d bits", ErrWiegandUnsupportedFormat, len(frame))
+	}
+
+	for _, p := range format.parity {
+		ones := 0
+		for _, i := range append(p.covers, p.bit) {
+			if frame[i] {
+				ones++
+			}
+		}
+
+		if (ones%2 == 1) != p.odd {
+			return WiegandCredential{}, fmt.Errorf("%s - %s bit %d", ErrWiegandParity, format.name, p.bit)
+		}
+	}
+
+	return WiegandCredential{
+		Format:       format.name,
+		Bits:         format.bits,
+		FacilityCode: uint32(wiegandValue(frame, format.facility)),
+		CardNumber:   wiegandValue(frame, format.card),
+	}, nil
+}
+
+func wiegandValue(frame []bool, bits [2]int) uint64 {
+	var v uint64
+	for i := bits[0]; i <= bits[1]; i++ {
+		v <<= 1
+		if frame[i] {
+			v |= 1
+		}
+	}
+
+	return v
+}
+
+// WiegandScanner implements the scanner interface for a reader with a Wiegand output connected to two GPIO inputs.
+type WiegandScanner struct {
+	reader       string
+	d0           gpio.PinIn
+	d1           gpio.PinIn
+	frameTimeout time.Duration
+	events       chan ScanEvent
+	errors       chan error
+	cancel       context.CancelFunc
+	wg           *sync.WaitGroup
+	started      bool
+}
+
+// OpenWiegandScanner opens the GPIO pins described by the provided configuration and provides an initialized Wiegand
+// scanner for them. See NewWiegandScanner for how the channels are used.
+func OpenWiegandScanner(cfg WiegandScannerConfig, events chan ScanEvent, errs chan error) (*WiegandScanner, error) {
+	_, err := host.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	d0 := gpioreg.ByName(cfg.D0Pin)
+	if d0 == nil {
+		return nil, fmt.Errorf("%s - %s", ErrWiegandPinNotFound, cfg.D0Pin)
+	}
+
+	d1 := gpioreg.ByName(cfg.D1Pin)
+	if d1 == nil {
+		return nil, fmt.Errorf("%s - %s", ErrWiegandPinNotFound, cfg.D1Pin)
+	}
+
+	return NewWiegandScanner(d0, d1, cfg, events, errs)
+}
+
+// NewWiegandScanner provides an initialized Wiegand scanner for the provided DATA0 and DATA1 pins. The pins are pulled
+// up and watched for falling edges, since both lines idle high and pulse low for each bit. Every ScanEvent is tagged
+// with the reader name in the configuration, and frames that can not be decoded are sent on the error channel. The
+// event and error channel should be buffered, otherwise the scanner will block until events/errors are read off of the
+// respective channel.
+func NewWiegandScanner(d0 gpio.PinIn, d1 gpio.PinIn, cfg WiegandScannerConfig, events chan ScanEvent,
+	errs chan error) (*WiegandScanner, error) {
+	for _, pin := range []gpio.PinIn{d0, d1} {
+		err := pin.In(gpio.PullUp, gpio.FallingEdge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	frameTimeout := cfg.FrameTimeout
+	if frameTimeout <= 0 {
+		frameTimeout = DefaultWiegandFrameTimeout
+	}
+
+	var wg sync.WaitGroup
+
+	return &WiegandScanner{
+		reader:       cfg.Reader,
+		d0:           d0,
+		d1:           d1,
+		frameTimeout: frameTimeout,
+		events:       events,
+		errors:       errs,
+		wg:           &wg,
+	}, nil
+}
+
+// Scan starts the scanner if it has not already been started. The scanner stops once the provided context is
+// cancelled or Done is called.
+func (s *WiegandScanner) Scan(ctx context.Context) {
+	if s.started {
+		return
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.started = true
+
+	bits := make(chan wiegandBit, maxWiegandBits)
+	s.wg.Add(3)
+	go s.watch(ctx, s.d0, false, bits)
+	go s.watch(ctx, s.d1, true, bits)
+	go s.collect(ctx, bits)
+}
+
+// Done will stop all goroutines. The pins are left configured as inputs.
+func (s *WiegandScanner) Done() error {
+	if s.started {
+		s.cancel()
+		s.wg.Wait()
+		s.started = false
+	}
+
+	return nil
+}
+
+// watch sends the bit carried by the data line every time it pulses. Each bit is timestamped as soon as its edge is
+// seen, because the bits of both data lines are not guaranteed to reach the collector in the order they were received.
+func (s *WiegandScanner) watch(ctx context.Context, pin gpio.PinIn, bit bool, bits chan wiegandBit) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if !pin.WaitForEdge(wiegandEdgeTimeout) {
+			continue
+		}
+
+		select {
+		case bits <- wiegandBit{value: bit, at: time.Now()}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collect gathers bits into a frame until the data lines are idle for the frame timeout, then puts the bits in the
+// order they were received and decodes the frame. Pulses that were missed or merged leave a frame with a length that
+// does not match a supported format or that fails a parity check, so it is reported as an error instead of decoded.
+func (s *WiegandScanner) collect(ctx context.Context, bits chan wiegandBit) {
+	defer s.wg.Done()
+
+	frame := []wiegandBit{}
+	overflow := false
+	var idle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case bit := <-bits:
+			if len(frame) < maxWiegandBits {
+				frame = append(frame, bit)
+			} else {
+				overflow = true
+			}
+			idle = time.After(s.frameTimeout)
+		case <-idle:
+			if overflow {
+				s.sendError(ctx, fmt.Errorf("%s - more than %d bits", ErrWiegandUnsupportedFormat, maxWiegandBits))
+			} else {
+				s.decode(ctx, sortWiegandBits(frame))
+			}
+
+			frame = []wiegandBit{}
+			overflow = false
+			idle = nil
+		}
+	}
+}
+
+// sortWiegandBits returns the values of the bits in the order they were received.
+func sortWiegandBits(bits []wiegandBit) []bool {
+	sort.SliceStable(bits, func(i, j int) bool {
+		return bits[i].at.Before(bits[j].at)
+	})
+
+	frame := make([]bool, len(bits))
+	for i, bit := range bits {
+		frame[i] = bit.value
+	}
+
+	return frame
+}
+
+func (s *WiegandScanner) decode(ctx context.Context, frame []bool) {
+	credential, err := DecodeWiegand(frame)
+	if err != nil {
+		s.sendError(ctx, err)
+		return
+	}
+
+	uid := make([]byte, (len(frame)+7)/8)
+	for i, bit := range frame {
+		if bit {
+			uid[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+
+	event := ScanEvent{
+		ID:           credential.ID(),
+		Reader:       s.reader,
+		Timestamp:    time.Now(),
+		Technology:   TechnologyWiegand,
+		UID:          uid,
+		FacilityCode: credential.FacilityCode,
+		CardNumber:   credential.CardNumber,
+	}
+
+	select {
+	case s.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// sendError sends the error unless the scanner is stopped first, so that a full error channel can not block shutdown.
+func (s *WiegandScanner) sendError(ctx context.Context, err error) {
+	select {
+	case s.errors <- &ReaderError{Reader: s.reader, Err: err}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package scanner_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/internal/mocks"
+	"github.com/betterengineering/open-keyless/pkg/scanner"
+	"github.com/golang/mock/gomock"
+	"periph.io/x/periph/conn/gpio"
+)
+
+// h10301Frame is a 26 bit frame with facility code 12 and card number 34567.
+const h10301Frame = "1" + "00001100" + "1000011100000111" + "1"
+
+func TestDecodeWiegand(t *testing.T) {
+	credential, err := scanner.DecodeWiegand(givenFrame(h10301Frame))
+	if err != nil {
+		t.Fatalf("error decoding frame - %s", err)
+	}
+
+	if credential.Format != "H10301" || credential.FacilityCode != 12 || credential.CardNumber != 34567 {
+		t.Errorf("unexpected credential '%+v'", credential)
+	}
+
+	if credential.ID() != "wiegand26:12:34567" {
+		t.Errorf("expected id 'wiegand26:12:34567' but got '%s'", credential.ID())
+	}
+}
+
+func TestDecodeWiegandFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		bits     int
+		facility uint32
+		card     uint64
+	}{
+		{format: "H10301", bits: 26, facility: 255, card: 65535},
+		{format: "H10306", bits: 34, facility: 4660, card: 22136},
+		{format: "Corporate1000", bits: 35, facility: 1234, card: 987654},
+		{format: "H10304", bits: 37, facility: 31337, card: 400000},
+	}
+
+	for _, test := range tests {
+		frame := encodeWiegand(test.bits, test.facility, test.card)
+
+		credential, err := scanner.DecodeWiegand(frame)
+		if err != nil {
+			t.Errorf("error decoding %s frame - %s", test.format, err)
+			continue
+		}
+
+		decoded := credential.FacilityCode == test.facility && credential.CardNumber == test.card
+		if credential.Format != test.format || !decoded {
+			t.Errorf("expected %s %d:%d but got '%+v'", test.format, test.facility, test.card, credential)
+		}
+
+		// Flipping any single bit must fail a parity check.
+		for i := range frame {
+			frame[i] = !frame[i]
+			_, err := scanner.DecodeWiegand(frame)
+			if err == nil || !strings.HasPrefix(err.Error(), scanner.ErrWiegandParity) {
+				t.Errorf("expected a parity error for %s with bit %d flipped but got '%v'", test.format, i, err)
+			}
+			frame[i] = !frame[i]
+		}
+	}
+}
+
+func TestDecodeWiegandUnsupportedFormat(t *testing.T) {
+	_, err := scanner.DecodeWiegand(make([]bool, 30))
+	if err == nil || !strings.HasPrefix(err.Error(), scanner.ErrWiegandUnsupportedFormat) {
+		t.Errorf("expected error '%s' but got '%v'", scanner.ErrWiegandUnsupportedFormat, err)
+	}
+}
+
+func TestWiegandScanner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d0, pulseD0 := givenDataLine(ctrl)
+	d1, pulseD1 := givenDataLine(ctrl)
+
+	events := make(chan scanner.ScanEvent, 100)
+	errs := make(chan error, 100)
+	s, err := scanner.NewWiegandScanner(d0, d1, scanner.WiegandScannerConfig{Reader: "entry"}, events, errs)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	s.Scan(context.Background())
+	defer s.Done()
+
+	send := func(frame string) {
+		for _, bit := range frame {
+			if bit == '1' {
+				pulseD1()
+			} else {
+				pulseD0()
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	send(h10301Frame)
+	select {
+	case event := <-events:
+		if event.ID != "wiegand26:12:34567" || event.Reader != "entry" {
+			t.Errorf("unexpected event '%+v'", event)
+		}
+
+		if event.Technology != scanner.TechnologyWiegand || event.FacilityCode != 12 || event.CardNumber != 34567 {
+			t.Errorf("unexpected event '%+v'", event)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error decoding the frame - %s", err)
+	case <-time.After(time.Second):
+		t.Fatalf("expected an event for the frame")
+	}
+
+	send(h10301Frame[:20])
+	select {
+	case event := <-events:
+		t.Fatalf("expected no event for a short frame but got '%s'", event.ID)
+	case err := <-errs:
+		readerErr, ok := err.(*scanner.ReaderError)
+		if !ok || readerErr.Reader != "entry" || !strings.HasPrefix(err.Error(), scanner.ErrWiegandUnsupportedFormat) {
+			t.Errorf("expected a reader error for the short frame but got '%v'", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected an error for the short frame")
+	}
+}
+
+// givenDataLine provides a pin whose WaitForEdge returns true once for every call to the returned pulse function.
+func givenDataLine(ctrl *gomock.Controller) (*mocks.MockPinIO, func()) {
+	edges := make(chan bool, 64)
+
+	pin := mocks.NewMockPinIO(ctrl)
+	pin.EXPECT().In(gpio.PullUp, gpio.FallingEdge).Return(nil).Times(1)
+	pin.EXPECT().WaitForEdge(gomock.Any()).DoAndReturn(func(timeout time.Duration) bool {
+		select {
+		case <-edges:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}).AnyTimes()
+
+	return pin, func() { edges <- true }
+}
+
+func givenFrame(bits string) []bool {
+	frame := []bool{}
+	for _, bit := range bits {
+		frame = append(frame, bit == '1')
+	}
+
+	return frame
+}
+
+// encodeWiegand builds a frame with valid parity for the supported frame lengths.
+func encodeWiegand(bits int, facility uint32, card uint64) []bool {
+	frame := make([]bool, bits)
+	put := func(first int, last int, v uint64) {
+		for i := last; i >= first; i-- {
+			frame[i] = v&1 == 1
+			v >>= 1
+		}
+	}
+	parity := func(bit int, odd bool, covers func(int) bool) {
+		ones := 0
+		for i := range frame {
+			if i != bit && covers(i) && frame[i] {
+				ones++
+			}
+		}
+		frame[bit] = (ones%2 == 1) != odd
+	}
+	between := func(first int, last int) func(int) bool {
+		return func(i int) bool { return i >= first && i <= last }
+	}
+
+	switch bits {
+	case 26:
+		put(1, 8, uint64(facility))
+		put(9, 24, card)
+		parity(0, false, between(1, 12))
+		parity(25, true, between(13, 24))
+	case 34:
+		put(1, 16, uint64(facility))
+		put(17, 32, card)
+		parity(0, false, between(1, 16))
+		parity(33, true, between(17, 32))
+	case 35:
+		put(2, 13, uint64(facility))
+		put(14, 33, card)
+		parity(1, false, func(i int) bool { return i >= 2 && i <= 33 && (i-2)%3 != 2 })
+		parity(34, true, func(i int) bool { return i >= 1 && i <= 32 && (i-1)%3 != 2 })
+		parity(0, true, between(1, 34))
+	case 37:
+		put(1, 16, uint64(facility))
+		put(17, 35, card)
+		parity(0, false, between(1, 18))
+		parity(36, true, between(18, 35))
+	}
+
+	return frame
+}
@@ -0,0 +1,238 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package schedule provides named access schedules that restrict when a badge may be granted access. A schedule is made
+// up of weekly time windows evaluated in a given timezone along with a list of holidays on which the schedule never
+// grants access.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// ErrInvalidDay is returned when a window contains a day of the week that can not be parsed.
+	ErrInvalidDay = "could not parse the day of the week"
+
+	// ErrInvalidTimeOfDay is returned when a window start or end can not be parsed.
+	ErrInvalidTimeOfDay = "could not parse the time of day, expected the form 15:04"
+
+	// ErrInvalidHoliday is returned when a holiday can not be parsed.
+	ErrInvalidHoliday = "could not parse the holiday, expected the form 2006-01-02"
+
+	// ErrNoWindows is returned when a schedule does not contain any windows.
+	ErrNoWindows = "a schedule must contain at least one window"
+
+	dateLayout      = "2006-01-02"
+	timeOfDayLayout = "15:04"
+	minutesPerDay   = 24 * 60
+)
+
+var days = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Config is a configuration struct for a Schedule.
+type Config struct {
+	// Timezone is the IANA timezone the windows are evaluated in. Ex "America/Los_Angeles". An empty timezone uses
+	// the local timezone of the controller.
+	Timezone string
+
+	// Windows are the weekly time windows during which access is allowed.
+	Windows []WindowConfig
+
+	// Holidays are dates in the form "2006-01-02" on which access is never allowed.
+	Holidays []string
+}
+
+// WindowConfig is a configuration struct for a single weekly time window of a schedule.
+type WindowConfig struct {
+	// Days are the days of the week the window starts on. Ex ["mon", "tue"].
+	Days []string
+
+	// Start is the time of day the window opens in the form "15:04".
+	Start string
+
+	// End is the time of day the window closes in the form "15:04". If End is before Start, the window runs past
+	// midnight into the next day.
+	End string
+}
+
+// Schedule determines whether access is allowed at a given time.
+type Schedule struct {
+	name     string
+	location *time.Location
+	windows  []window
+	holidays map[string]bool
+}
+
+type window struct {
+	days  map[time.Weekday]bool
+	start int
+	end   int
+}
+
+// New provides a Schedule with the provided name and configuration.
+func New(name string, cfg Config) (*Schedule, error) {
+	location := time.Local
+	if cfg.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.Windows) == 0 {
+		return nil, errors.New(ErrNoWindows)
+	}
+
+	windows := []window{}
+	for _, windowConfig := range cfg.Windows {
+		w, err := newWindow(windowConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, w)
+	}
+
+	holidays := map[string]bool{}
+	for _, holiday := range cfg.Holidays {
+		_, err := time.Parse(dateLayout, holiday)
+		if err != nil {
+			return nil, fmt.Errorf("%s - %s", ErrInvalidHoliday, holiday)
+		}
+
+		holidays[holiday] = true
+	}
+
+	return &Schedule{
+		name:     name,
+		location: location,
+		windows:  windows,
+		holidays: holidays,
+	}, nil
+}
+
+// Name returns the name of the schedule.
+func (s *Schedule) Name() string {
+	return s.name
+}
+
+// Allows returns true if access is allowed at the provided time.
+func (s *Schedule) Allows(t time.Time) bool {
+	local := t.In(s.location)
+	if s.holidays[local.Format(dateLayout)] {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+	previousDay := (day + 6) % 7
+
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[day] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+
+		// The window runs past midnight, so it either started today or started yesterday and has not closed yet.
+		if w.days[day] && minute >= w.start {
+			return true
+		}
+
+		if w.days[previousDay] && minute < w.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newWindow(cfg WindowConfig) (window, error) {
+	w := window{
+		days: map[time.Weekday]bool{},
+	}
+
+	for _, day := range cfg.Days {
+		weekday, ok := parseDay(day)
+		if !ok {
+			return window{}, fmt.Errorf("%s - %s", ErrInvalidDay, day)
+		}
+
+		w.days[weekday] = true
+	}
+
+	start, err := parseTimeOfDay(cfg.Start)
+	if err != nil {
+		return window{}, err
+	}
+
+	end, err := parseTimeOfDay(cfg.End)
+	if err != nil {
+		return window{}, err
+	}
+
+	w.start = start
+	w.end = end
+
+	return w, nil
+}
+
+// parseDay parses a day of the week by its name or the first three letters of its name. Ex "mon" or "Monday".
+func parseDay(value string) (time.Weekday, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) < 3 {
+		return 0, false
+	}
+
+	weekday, ok := days[value[:3]]
+	if !ok {
+		return 0, false
+	}
+
+	if len(value) > 3 && value != strings.ToLower(weekday.String()) {
+		return 0, false
+	}
+
+	return weekday, true
+}
+
+// parseTimeOfDay parses a time of day in the form "15:04" into minutes since midnight. "24:00" is accepted as the end
+// of the day.
+func parseTimeOfDay(value string) (int, error) {
+	if value == "24:00" {
+		return minutesPerDay, nil
+	}
+
+	t, err := time.Parse(timeOfDayLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("%s - %s", ErrInvalidTimeOfDay, value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 Mark Spicer
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/betterengineering/open-keyless/pkg/schedule"
+)
+
+func TestScheduleAllows(t *testing.T) {
+	s, err := schedule.New("cleaning", schedule.Config{
+		Timezone: "UTC",
+		Windows: []schedule.WindowConfig{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "17:30"},
+			{Days: []string{"Saturday"}, Start: "22:00", End: "02:00"},
+		},
+		Holidays: []string{"2021-12-24"},
+	})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	cases := map[string]bool{
+		"2021-12-20T08:00:00Z": true,  // Monday, start of the window
+		"2021-12-20T17:29:59Z": true,  // Monday, end of the window
+		"2021-12-20T17:30:00Z": false, // Monday, after the window closes
+		"2021-12-20T07:59:00Z": false, // Monday, before the window opens
+		"2021-12-24T12:00:00Z": false, // Friday, holiday
+		"2021-12-25T12:00:00Z": false, // Saturday, no daytime window
+		"2021-12-25T23:00:00Z": true,  // Saturday night
+		"2021-12-26T01:59:00Z": true,  // Sunday morning, window started Saturday
+		"2021-12-26T02:00:00Z": false, // Sunday morning, after the window closes
+		"2021-12-26T23:00:00Z": false, // Sunday night, window only starts on Saturday
+	}
+
+	for value, expected := range cases {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("error setting up test - %s", err)
+		}
+
+		actual := s.Allows(at)
+		if actual != expected {
+			t.Errorf("expected '%t' at '%s' but got '%t'", expected, value, actual)
+		}
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	s, err := schedule.New("office", schedule.Config{
+		Timezone: "America/Los_Angeles",
+		Windows: []schedule.WindowConfig{
+			{Days: []string{"mon"}, Start: "09:00", End: "17:00"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	// 09:30 in Los Angeles on a Monday in December is 17:30 UTC.
+	at := time.Date(2021, time.December, 20, 17, 30, 0, 0, time.UTC)
+	if !s.Allows(at) {
+		t.Errorf("expected the window to be evaluated in the configured timezone")
+	}
+
+	at = time.Date(2021, time.December, 20, 9, 30, 0, 0, time.UTC)
+	if s.Allows(at) {
+		t.Errorf("expected the window to be evaluated in the configured timezone")
+	}
+}
+
+func TestScheduleInvalidConfig(t *testing.T) {
+	weekdays := []schedule.WindowConfig{{Days: []string{"mon"}, Start: "09:00", End: "17:00"}}
+	cases := map[string]schedule.Config{
+		"no windows":   {},
+		"bad timezone": {Timezone: "Mars/Olympus_Mons", Windows: weekdays},
+		"bad day":      {Windows: []schedule.WindowConfig{{Days: []string{"funday"}, Start: "09:00", End: "17:00"}}},
+		"bad start":    {Windows: []schedule.WindowConfig{{Days: []string{"mon"}, Start: "9am", End: "17:00"}}},
+		"bad holiday":  {Windows: weekdays, Holidays: []string{"12/25"}},
+	}
+
+	for name, cfg := range cases {
+		_, err := schedule.New(name, cfg)
+		if err == nil {
+			t.Errorf("expected an error for a schedule with a %s", name)
+		}
+	}
+}
@@ -21,10 +21,14 @@
 package strike
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"periph.io/x/periph/conn/gpio/gpioreg"
 
 	"periph.io/x/periph/host"
@@ -33,137 +37,373 @@ import (
 )
 
 const (
-	// ErrStrikeNotInitialized is returned when unlock is called after Done() has been called on the strike.
+	// ErrStrikeNotInitialized is returned when unlock or lock is called after Done() has been called on the strike.
 	ErrStrikeNotInitialized = "strike cannot be unlocked after Done() has been called"
 
 	// ErrCouldNotInitializeGPIOPin is returned when the GPIO pin for the strike can not be initialized.
 	ErrCouldNotInitializeGPIOPin = "could not initialize the GPIO pin for the strike"
+
+	// ErrInvalidMode is returned when a strike is configured with an unsupported mode.
+	ErrInvalidMode = "unsupported strike mode, expected fail-secure or fail-safe"
+
+	// ErrActuationFailed is returned when the GPIO pin for the strike could not be driven to lock or unlock it.
+	ErrActuationFailed = "could not drive the strike"
+
+	// ErrReadbackMismatch is returned when the GPIO pin for the strike does not read back the level it was driven to,
+	// such as when the pin is shorted or claimed by something else.
+	ErrReadbackMismatch = "the strike pin did not read back the level it was driven to"
+
+	// ModeFailSecure is a strike that stays locked without power and is powered to unlock.
+	ModeFailSecure = "fail-secure"
+
+	// ModeFailSafe is a strike that unlocks without power, such as on a fire exit, and is powered to stay locked.
+	ModeFailSafe = "fail-safe"
+
+	// StateLocked is the state of a strike that is locked.
+	StateLocked State = "locked"
+
+	// StateUnlocked is the state of a strike that is unlocked.
+	StateUnlocked State = "unlocked"
+
+	// StateFault is the state of a strike whose last actuation failed, so whether it is locked is unknown.
+	StateFault State = "fault"
+
+	// DefaultPin is the GPIO pin the strike is connected to when one is not configured.
+	DefaultPin = "16"
+)
+
+var (
+	strikeStateGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "open_keyless_strike_state",
+			Help: "The current state of the strike, 1 for the current state and 0 for every other state.",
+		},
+		[]string{"state"},
+	)
+	strikeUnlockedSecondsCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_keyless_strike_unlocked_seconds_total",
+			Help: "The total number of seconds the strike has been unlocked for.",
+		},
+	)
+	strikeActuationFailuresCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "open_keyless_strike_actuation_failures_total",
+			Help: "The total count of failures to lock or unlock the strike by action.",
+		},
+		[]string{"action"},
+	)
 )
 
+func init() {
+	prometheus.MustRegister(strikeStateGauge)
+	prometheus.MustRegister(strikeUnlockedSecondsCounter)
+	prometheus.MustRegister(strikeActuationFailuresCounter)
+}
+
+// State is the state of a strike as last driven and confirmed by the strike.
+type State string
+
+// Config is a configuration struct for a DoorStrike.
+type Config struct {
+	// Pin is the name of the GPIO pin that powers the strike. Ex "16" or "GPIO16". Defaults to DefaultPin.
+	Pin string
+
+	// ActiveLow is true when the strike is powered by driving the pin low rather than high, such as with a relay
+	// board that switches on a low input.
+	ActiveLow bool
+
+	// Mode is either ModeFailSecure or ModeFailSafe. Defaults to ModeFailSecure.
+	Mode string
+}
+
 // Strike is an interface for an electric door strike. This interface was created for the sole purpose of generating a
 // mock for this package which can be found in the internal/mocks package.
 type Strike interface {
 	Unlock(dur time.Duration) error
+	Lock() error
+	State() State
 	Done()
 }
 
 // DoorStrike is an implementation of the strike interface to control an electric door strike with a Raspberry Pi via
 // the GPIO interface.
 type DoorStrike struct {
-	initialized    bool
-	pin            gpio.PinIO
-	timeCtrlChan   chan time.Duration
-	strikeCtrlChan chan bool
-	quitStrike     chan bool
-	quitTime       chan bool
-	wg             *sync.WaitGroup
+	pin      gpio.PinIO
+	locked   gpio.Level
+	unlocked gpio.Level
+	commands chan command
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       *sync.WaitGroup
+	mu       sync.Mutex
+	done     bool
+
+	stateMu sync.Mutex
+	state   State
+	since   time.Time
+}
+
+// command is sent to the goroutine driving the strike. It either locks the strike or unlocks it for a duration. A
+// single channel is used so that commands are applied in the order they were made. The result of driving the strike is
+// sent on result.
+type command struct {
+	lock   bool
+	unlock time.Duration
+	result chan error
 }
 
 // NewDefaultDoorStrike returns an initialized door strike on the default GPIO pin.
 func NewDefaultDoorStrike() (*DoorStrike, error) {
+	return OpenDoorStrike(Config{})
+}
+
+// OpenDoorStrike opens the GPIO pin described by the provided configuration and returns an initialized DoorStrike for
+// it.
+func OpenDoorStrike(cfg Config) (*DoorStrike, error) {
 	_, err := host.Init()
 	if err != nil {
 		return nil, err
 	}
 
-	p := gpioreg.ByName("16")
+	name := cfg.Pin
+	if name == "" {
+		name = DefaultPin
+	}
+
+	p := gpioreg.ByName(name)
 	if p == nil {
 		return nil, errors.New(ErrCouldNotInitializeGPIOPin)
 	}
 
-	return NewDoorStrike(p)
+	return NewDoorStrikeWithConfig(p, cfg)
 }
 
-// NewDoorStrike returns an initialized DoorStrike ready for use.
+// NewDoorStrike returns an initialized fail-secure DoorStrike that is powered by driving the pin high.
 func NewDoorStrike(pin gpio.PinIO) (*DoorStrike, error) {
+	return NewDoorStrikeWithConfig(pin, Config{})
+}
+
+// NewDoorStrikeWithConfig returns an initialized DoorStrike ready for use with the mode and polarity from the provided
+// configuration. The strike is driven to its locked state before it is returned.
+func NewDoorStrikeWithConfig(pin gpio.PinIO, cfg Config) (*DoorStrike, error) {
+	locked, unlocked, err := levels(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
 
 	ds := &DoorStrike{
-		initialized:    true,
-		pin:            pin,
-		timeCtrlChan:   make(chan time.Duration, 100),
-		strikeCtrlChan: make(chan bool, 100),
-		quitStrike:     make(chan bool),
-		quitTime:       make(chan bool),
-		wg:             &wg,
+		pin:      pin,
+		locked:   locked,
+		unlocked: unlocked,
+		commands: make(chan command, 100),
+		ctx:      ctx,
+		cancel:   cancel,
+		wg:       &wg,
+	}
+
+	err = ds.lockPin()
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
-	ds.wg.Add(2)
-	go ds.timeControlLoop()
-	go ds.strikeControlLoop()
+	ds.wg.Add(1)
+	go ds.run()
 
 	return ds, nil
 }
 
-// Done will clean up the electric strike cleanly and will allow for the GPIO pin to be reused.
+// levels returns the pin levels that lock and unlock a strike with the provided configuration.
+func levels(cfg Config) (gpio.Level, gpio.Level, error) {
+	powered := gpio.High
+	if cfg.ActiveLow {
+		powered = gpio.Low
+	}
+
+	switch cfg.Mode {
+	case "", ModeFailSecure:
+		return !powered, powered, nil
+	case ModeFailSafe:
+		return powered, !powered, nil
+	default:
+		return gpio.Low, gpio.Low, fmt.Errorf("%s - %s", ErrInvalidMode, cfg.Mode)
+	}
+}
+
+// Done drives the electric strike to its locked state, regardless of any unlock in progress, and cleans it up so that
+// the GPIO pin can be reused. Done is safe to call more than once.
 func (ds *DoorStrike) Done() {
-	ds.pin.Halt()
-	ds.initialized = false
-	ds.quitStrike <- true
-	ds.quitTime <- true
+	ds.mu.Lock()
+	if ds.done {
+		ds.mu.Unlock()
+		return
+	}
+	ds.done = true
+	ds.mu.Unlock()
+
+	ds.cancel()
 	ds.wg.Wait()
+	ds.pin.Halt()
 }
 
 // Unlock unlocks the electric door strike for the provided duration. Unlock is thread safe and can be called
 // simultaneously from multiple threads. If the duration of a previous call to Unlock has not elapsed, the total
-// duration will be the elapsed duration of the previous call plus the new duration.
+// duration will be the elapsed duration of the previous call plus the new duration. An error is returned if the strike
+// could not be driven to unlock or the pin did not read back the unlocked level.
 func (ds *DoorStrike) Unlock(dur time.Duration) error {
-	if !ds.initialized {
+	return ds.send(command{unlock: dur})
+}
+
+// Lock locks the electric door strike immediately, ending any unlock in progress. Lock is thread safe. An error is
+// returned if the strike could not be driven to lock or the pin did not read back the locked level.
+func (ds *DoorStrike) Lock() error {
+	return ds.send(command{lock: true})
+}
+
+// State returns the state the strike was last driven to, or StateFault if the last actuation failed.
+func (ds *DoorStrike) State() State {
+	ds.stateMu.Lock()
+	defer ds.stateMu.Unlock()
+
+	return ds.state
+}
+
+// send sends the command to the goroutine driving the strike and waits for the result.
+func (ds *DoorStrike) send(cmd command) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.done {
 		return errors.New(ErrStrikeNotInitialized)
 	}
 
-	ds.timeCtrlChan <- dur
-	return nil
+	cmd.result = make(chan error, 1)
+
+	select {
+	case ds.commands <- cmd:
+	case <-ds.ctx.Done():
+		return errors.New(ErrStrikeNotInitialized)
+	}
+
+	return <-cmd.result
 }
 
-func (ds *DoorStrike) timeControlLoop() {
+// run drives the strike until Done is called, at which point the strike is locked before returning.
+func (ds *DoorStrike) run() {
 	defer ds.wg.Done()
 
-	timerStarted := false
-	timer := time.NewTimer(0 * time.Millisecond)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
 
+	unlocked := false
 	for {
 		select {
-		case dur := <-ds.timeCtrlChan:
-			if timerStarted {
-				timer.Reset(dur)
+		case cmd := <-ds.commands:
+			if cmd.lock {
+				// A strike whose last actuation failed is driven again since it may not be locked.
+				if !unlocked && ds.State() == StateLocked {
+					cmd.result <- nil
+					continue
+				}
+
+				if unlocked && !timer.Stop() {
+					<-timer.C
+				}
+				unlocked = false
+				cmd.result <- ds.lockPin()
+				continue
+			}
+
+			if unlocked {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(cmd.unlock)
+				cmd.result <- nil
 				continue
 			}
 
-			timer = time.NewTimer(dur)
-			timerStarted = true
-			ds.strikeCtrlChan <- true
+			err := ds.unlockPin()
+			if err == nil {
+				unlocked = true
+				timer.Reset(cmd.unlock)
+			}
+			cmd.result <- err
 		case <-timer.C:
-			ds.strikeCtrlChan <- false
-			timerStarted = false
-		case <-ds.quitTime:
+			unlocked = false
+			ds.relock("could not relock the strike after the unlock elapsed")
+		case <-ds.ctx.Done():
+			timer.Stop()
+			ds.relock("could not lock the strike while shutting down")
 			return
 		}
 	}
 }
 
-func (ds *DoorStrike) strikeControlLoop() {
-	defer ds.wg.Done()
+// relock locks the strike when there is no caller to return an error to, so a failure is logged instead.
+func (ds *DoorStrike) relock(message string) {
+	err := ds.lockPin()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error(message)
+	}
+}
 
-	for {
-		select {
-		case open := <-ds.strikeCtrlChan:
-			if open {
-				ds.unlock()
-				continue
-			}
+func (ds *DoorStrike) lockPin() error {
+	return ds.drive(ds.locked, StateLocked)
+}
 
-			ds.lock()
-		case <-ds.quitStrike:
-			return
+func (ds *DoorStrike) unlockPin() error {
+	return ds.drive(ds.unlocked, StateUnlocked)
+}
+
+// drive drives the pin to the level and reads it back to confirm the strike reached the state.
+func (ds *DoorStrike) drive(level gpio.Level, state State) error {
+	err := ds.pin.Out(level)
+	if err == nil && ds.pin.Read() != level {
+		err = errors.New(ErrReadbackMismatch)
+	}
+
+	if err != nil {
+		action := "lock"
+		if state == StateUnlocked {
+			action = "unlock"
 		}
+
+		strikeActuationFailuresCounter.WithLabelValues(action).Inc()
+		ds.setState(StateFault)
+		return fmt.Errorf("%s - %s", ErrActuationFailed, err)
 	}
-}
 
-func (ds *DoorStrike) lock() error {
-	return ds.pin.Out(gpio.Low)
+	ds.setState(state)
+	return nil
 }
 
-func (ds *DoorStrike) unlock() error {
-	return ds.pin.Out(gpio.High)
+// setState records the state of the strike and updates the state metrics.
+func (ds *DoorStrike) setState(state State) {
+	ds.stateMu.Lock()
+	defer ds.stateMu.Unlock()
+
+	now := time.Now()
+	if ds.state == StateUnlocked && state != StateUnlocked {
+		strikeUnlockedSecondsCounter.Add(now.Sub(ds.since).Seconds())
+	}
+
+	if ds.state != state {
+		ds.since = now
+	}
+	ds.state = state
+
+	for _, s := range []State{StateLocked, StateUnlocked, StateFault} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		strikeStateGauge.WithLabelValues(string(s)).Set(value)
+	}
 }
@@ -16,6 +16,9 @@
 package strike_test
 
 import (
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,16 +28,46 @@ import (
 	"periph.io/x/periph/conn/gpio"
 )
 
+// readbackPin reads back the last level the mock pin was driven to, like a healthy GPIO output.
+type readbackPin struct {
+	*mocks.MockPinIO
+
+	mu    sync.Mutex
+	level gpio.Level
+}
+
+func givenReadback(pin *mocks.MockPinIO) *readbackPin {
+	return &readbackPin{MockPinIO: pin}
+}
+
+func (p *readbackPin) Out(l gpio.Level) error {
+	err := p.MockPinIO.Out(l)
+	if err == nil {
+		p.mu.Lock()
+		p.level = l
+		p.mu.Unlock()
+	}
+
+	return err
+}
+
+func (p *readbackPin) Read() gpio.Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.level
+}
+
 func TestDoorStrikeUnlock(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockPin := mocks.NewMockPinIO(ctrl)
-	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(1)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(3)
 	mockPin.EXPECT().Out(gpio.High).Return(nil).Times(1)
 	mockPin.EXPECT().Halt().Return(nil).Times(1)
 
-	ds, err := strike.NewDoorStrike(mockPin)
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
@@ -53,11 +86,11 @@ func TestDoorStrikeUnlockMultipleTimes(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockPin := mocks.NewMockPinIO(ctrl)
-	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(1)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(3)
 	mockPin.EXPECT().Out(gpio.High).Return(nil).Times(1)
 	mockPin.EXPECT().Halt().Return(nil).Times(1)
 
-	ds, err := strike.NewDoorStrike(mockPin)
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
@@ -78,11 +111,11 @@ func TestDoorStrikeUnlockWithGap(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockPin := mocks.NewMockPinIO(ctrl)
-	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(2)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(4)
 	mockPin.EXPECT().Out(gpio.High).Return(nil).Times(2)
 	mockPin.EXPECT().Halt().Return(nil).Times(1)
 
-	ds, err := strike.NewDoorStrike(mockPin)
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
@@ -108,11 +141,11 @@ func TestDoorStrikeAfterDoneIsCalled(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockPin := mocks.NewMockPinIO(ctrl)
-	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(0)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(2)
 	mockPin.EXPECT().Out(gpio.High).Return(nil).Times(0)
 	mockPin.EXPECT().Halt().Return(nil).Times(1)
 
-	ds, err := strike.NewDoorStrike(mockPin)
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
 	if err != nil {
 		t.Fatalf("error setting up test - %s", err)
 	}
@@ -124,3 +157,213 @@ func TestDoorStrikeAfterDoneIsCalled(t *testing.T) {
 		t.Errorf("expected error does not match - %s", err)
 	}
 }
+
+func TestDoorStrikeDoneLocksWhileUnlocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPin := mocks.NewMockPinIO(ctrl)
+	startup := mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(1)
+	high := mockPin.EXPECT().Out(gpio.High).Return(nil).Times(1).After(startup)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(1).After(high)
+	mockPin.EXPECT().Halt().Return(nil).Times(1)
+
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+
+	err = ds.Unlock(time.Hour)
+	if err != nil {
+		t.Errorf("error unlocking the strike - %s", err)
+	}
+
+	time.Sleep(1 * time.Millisecond)
+	ds.Done()
+	ds.Done()
+}
+
+func TestDoorStrikeLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPin := mocks.NewMockPinIO(ctrl)
+	startup := mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(1)
+	high := mockPin.EXPECT().Out(gpio.High).Return(nil).Times(1).After(startup)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(2).After(high)
+	mockPin.EXPECT().Halt().Return(nil).Times(1)
+
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer ds.Done()
+
+	err = ds.Unlock(time.Hour)
+	if err != nil {
+		t.Errorf("error unlocking the strike - %s", err)
+	}
+
+	err = ds.Lock()
+	if err != nil {
+		t.Errorf("error locking the strike - %s", err)
+	}
+
+	// Locking an already locked strike does not drive the pin again.
+	err = ds.Lock()
+	if err != nil {
+		t.Errorf("error locking the strike - %s", err)
+	}
+
+	time.Sleep(1 * time.Millisecond)
+}
+
+func TestDoorStrikeLevels(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      strike.Config
+		locked   gpio.Level
+		unlocked gpio.Level
+	}{
+		{name: "fail-secure", cfg: strike.Config{Mode: strike.ModeFailSecure}, locked: gpio.Low, unlocked: gpio.High},
+		{
+			name:     "fail-secure active low",
+			cfg:      strike.Config{Mode: strike.ModeFailSecure, ActiveLow: true},
+			locked:   gpio.High,
+			unlocked: gpio.Low,
+		},
+		{name: "fail-safe", cfg: strike.Config{Mode: strike.ModeFailSafe}, locked: gpio.High, unlocked: gpio.Low},
+		{
+			name:     "fail-safe active low",
+			cfg:      strike.Config{Mode: strike.ModeFailSafe, ActiveLow: true},
+			locked:   gpio.Low,
+			unlocked: gpio.High,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockPin := mocks.NewMockPinIO(ctrl)
+			startup := mockPin.EXPECT().Out(c.locked).Return(nil).Times(1)
+			unlock := mockPin.EXPECT().Out(c.unlocked).Return(nil).Times(1).After(startup)
+			mockPin.EXPECT().Out(c.locked).Return(nil).Times(1).After(unlock)
+			mockPin.EXPECT().Halt().Return(nil).Times(1)
+
+			ds, err := strike.NewDoorStrikeWithConfig(givenReadback(mockPin), c.cfg)
+			if err != nil {
+				t.Fatalf("error setting up test - %s", err)
+			}
+
+			err = ds.Unlock(time.Hour)
+			if err != nil {
+				t.Errorf("error unlocking the strike - %s", err)
+			}
+
+			time.Sleep(1 * time.Millisecond)
+			ds.Done()
+		})
+	}
+}
+
+func TestDoorStrikeInvalidMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPin := mocks.NewMockPinIO(ctrl)
+
+	_, err := strike.NewDoorStrikeWithConfig(mockPin, strike.Config{Mode: "fail-deadly"})
+	if err == nil || !strings.HasPrefix(err.Error(), strike.ErrInvalidMode) {
+		t.Errorf("expected error '%s' but got '%v'", strike.ErrInvalidMode, err)
+	}
+}
+
+func TestDoorStrikeState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPin := mocks.NewMockPinIO(ctrl)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(2)
+	mockPin.EXPECT().Out(gpio.High).Return(nil).Times(1)
+	mockPin.EXPECT().Halt().Return(nil).Times(1)
+
+	ds, err := strike.NewDoorStrike(givenReadback(mockPin))
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer ds.Done()
+
+	if ds.State() != strike.StateLocked {
+		t.Errorf("expected the strike to be locked at startup but it was '%s'", ds.State())
+	}
+
+	err = ds.Unlock(time.Hour)
+	if err != nil {
+		t.Errorf("error unlocking the strike - %s", err)
+	}
+
+	if ds.State() != strike.StateUnlocked {
+		t.Errorf("expected the strike to be unlocked but it was '%s'", ds.State())
+	}
+}
+
+func TestDoorStrikeReadbackMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The pin is stuck low, so driving it high to unlock the strike does not read back.
+	mockPin := mocks.NewMockPinIO(ctrl)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(2)
+	mockPin.EXPECT().Out(gpio.High).Return(nil).Times(1)
+	mockPin.EXPECT().Read().Return(gpio.Low).AnyTimes()
+	mockPin.EXPECT().Halt().Return(nil).Times(1)
+
+	ds, err := strike.NewDoorStrike(mockPin)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer ds.Done()
+
+	err = ds.Unlock(time.Hour)
+	if err == nil || !strings.Contains(err.Error(), strike.ErrReadbackMismatch) {
+		t.Errorf("expected error '%s' but got '%v'", strike.ErrReadbackMismatch, err)
+	}
+
+	if ds.State() != strike.StateFault {
+		t.Errorf("expected the strike to be faulted but it was '%s'", ds.State())
+	}
+}
+
+func TestDoorStrikeActuationError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPin := mocks.NewMockPinIO(ctrl)
+	mockPin.EXPECT().Out(gpio.Low).Return(nil).Times(3)
+	mockPin.EXPECT().Out(gpio.High).Return(errors.New("write failed")).Times(1)
+	mockPin.EXPECT().Read().Return(gpio.Low).AnyTimes()
+	mockPin.EXPECT().Halt().Return(nil).Times(1)
+
+	ds, err := strike.NewDoorStrike(mockPin)
+	if err != nil {
+		t.Fatalf("error setting up test - %s", err)
+	}
+	defer ds.Done()
+
+	err = ds.Unlock(time.Hour)
+	if err == nil || !strings.HasPrefix(err.Error(), strike.ErrActuationFailed) {
+		t.Errorf("expected error '%s' but got '%v'", strike.ErrActuationFailed, err)
+	}
+
+	// Locking a faulted strike drives it again to make sure it is locked.
+	err = ds.Lock()
+	if err != nil {
+		t.Errorf("error locking the strike - %s", err)
+	}
+
+	if ds.State() != strike.StateLocked {
+		t.Errorf("expected the strike to be locked but it was '%s'", ds.State())
+	}
+}